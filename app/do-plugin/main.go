@@ -0,0 +1,76 @@
+// do-plugin is the reference provider plugin. It serves the digitalocean
+// provider over the plugin protocol, spot-manager connects to it with
+// --plugins=<name>=<address>. Pools use it with a plugin provider spec whose
+// config has apiKey, region, image, instanceSize and optionally apiURL.
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	_ "github.com/shovanmaity/spotcluster/provider/digitalocean"
+	"github.com/shovanmaity/spotcluster/provider/plugin"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Set logging property
+func init() {
+	logrus.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp:   true,
+		PadLevelText:    true,
+		TimestampFormat: time.RFC3339,
+	})
+}
+
+func main() {
+	listen := flag.String("listen", "unix:///var/run/spotcluster/digitalocean.sock",
+		"address to serve the plugin at, host:port or unix:///path")
+	flag.Parse()
+
+	registration, ok := provider.GetRegistration(provider.DigitalOcean)
+	if !ok {
+		logrus.Panic("digitalocean provider is not registered")
+	}
+
+	backend := plugin.Backend{
+		Registration: registration,
+		Capabilities: provider.Capabilities{
+			Rebuild:    true,
+			Adopt:      true,
+			DeleteByID: true,
+		},
+		Pool: pool,
+	}
+
+	logrus.Infof("Serving digitalocean provider plugin at %s", *listen)
+	if err := plugin.Serve(*listen, backend); err != nil {
+		logrus.Panic(err)
+	}
+}
+
+// pool returns a digitalocean pool for the plugin config of a pool
+func pool(name string, config map[string]string) (*spotcluster.Pool, error) {
+	if config["apiKey"] == "" {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Errorf("pool %s has no apiKey in its plugin config", name))
+	}
+
+	return &spotcluster.Pool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		ProviderSpec: spotcluster.ProviderSpec{
+			DigitalOcean: &spotcluster.DigitalOcean{
+				APIKey:       config["apiKey"],
+				Region:       config["region"],
+				Image:        config["image"],
+				InstanceSize: config["instanceSize"],
+				APIURL:       config["apiURL"],
+			},
+		},
+	}, nil
+}
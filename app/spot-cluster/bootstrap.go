@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	controller "github.com/shovanmaity/spotcluster/controller/common"
+	clientset "github.com/shovanmaity/spotcluster/pkg/client/clientset/versioned"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// bootstrapLog prints the full bootstrap output of an instance which is
+// kept in a config map by spot-manager.
+func bootstrapLog(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("expected name of the instance")
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", c.String("kubeconfig"))
+	if err != nil {
+		return err
+	}
+
+	clientset, err := clientset.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	kubeClientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	instance, err := clientset.SpotclusterV1alpha1().
+		Instances().
+		Get(context.TODO(), c.Args().First(), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if instance.Status.Bootstrap == nil || instance.Status.Bootstrap.ConfigMap == "" {
+		return errors.Errorf("no bootstrap log found for instance %s", instance.GetName())
+	}
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(instance.Status.Bootstrap.ConfigMap)
+	if err != nil {
+		return err
+	}
+
+	configMap, err := kubeClientset.CoreV1().
+		ConfigMaps(namespace).
+		Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(configMap.Data[controller.BootstrapLogKey])
+	return nil
+}
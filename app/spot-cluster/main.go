@@ -1,8 +1,8 @@
 package main
 
 import (
-	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
@@ -10,11 +10,23 @@ import (
 
 func main() {
 	app := &cli.App{
-		Name:  "greet",
-		Usage: "fight the loneliness!",
-		Action: func(c *cli.Context) error {
-			fmt.Println("Hello friend!")
-			return nil
+		Name:  "spot-cluster",
+		Usage: "inspect spotcluster pools and instances",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "kubeconfig",
+				Usage:   "path of the kubeconfig file",
+				EnvVars: []string{"KUBECONFIG"},
+				Value:   filepath.Join(os.Getenv("HOME"), ".kube", "config"),
+			},
+		},
+		Commands: []*cli.Command{
+			{
+				Name:      "bootstrap-log",
+				Usage:     "print the bootstrap output of an instance",
+				ArgsUsage: "INSTANCE",
+				Action:    bootstrapLog,
+			},
 		},
 	}
 
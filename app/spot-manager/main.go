@@ -1,15 +1,25 @@
 package main
 
 import (
+	"flag"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	controller "github.com/shovanmaity/spotcluster/controller/common"
 	instancecontroller "github.com/shovanmaity/spotcluster/controller/instance"
 	poolcontroller "github.com/shovanmaity/spotcluster/controller/pool"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/shovanmaity/spotcluster/provider/fake"
+	"github.com/shovanmaity/spotcluster/provider/plugin"
 )
 
 // Set logging property
@@ -22,6 +32,87 @@ func init() {
 }
 
 func main() {
+	namespace := flag.String("namespace", os.Getenv("POD_NAMESPACE"),
+		"namespace where the bootstrap logs are kept")
+	workers := flag.Int("instance-workers", controller.DefaultWorkers,
+		"number of instances synced at a time")
+	maxBootstrapJobs := flag.Int("max-bootstrap-jobs", controller.DefaultMaxBootstrapJobs,
+		"number of instances bootstrapped at a time")
+	maxBootstrapJobsPerPool := flag.Int("max-bootstrap-jobs-per-pool",
+		controller.DefaultMaxBootstrapJobsPerPool,
+		"number of instances of a pool bootstrapped at a time")
+	orphanGracePeriod := flag.Duration("orphan-grace-period", 30*time.Minute,
+		"time an orphaned vm is kept before it is deleted")
+	metricsAddress := flag.String("metrics-address", ":9090",
+		"address the prometheus metrics are served at, empty to disable")
+	orphanReportOnly := flag.Bool("orphan-report-only", false,
+		"only report orphaned vms, never delete them")
+	plugins := flag.String("plugins", "",
+		"comma separated name=address of provider plugins, address is host:port or unix:///path")
+	pluginConnectTimeout := flag.Duration("plugin-connect-timeout", time.Minute,
+		"time a provider plugin gets to report its info once it is first used")
+	providerOverride := flag.String("provider", "",
+		"use this provider for every pool irrespective of its provider spec, e.g. fake for a dry run")
+	fakeSSHAddresses := flag.String("fake-ssh-addresses", "",
+		"comma separated host:port of ssh test servers used by fake vms")
+	fakeBootDelay := flag.Duration("fake-boot-delay", 10*time.Second,
+		"time a fake vm takes to become running")
+	fakeCapacity := flag.Int("fake-capacity", 0,
+		"maximum number of fake vms, zero means no limit")
+	fakeFailureRate := flag.Int("fake-failure-rate", 0,
+		"percentage of fake vm creations which fail")
+	fakeReclaimAfter := flag.Duration("fake-reclaim-after", 0,
+		"time after which a running fake vm is reclaimed, zero means never")
+	flag.Parse()
+
+	if *providerOverride != "" {
+		logrus.Warnf("Using provider %s for every pool", *providerOverride)
+		provider.SetOverride(*providerOverride)
+	}
+
+	fakeSpec := spotcluster.Fake{
+		BootDelay:   &metav1.Duration{Duration: *fakeBootDelay},
+		Capacity:    *fakeCapacity,
+		FailureRate: *fakeFailureRate,
+	}
+	if *fakeReclaimAfter > 0 {
+		fakeSpec.ReclaimAfter = &metav1.Duration{Duration: *fakeReclaimAfter}
+	}
+	for _, address := range strings.Split(*fakeSSHAddresses, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			fakeSpec.SSHAddresses = append(fakeSpec.SSHAddresses, address)
+		}
+	}
+	fake.SetDefaults(fakeSpec)
+
+	// Connect to provider plugins
+	for _, entry := range strings.Split(*plugins, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			logrus.Panicf("invalid plugin %s, expected name=address", entry)
+		}
+
+		// Plugin is connected to on first use, it need not be up yet.
+		if err := plugin.Connect(parts[0], parts[1], *pluginConnectTimeout); err != nil {
+			logrus.Panic(err)
+		}
+	}
+
+	// Serve metrics
+	if *metricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		go func() {
+			logrus.Infof("Serving metrics at %s", *metricsAddress)
+			if err := http.ListenAndServe(*metricsAddress, mux); err != nil {
+				logrus.Errorf("error serving metrics: %s", err)
+			}
+		}()
+	}
+
 	// Create pool controller
 	poolcontroller, err := poolcontroller.New()
 	if err != nil {
@@ -29,7 +120,14 @@ func main() {
 	}
 
 	// Create instance controller
-	instancecontroller, err := instancecontroller.New()
+	instancecontroller, err := instancecontroller.New(instancecontroller.Options{
+		Namespace:               *namespace,
+		Workers:                 *workers,
+		MaxBootstrapJobs:        *maxBootstrapJobs,
+		MaxBootstrapJobsPerPool: *maxBootstrapJobsPerPool,
+		OrphanGracePeriod:       *orphanGracePeriod,
+		OrphanReportOnly:        *orphanReportOnly,
+	})
 	if err != nil {
 		logrus.Panic(err)
 	}
@@ -38,15 +136,15 @@ func main() {
 	var waitGroup sync.WaitGroup
 
 	// Start pool controller
+	waitGroup.Add(1)
 	go func() {
-		waitGroup.Add(1)
 		poolcontroller.Run(stopChannel)
 		waitGroup.Done()
 	}()
 
 	// Start instance controller
+	waitGroup.Add(1)
 	go func() {
-		waitGroup.Add(1)
 		instancecontroller.Run(stopChannel)
 		waitGroup.Done()
 	}()
@@ -0,0 +1,15 @@
+package main
+
+// Providers register themselves with the provider registry when they are
+// imported.
+import (
+	_ "github.com/shovanmaity/spotcluster/provider/aws"
+	_ "github.com/shovanmaity/spotcluster/provider/azure"
+	_ "github.com/shovanmaity/spotcluster/provider/container"
+	_ "github.com/shovanmaity/spotcluster/provider/digitalocean"
+	_ "github.com/shovanmaity/spotcluster/provider/fake"
+	_ "github.com/shovanmaity/spotcluster/provider/gcp"
+	_ "github.com/shovanmaity/spotcluster/provider/hetzner"
+	_ "github.com/shovanmaity/spotcluster/provider/openstack"
+	_ "github.com/shovanmaity/spotcluster/provider/static"
+)
@@ -0,0 +1,58 @@
+package common
+
+import (
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reasons of instance conditions
+const (
+	ReasonProvisioned     = "Provisioned"
+	ReasonRunning         = "Running"
+	ReasonPending         = "Pending"
+	ReasonNodeRegistered  = "NodeRegistered"
+	ReasonNodeNotFound    = "NodeNotFound"
+	ReasonKubeletReady    = "KubeletReady"
+	ReasonKubeletNotReady = "KubeletNotReady"
+)
+
+// GetInstanceCondition returns the condition of given type or nil if the
+// condition is not set.
+func GetInstanceCondition(instance *spotcluster.Instance,
+	conditionType spotcluster.InstanceConditionType) *spotcluster.InstanceCondition {
+	for i := range instance.Status.Conditions {
+		if instance.Status.Conditions[i].Type == conditionType {
+			return &instance.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetInstanceCondition sets a condition of an instance. Last transition
+// time is changed only when the status of the condition changes.
+func SetInstanceCondition(instance *spotcluster.Instance,
+	conditionType spotcluster.InstanceConditionType, status corev1.ConditionStatus,
+	reason, message string) {
+	condition := GetInstanceCondition(instance, conditionType)
+	if condition == nil {
+		instance.Status.Conditions = append(instance.Status.Conditions,
+			spotcluster.InstanceCondition{Type: conditionType})
+		condition = &instance.Status.Conditions[len(instance.Status.Conditions)-1]
+	}
+
+	if condition.Status != status {
+		condition.Status = status
+		condition.LastTransitionTime = metav1.Now()
+	}
+	condition.Reason = reason
+	condition.Message = message
+}
+
+// IsInstanceConditionTrue returns true if the condition of given type is
+// set and true.
+func IsInstanceConditionTrue(instance *spotcluster.Instance,
+	conditionType spotcluster.InstanceConditionType) bool {
+	condition := GetInstanceCondition(instance, conditionType)
+	return condition != nil && condition.Status == corev1.ConditionTrue
+}
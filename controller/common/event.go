@@ -0,0 +1,69 @@
+package common
+
+import (
+	spotclusterscheme "github.com/shovanmaity/spotcluster/pkg/client/clientset/versioned/scheme"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	EventComponent = "spot-manager"
+)
+
+// Event reasons for pool
+const (
+	ReasonInstanceCreated      = "InstanceCreated"
+	ReasonInstanceCreateFailed = "InstanceCreateFailed"
+	ReasonInstanceDeleted      = "InstanceDeleted"
+	ReasonDropletAdopted       = "DropletAdopted"
+	ReasonAdoptFailed          = "AdoptFailed"
+	ReasonOrphanFound          = "OrphanFound"
+	ReasonOrphanDeleted        = "OrphanDeleted"
+)
+
+// Event reasons for instance
+const (
+	ReasonVMCreated        = "VMCreated"
+	ReasonVMRunning        = "VMRunning"
+	ReasonVMDeleted        = "VMDeleted"
+	ReasonProvisionFailed  = "ProvisionFailed"
+	ReasonBootstrapStarted = "BootstrapStarted"
+	ReasonBootstrapFailed  = "BootstrapFailed"
+	ReasonBootstrapped     = "Bootstrapped"
+	ReasonBootstrapLogLost = "BootstrapLogLost"
+	ReasonNodeJoined       = "NodeJoined"
+	ReasonNodeReady        = "NodeReady"
+	ReasonNodeDeleted      = "NodeDeleted"
+	ReasonRepairLimited    = "RepairLimited"
+	ReasonDriftDetected    = "DriftDetected"
+	ReasonReleased         = "Released"
+	ReasonDeleteFailed     = "DeleteFailed"
+	ReasonThrottled        = "Throttled"
+	ReasonQuotaExceeded    = "QuotaExceeded"
+	ReasonReclaiming       = "Reclaiming"
+	ReasonDrained          = "Drained"
+	ReasonDrainTimeout     = "DrainTimeout"
+	ReasonActionSucceeded  = "ActionSucceeded"
+	ReasonActionFailed     = "ActionFailed"
+)
+
+func init() {
+	runtime.Must(spotclusterscheme.AddToScheme(scheme.Scheme))
+}
+
+// NewEventRecorder returns an event recorder which records events of pools
+// and instances. Pools and instances are cluster scoped so their events
+// are recorded in the default namespace.
+func NewEventRecorder(kubeClientset kubernetes.Interface) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: kubeClientset.CoreV1().Events(""),
+	})
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{
+		Component: EventComponent,
+	})
+}
@@ -1,14 +1,133 @@
 package common
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
 const (
 	KindInstace       = "Instace"
 	InstaceAPIVersion = "spotcluster.io/v1alpha1"
 )
 
 const (
+	// LabelInstanceID selects the instance of a vm, its value is given by
+	// InstanceIDLabel. The id itself is kept in the instance spec.
 	LabelInstanceID = "instance.spotcluster.io/id"
 )
 
+const (
+	AnnotationAdoptedID = "instance.spotcluster.io/adopted-id"
+	// AnnotationAction asks the controller to run an action on an instance.
+	// It is removed once the action is run.
+	AnnotationAction = "spotcluster.io/action"
+)
+
+// Actions which can be asked for through the action annotation
+const (
+	ActionReboot      = "reboot"
+	ActionRebuild     = "rebuild"
+	ActionRebootstrap = "rebootstrap"
+	ActionReplace     = "replace"
+)
+
+const (
+	ActionSucceeded = "Succeeded"
+	ActionFailed    = "Failed"
+)
+
 const (
 	InstanceProtectionFinalizer = "spotcluster.io/instance-protection"
 )
+
+const (
+	// TaintReclaiming is added to the node of an instance which is being
+	// reclaimed by the provider so that no new pods are scheduled on it.
+	TaintReclaiming = "spotcluster.io/reclaiming"
+)
+
+const (
+	InstanceStatusReclaiming = "Reclaiming"
+	InstanceStatusFailed     = "Failed"
+	InstanceStatusLost       = "Lost"
+)
+
+const (
+	ReasonNodeRegistrationTimeout = "NodeRegistrationTimeout"
+	ReasonNodeNotReady            = "NodeNotReady"
+	ReasonInstanceNotFound        = "InstanceNotFound"
+	ReasonInstanceNotRunning      = "InstanceNotRunning"
+	ReasonInvalidConfig           = "InvalidConfig"
+	ReasonTerminalError           = "TerminalError"
+	ReasonInterrupted             = "Interrupted"
+	ReasonReplaceRequested        = "ReplaceRequested"
+)
+
+const (
+	DefaultNamespace               = "spotcluster"
+	DefaultWorkers                 = 2
+	DefaultMaxBootstrapJobs        = 10
+	DefaultMaxBootstrapJobsPerPool = 3
+)
+
+const (
+	// BootstrapLogLimit is the size of bootstrap output kept in a config map
+	BootstrapLogLimit = 512 * 1024
+	// BootstrapTailLines is the number of bootstrap output lines kept in status
+	BootstrapTailLines = 20
+	// BootstrapLogKey is the config map key which keeps the bootstrap output
+	BootstrapLogKey = "bootstrap.log"
+)
+
+const (
+	RetryBaseDelay   = 5 * time.Second
+	RetryMaxDelay    = 5 * time.Minute
+	QuotaRetryPeriod = 10 * time.Minute
+	// AuthFailureLimit is the number of ssh authentication failures in a row
+	// after which the ssh key is taken as invalid. Vm may not have the key
+	// installed while it is booting.
+	AuthFailureLimit = 5
+)
+
+const (
+	ProviderSyncPeriod = 5 * time.Minute
+	OrphanSyncPeriod   = 10 * time.Minute
+	ReclaimSyncPeriod  = 30 * time.Second
+)
+
+const (
+	// DrainTimeout is the time given to evict the pods of a reclaimed node.
+	// Providers usually give about two minutes before a spot vm is stopped.
+	DrainTimeout = 2 * time.Minute
+	// DrainCheckPeriod is the period between two eviction passes
+	DrainCheckPeriod = 10 * time.Second
+)
+
+// IsTerminated returns true if an instance has failed or has been lost.
+// Such instances are not reconciled anymore, they are replaced.
+func IsTerminated(instance *spotcluster.Instance) bool {
+	return instance.Status.InstanceStatus == InstanceStatusFailed ||
+		instance.Status.InstanceStatus == InstanceStatusLost
+}
+
+// IsReclaiming returns true if the vm of an instance is being reclaimed by
+// the provider. Such instances are drained and replaced.
+func IsReclaiming(instance *spotcluster.Instance) bool {
+	return instance.Status.InstanceStatus == InstanceStatusReclaiming
+}
+
+// InstanceIDLabel returns the value of the instance id label for the id of
+// a vm. Ids which are not valid label values, such as resource paths and
+// addresses, are hashed.
+func InstanceIDLabel(id string) string {
+	if len(validation.IsValidLabelValue(id)) == 0 {
+		return id
+	}
+
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:20])
+}
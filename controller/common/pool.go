@@ -1,5 +1,9 @@
 package common
 
+import (
+	"time"
+)
+
 const (
 	KindPool       = "Pool"
 	PoolAPIVersion = "spotcluster.io/v1alpha1"
@@ -9,3 +13,24 @@ const (
 	LabelClusterName = "pool.spotcluster.io/name"
 	LabelClusterUID  = "pool.spotcluster.io/uid"
 )
+
+const (
+	// AnnotationAdopt lists comma separated droplet ids or tags which are
+	// adopted into the pool. Adopted droplets are counted as replicas, the
+	// replicas of the pool must have room for them.
+	AnnotationAdopt = "pool.spotcluster.io/adopt"
+	// AnnotationAdopting records the droplet ids and the instance names of
+	// an adoption whose instances are not created yet.
+	AnnotationAdopting = "pool.spotcluster.io/adopting"
+)
+
+const (
+	DefaultNodeRegistrationTimeout = 15 * time.Minute
+	FailedInstanceHistoryLimit     = 3
+)
+
+const (
+	DefaultNotReadyThreshold = 5 * time.Minute
+	DefaultMaxRepairs        = 1
+	DefaultRepairWindow      = 30 * time.Minute
+)
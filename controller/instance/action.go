@@ -0,0 +1,150 @@
+package instance
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	controller "github.com/shovanmaity/spotcluster/controller/common"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// runAction runs the action asked for through the action annotation of an
+// instance. Annotation is removed and the outcome is kept in the status
+// whether the action succeeds or not.
+func (c *Controller) runAction(pool *spotcluster.Pool,
+	instance *spotcluster.Instance, action string) error {
+	var message string
+	var err error
+	switch action {
+	case controller.ActionReboot:
+		message, err = c.reboot(pool, instance)
+	case controller.ActionRebuild:
+		message, err = c.rebuild(pool, instance)
+	case controller.ActionRebootstrap:
+		message, err = c.rebootstrap(instance)
+	case controller.ActionReplace:
+		message = "Replacement is requested"
+		c.startReclaim(instance, controller.ReasonReplaceRequested, message)
+	default:
+		err = errors.Errorf("unknown action %s", action)
+	}
+
+	now := metav1.Now()
+	instance.Status.LastAction = &spotcluster.ActionStatus{
+		Name:    action,
+		Time:    &now,
+		Result:  controller.ActionSucceeded,
+		Message: message,
+	}
+	if err != nil {
+		instance.Status.LastAction.Result = controller.ActionFailed
+		instance.Status.LastAction.Message = err.Error()
+	}
+	delete(instance.Annotations, controller.AnnotationAction)
+
+	gotInstance, updateErr := c.clientset.SpotclusterV1alpha1().
+		Instances().
+		Update(context.TODO(), instance, metav1.UpdateOptions{})
+	if updateErr != nil {
+		logrus.Errorf("error updating instance %s after %s: %s",
+			instance.GetName(), action, updateErr)
+		return updateErr
+	}
+
+	if err != nil {
+		logrus.Errorf("action %s on instance %s failed: %s", action, gotInstance.GetName(), err)
+		c.recorder.Eventf(gotInstance, corev1.EventTypeWarning, controller.ReasonActionFailed,
+			"Action %s failed: %s", action, err)
+		return nil
+	}
+
+	logrus.Infof("action %s on instance %s succeeded: %s", action, gotInstance.GetName(), message)
+	c.recorder.Eventf(gotInstance, corev1.EventTypeNormal, controller.ReasonActionSucceeded,
+		"Action %s succeeded: %s", action, message)
+	return nil
+}
+
+// reboot power cycles the vm of an instance. Vm is marked as not running
+// like a rebuilt vm so that a stopped vm is not taken as reclaimed, it is
+// marked as running again once the provider reports so.
+func (c *Controller) reboot(pool *spotcluster.Pool,
+	instance *spotcluster.Instance) (string, error) {
+	if !instance.Spec.InstanceAvailable {
+		return "", errors.New("instance has no vm yet")
+	}
+
+	if pool == nil {
+		return "", errors.New("pool of the instance is not found")
+	}
+
+	if err := rebootVM(pool, instance); err != nil {
+		return "", err
+	}
+
+	instance.Spec.InstanceReady = false
+	controller.SetInstanceCondition(instance, spotcluster.InstanceVMRunning,
+		corev1.ConditionFalse, controller.ReasonPending, "VM is being rebooted")
+	return "VM " + instance.Spec.InstanceName + " is rebooted", nil
+}
+
+// rebuild reimages the vm of an instance with the image of the pool. Old
+// node is deleted and a worker is provisioned again once the vm is running.
+func (c *Controller) rebuild(pool *spotcluster.Pool,
+	instance *spotcluster.Instance) (string, error) {
+	if !instance.Spec.InstanceAvailable {
+		return "", errors.New("instance has no vm yet")
+	}
+
+	if pool == nil {
+		return "", errors.New("pool of the instance is not found")
+	}
+
+	if err := rebuildVM(pool, instance); err != nil {
+		return "", err
+	}
+
+	if err := c.deleteNode(instance); err != nil {
+		logrus.Errorf("error deleting node of rebuilt instance %s: %s", instance.GetName(), err)
+	}
+
+	c.resetWorker(instance)
+	instance.Spec.InstanceReady = false
+	instance.Spec.NodeName = ""
+	instance.Spec.NodePassword = ""
+	controller.SetInstanceCondition(instance, spotcluster.InstanceVMRunning,
+		corev1.ConditionFalse, controller.ReasonPending, "VM is being rebuilt")
+	controller.SetInstanceCondition(instance, spotcluster.InstanceNodeRegistered,
+		corev1.ConditionFalse, controller.ReasonPending, "VM is being rebuilt")
+	return "VM " + instance.Spec.InstanceName + " is being rebuilt", nil
+}
+
+// rebootstrap provisions the worker of an instance again
+func (c *Controller) rebootstrap(instance *spotcluster.Instance) (string, error) {
+	if !instance.Spec.InstanceReady {
+		return "", errors.New("vm of the instance is not running yet")
+	}
+
+	c.resetWorker(instance)
+	return "Worker is being provisioned again", nil
+}
+
+// resetWorker clears the worker details of an instance so that the worker
+// is provisioned again. Adopted instances get a fresh worker as well.
+func (c *Controller) resetWorker(instance *spotcluster.Instance) {
+	c.jobs.cancel(instance.GetName())
+	c.retries.forget(instance.GetName())
+
+	instance.Spec.NodeAvailable = false
+	instance.Spec.NodeReady = false
+	instance.Status.NodeRegisteredAt = nil
+	instance.Status.NodeNotReadySince = nil
+	instance.Status.Attempts = 0
+	delete(instance.Annotations, controller.AnnotationAdoptedID)
+	controller.SetInstanceCondition(instance, spotcluster.InstanceBootstrapped,
+		corev1.ConditionFalse, controller.ReasonPending, "Worker is not provisioned yet")
+	controller.SetInstanceCondition(instance, spotcluster.InstanceNodeReady,
+		corev1.ConditionFalse, controller.ReasonPending, "Worker is not provisioned yet")
+}
@@ -0,0 +1,96 @@
+package instance
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	controller "github.com/shovanmaity/spotcluster/controller/common"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// recordBootstrap keeps the outcome and the output tail of a bootstrap in
+// the instance status. Full output is kept in a config map owned by the
+// instance so that it is deleted along with the instance.
+func (c *Controller) recordBootstrap(instance *spotcluster.Instance,
+	result *provider.BootstrapResult) {
+	startTime := metav1.NewTime(result.StartTime)
+	instance.Status.Bootstrap = &spotcluster.BootstrapStatus{
+		StartTime:  &startTime,
+		Duration:   &metav1.Duration{Duration: result.Duration.Round(time.Second)},
+		ExitCode:   result.ExitCode,
+		OutputTail: tail(result.Output, controller.BootstrapTailLines),
+	}
+
+	name, err := c.saveBootstrapLog(instance, result.Output)
+	if err != nil {
+		logrus.Errorf("error saving bootstrap log of instance %s: %s", instance.GetName(), err)
+		c.recorder.Event(instance, corev1.EventTypeWarning, controller.ReasonBootstrapLogLost,
+			err.Error())
+		return
+	}
+
+	instance.Status.Bootstrap.ConfigMap = c.options.Namespace + "/" + name
+}
+
+// saveBootstrapLog creates or updates the config map with bootstrap output
+// of an instance and returns its name.
+func (c *Controller) saveBootstrapLog(instance *spotcluster.Instance,
+	output []byte) (string, error) {
+	if len(output) > controller.BootstrapLogLimit {
+		output = output[len(output)-controller.BootstrapLogLimit:]
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.GetName() + "-bootstrap",
+			Namespace: c.options.Namespace,
+			Labels: map[string]string{
+				controller.LabelClusterName: instance.GetLabels()[controller.LabelClusterName],
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(instance,
+					spotcluster.SchemeGroupVersion.WithKind("Instance")),
+			},
+		},
+		Data: map[string]string{
+			controller.BootstrapLogKey: strings.ToValidUTF8(string(output), "?"),
+		},
+	}
+
+	configMaps := c.kubeClientset.CoreV1().ConfigMaps(c.options.Namespace)
+	_, err := configMaps.Create(context.TODO(), configMap, metav1.CreateOptions{})
+	if err == nil {
+		return configMap.GetName(), nil
+	}
+
+	if !k8serror.IsAlreadyExists(err) {
+		return "", err
+	}
+
+	gotConfigMap, err := configMaps.Get(context.TODO(), configMap.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	gotConfigMap.Data = configMap.Data
+	_, err = configMaps.Update(context.TODO(), gotConfigMap, metav1.UpdateOptions{})
+	if err != nil {
+		return "", err
+	}
+	return configMap.GetName(), nil
+}
+
+// tail returns the last n lines of an output
+func tail(output []byte, n int) string {
+	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.ToValidUTF8(strings.Join(lines, "\n"), "?")
+}
@@ -0,0 +1,85 @@
+package instance
+
+import (
+	controller "github.com/shovanmaity/spotcluster/controller/common"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// setVMConditions sets the vm conditions of an instance from its spec
+func setVMConditions(instance *spotcluster.Instance) {
+	if !instance.Spec.InstanceAvailable {
+		return
+	}
+
+	controller.SetInstanceCondition(instance, spotcluster.InstanceVMProvisioned,
+		corev1.ConditionTrue, controller.ReasonProvisioned,
+		"VM "+instance.Spec.InstanceName+" is provisioned")
+	if instance.Spec.InstanceReady {
+		controller.SetInstanceCondition(instance, spotcluster.InstanceVMRunning,
+			corev1.ConditionTrue, controller.ReasonRunning,
+			"VM is running at "+instance.Spec.ExternalIP)
+	} else {
+		controller.SetInstanceCondition(instance, spotcluster.InstanceVMRunning,
+			corev1.ConditionFalse, controller.ReasonPending,
+			"VM is not running yet")
+	}
+}
+
+// setNodeConditions sets the node conditions of an instance. A nil node
+// is a node which is not registered.
+func setNodeConditions(instance *spotcluster.Instance, node *corev1.Node) {
+	if node == nil {
+		controller.SetInstanceCondition(instance, spotcluster.InstanceNodeRegistered,
+			corev1.ConditionFalse, controller.ReasonNodeNotFound,
+			"Node is not registered")
+		controller.SetInstanceCondition(instance, spotcluster.InstanceNodeReady,
+			corev1.ConditionFalse, controller.ReasonNodeNotFound,
+			"Node is not registered")
+		return
+	}
+
+	controller.SetInstanceCondition(instance, spotcluster.InstanceNodeRegistered,
+		corev1.ConditionTrue, controller.ReasonNodeRegistered,
+		"Node "+node.GetName()+" is registered")
+	if isNodeReady(node) {
+		controller.SetInstanceCondition(instance, spotcluster.InstanceNodeReady,
+			corev1.ConditionTrue, controller.ReasonKubeletReady,
+			"Node "+node.GetName()+" is ready")
+		return
+	}
+
+	message := "Node " + node.GetName() + " is not ready"
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady && condition.Message != "" {
+			message = condition.Message
+		}
+	}
+	controller.SetInstanceCondition(instance, spotcluster.InstanceNodeReady,
+		corev1.ConditionFalse, controller.ReasonKubeletNotReady, message)
+}
+
+// clearRepairLimited marks the repair of an instance as no longer limited
+func clearRepairLimited(instance *spotcluster.Instance) {
+	if controller.GetInstanceCondition(instance, spotcluster.InstanceRepairLimited) == nil {
+		return
+	}
+	controller.SetInstanceCondition(instance, spotcluster.InstanceRepairLimited,
+		corev1.ConditionFalse, controller.ReasonKubeletReady, "Node is ready")
+}
+
+// resetVMConditions marks the vm and the node of an instance as gone
+func resetVMConditions(instance *spotcluster.Instance, reason, message string) {
+	for _, conditionType := range []spotcluster.InstanceConditionType{
+		spotcluster.InstanceVMProvisioned,
+		spotcluster.InstanceVMRunning,
+		spotcluster.InstanceBootstrapped,
+		spotcluster.InstanceNodeReady,
+	} {
+		if controller.GetInstanceCondition(instance, conditionType) == nil {
+			continue
+		}
+		controller.SetInstanceCondition(instance, conditionType,
+			corev1.ConditionFalse, reason, message)
+	}
+}
@@ -6,30 +6,64 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
+	controller "github.com/shovanmaity/spotcluster/controller/common"
 	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
 	clientset "github.com/shovanmaity/spotcluster/pkg/client/clientset/versioned"
 	informer "github.com/shovanmaity/spotcluster/pkg/client/informers/externalversions"
 	lister "github.com/shovanmaity/spotcluster/pkg/client/listers/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	kubeinformer "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	corelister "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 )
 
+// Options contains configurable settings of instance controller
+type Options struct {
+	// Namespace is where the config maps with bootstrap logs are kept
+	Namespace string
+	// Workers is the number of instances synced at a time
+	Workers int
+	// MaxBootstrapJobs is the number of instances bootstrapped at a time
+	MaxBootstrapJobs int
+	// MaxBootstrapJobsPerPool is the number of instances of a pool
+	// bootstrapped at a time
+	MaxBootstrapJobsPerPool int
+	// OrphanGracePeriod is the time an orphaned vm is kept before it is deleted
+	OrphanGracePeriod time.Duration
+	// OrphanReportOnly only reports orphaned vms, they are never deleted
+	OrphanReportOnly bool
+}
+
 // Controller contains required objects for a instance controller
 type Controller struct {
+	options         Options
 	kubeClientset   kubernetes.Interface
 	clientset       clientset.Interface
 	informerFactory informer.SharedInformerFactory
 	instanceLister  lister.InstanceLister
 	instanceSynced  cache.InformerSynced
+	poolLister      lister.PoolLister
+	poolSynced      cache.InformerSynced
+	kubeInformers   kubeinformer.SharedInformerFactory
+	nodeLister      corelister.NodeLister
+	nodeIndexer     cache.Indexer
+	nodeSynced      cache.InformerSynced
 	workqueue       workqueue.RateLimitingInterface
+	recorder        record.EventRecorder
+	repairs         *repairLimiter
+	orphans         map[string]time.Time
+	jobs            *jobManager
+	retries         *retryTracker
 }
 
 // New returns an instance of Controller object
-func New() (*Controller, error) {
+func New(options Options) (*Controller, error) {
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		return nil, err
@@ -44,7 +78,15 @@ func New() (*Controller, error) {
 	if err != nil {
 		return nil, err
 	}
+	provider.SetKubeClient(kubeClientset)
+
+	return NewWithClientsets(options, kubeClientset, clientset)
+}
 
+// NewWithClientsets returns an instance controller which uses the given
+// clientsets
+func NewWithClientsets(options Options, kubeClientset kubernetes.Interface,
+	clientset clientset.Interface) (*Controller, error) {
 	informerFactory := informer.NewSharedInformerFactory(clientset, 30*time.Second)
 	instanceLister := informerFactory.Spotcluster().
 		V1alpha1().
@@ -55,16 +97,62 @@ func New() (*Controller, error) {
 		Instances().
 		Informer().
 		HasSynced
+	poolLister := informerFactory.Spotcluster().
+		V1alpha1().
+		Pools().
+		Lister()
+	poolSynced := informerFactory.Spotcluster().
+		V1alpha1().
+		Pools().
+		Informer().
+		HasSynced
+	kubeInformers := kubeinformer.NewSharedInformerFactory(kubeClientset, 30*time.Second)
+	nodeInformer := kubeInformers.Core().V1().Nodes()
+	err := nodeInformer.Informer().AddIndexers(cache.Indexers{
+		providerIDIndex: providerIDIndexFunc,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	workqueue := workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "INSTANCE")
 
+	if options.Namespace == "" {
+		options.Namespace = controller.DefaultNamespace
+	}
+	if options.Workers <= 0 {
+		options.Workers = controller.DefaultWorkers
+	}
+	if options.MaxBootstrapJobs <= 0 {
+		options.MaxBootstrapJobs = controller.DefaultMaxBootstrapJobs
+	}
+	if options.MaxBootstrapJobsPerPool <= 0 {
+		options.MaxBootstrapJobsPerPool = controller.DefaultMaxBootstrapJobsPerPool
+	}
+
 	c := &Controller{
+		options:         options,
 		kubeClientset:   kubeClientset,
 		clientset:       clientset,
 		informerFactory: informerFactory,
 		instanceLister:  instanceLister,
 		instanceSynced:  instanceSynced,
+		poolLister:      poolLister,
+		poolSynced:      poolSynced,
+		kubeInformers:   kubeInformers,
+		nodeLister:      nodeInformer.Lister(),
+		nodeIndexer:     nodeInformer.Informer().GetIndexer(),
+		nodeSynced:      nodeInformer.Informer().HasSynced,
 		workqueue:       workqueue,
+		recorder:        controller.NewEventRecorder(kubeClientset),
+		repairs:         newRepairLimiter(),
+		orphans:         make(map[string]time.Time),
+		retries:         newRetryTracker(),
 	}
+	c.jobs = newJobManager(options.MaxBootstrapJobs, options.MaxBootstrapJobsPerPool,
+		func(key string) {
+			c.workqueue.Add(key)
+		})
 
 	c.informerFactory.Spotcluster().
 		V1alpha1().
@@ -128,16 +216,19 @@ func (c *Controller) Run(stopCh <-chan struct{}) error {
 	defer c.workqueue.ShutDown()
 
 	c.informerFactory.Start(stopCh)
+	c.kubeInformers.Start(stopCh)
 	logrus.WithField("controller", "instance").
 		Info("Waiting for informer caches to sync.")
-	if ok := cache.WaitForCacheSync(stopCh, c.instanceSynced); !ok {
+	if ok := cache.WaitForCacheSync(stopCh, c.instanceSynced, c.poolSynced, c.nodeSynced); !ok {
 		return errors.New("failed to wait for caches to sync")
 	}
 
-	worker := 1
-	for i := 0; i < worker; i++ {
+	for i := 0; i < c.options.Workers; i++ {
 		go wait.Until(c.worker, time.Second, stopCh)
 	}
+	go wait.Until(c.syncProvider, controller.ProviderSyncPeriod, stopCh)
+	go wait.Until(c.collectOrphans, controller.OrphanSyncPeriod, stopCh)
+	go wait.Until(c.detectInterruptions, controller.ReclaimSyncPeriod, stopCh)
 	logrus.WithField("controller", "instance").
 		Info("Started controller.")
 
@@ -169,7 +260,15 @@ func (c *Controller) do() bool {
 			return nil
 		}
 
-		if err := c.sync(key); err != nil {
+		err := c.sync(key)
+		var requeue *requeueError
+		if errors.As(err, &requeue) {
+			// Backoff of the instance is tracked by the controller.
+			c.workqueue.Forget(obj)
+			c.workqueue.AddAfter(key, requeue.after)
+			return nil
+		}
+		if err != nil {
 			c.workqueue.AddRateLimited(key)
 			return errors.Errorf("error syncing '%s': %s, requeuing", key, err.Error())
 		}
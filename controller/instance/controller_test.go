@@ -0,0 +1,356 @@
+package instance
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	controller "github.com/shovanmaity/spotcluster/controller/common"
+	poolcontroller "github.com/shovanmaity/spotcluster/controller/pool"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	"github.com/shovanmaity/spotcluster/pkg/client/clientset/versioned/fake"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	_ "github.com/shovanmaity/spotcluster/provider/fake"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+// validateLabels rejects instances with label values which the api server
+// does not accept
+func validateLabels(action k8stesting.Action) (bool, runtime.Object, error) {
+	instance := action.(k8stesting.CreateAction).GetObject().(*spotcluster.Instance)
+	for key, value := range instance.GetLabels() {
+		if errs := validation.IsValidLabelValue(value); len(errs) != 0 {
+			return true, nil, k8serror.NewInvalid(
+				spotcluster.SchemeGroupVersion.WithKind(controller.KindInstace).GroupKind(),
+				instance.GetName(), field.ErrorList{
+					field.Invalid(field.NewPath("metadata", "labels").Key(key), value,
+						strings.Join(errs, "; ")),
+				})
+		}
+	}
+	return false, nil, nil
+}
+
+// newTestClientset returns a clientset which names the instances created
+// with a generated name and gives them a uid and a creation time, as the
+// api server does. Label values are validated as well.
+func newTestClientset(objects ...runtime.Object) *fake.Clientset {
+	var lock sync.Mutex
+	created := 0
+
+	clientset := fake.NewSimpleClientset(objects...)
+	clientset.PrependReactor("update", "instances", validateLabels)
+	clientset.PrependReactor("create", "instances",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			created++
+			instance := action.(k8stesting.CreateAction).GetObject().(*spotcluster.Instance)
+			if instance.GetName() == "" {
+				instance.Name = instance.GenerateName + strconv.Itoa(created)
+			}
+			instance.UID = types.UID("uid-" + instance.GetName())
+			instance.CreationTimestamp = metav1.Now()
+			return false, nil, nil
+		})
+	clientset.PrependReactor("create", "instances", validateLabels)
+	return clientset
+}
+
+// eventRecorder keeps the reasons of the events of the objects of a kind
+type eventRecorder struct {
+	sync.Mutex
+	reasons map[string]bool
+}
+
+// record records the events created by a clientset. Events of the cluster
+// scoped objects are not accepted by the fake clientset otherwise.
+func (r *eventRecorder) record(clientset *kubefake.Clientset) {
+	r.reasons = make(map[string]bool)
+	clientset.PrependReactor("create", "events",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			r.Lock()
+			defer r.Unlock()
+
+			event := action.(k8stesting.CreateAction).GetObject().(*corev1.Event)
+			r.reasons[event.InvolvedObject.Kind+"/"+event.Reason] = true
+			return true, event, nil
+		})
+}
+
+// has returns true if an event with the given reason is recorded for an
+// object of the given kind
+func (r *eventRecorder) has(kind, reason string) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.reasons[kind+"/"+reason]
+}
+
+// waitFor waits until the condition is true
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); {
+		if condition() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+// count returns the number of instances in the cache of the controller
+// which match the filter
+func count(c *Controller, filter func(i *spotcluster.Instance) bool) int {
+	instances, err := c.instanceLister.List(labels.Everything())
+	if err != nil {
+		return -1
+	}
+
+	n := 0
+	for _, i := range instances {
+		if filter(i) {
+			n++
+		}
+	}
+	return n
+}
+
+// syncInstances syncs the instances in the cache of the controller which
+// match the filter. Errors asking for a retry are expected, instances are
+// not synced again after a retry delay.
+func syncInstances(t *testing.T, c *Controller, filter func(i *spotcluster.Instance) bool) {
+	t.Helper()
+	instances, err := c.instanceLister.List(labels.Everything())
+	if err != nil {
+		t.Fatalf("error listing instances: %s", err)
+	}
+
+	for _, i := range instances {
+		if !filter(i) {
+			continue
+		}
+		err := c.sync(i.GetName())
+		if _, ok := err.(*requeueError); err != nil && !ok {
+			t.Fatalf("error syncing instance %s: %s", i.GetName(), err)
+		}
+	}
+}
+
+// updatePool changes the pool and bumps its generation as the api server
+// does for a spec change
+func updatePool(t *testing.T, clientset *fake.Clientset, change func(pool *spotcluster.Pool)) {
+	t.Helper()
+	pool, err := clientset.SpotclusterV1alpha1().
+		Pools().
+		Get(context.TODO(), "pool", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting pool: %s", err)
+	}
+
+	change(pool)
+	pool.Generation++
+	_, err = clientset.SpotclusterV1alpha1().
+		Pools().
+		Update(context.TODO(), pool, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("error updating pool: %s", err)
+	}
+}
+
+func isPending(i *spotcluster.Instance) bool {
+	return !i.Spec.InstanceAvailable && !controller.IsTerminated(i) && !controller.IsReclaiming(i)
+}
+
+func isRunning(i *spotcluster.Instance) bool {
+	return i.Spec.InstanceReady && !controller.IsTerminated(i) && !controller.IsReclaiming(i)
+}
+
+func isReclaiming(i *spotcluster.Instance) bool {
+	return controller.IsReclaiming(i)
+}
+
+func isLost(i *spotcluster.Instance) bool {
+	return i.Status.InstanceStatus == controller.InstanceStatusLost
+}
+
+func isReleased(i *spotcluster.Instance) bool {
+	return isLost(i) && !i.Spec.InstanceAvailable
+}
+
+// TestPool drives a pool of the fake provider through a scale up, a
+// failing create and a reclaim of its vms. Pool controller runs in the
+// background, instances are synced by the test.
+func TestPool(t *testing.T) {
+	pool := &spotcluster.Pool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "pool",
+			UID:        types.UID("pool-uid"),
+			Generation: 1,
+		},
+		Spec: spotcluster.ClusterSpec{
+			Replicas: 1,
+		},
+		ProviderSpec: spotcluster.ProviderSpec{
+			Fake: &spotcluster.Fake{},
+		},
+	}
+	kubeClientset := kubefake.NewSimpleClientset()
+	events := &eventRecorder{}
+	events.record(kubeClientset)
+	clientset := newTestClientset(pool)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	pools := poolcontroller.NewWithClientsets(kubeClientset, clientset)
+	go pools.Run(stopCh)
+
+	c, err := NewWithClientsets(Options{}, kubeClientset, clientset)
+	if err != nil {
+		t.Fatalf("error creating controller: %s", err)
+	}
+	c.informerFactory.Start(stopCh)
+	c.kubeInformers.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, c.instanceSynced, c.poolSynced, c.nodeSynced) {
+		t.Fatal("error waiting for caches to sync")
+	}
+
+	// VMs of the fake provider are kept in memory by the package, the ones
+	// left by an earlier run are removed.
+	p, _, err := provider.ForPool(pool)
+	if err != nil {
+		t.Fatalf("error getting provider: %s", err)
+	}
+	if err := p.Delete(context.TODO(), provider.OwnerTag); err != nil {
+		t.Fatalf("error deleting vms: %s", err)
+	}
+	vms := func() []provider.InstanceConfig {
+		list, err := p.List(context.TODO(), provider.OwnerTag)
+		if err != nil {
+			t.Fatalf("error listing vms: %s", err)
+		}
+		return list
+	}
+
+	// Scale up.
+	waitFor(t, "pool to be scaled up", func() bool {
+		return count(c, isPending) == 1
+	})
+	syncInstances(t, c, isPending)
+	waitFor(t, "vms to be running", func() bool {
+		return count(c, isRunning) == 1
+	})
+	if n := len(vms()); n != 1 {
+		t.Fatalf("expected 1 vm, got %d", n)
+	}
+
+	// Create fails on an invalid configuration, the failed instance is not
+	// replaced until the pool is updated.
+	updatePool(t, clientset, func(pool *spotcluster.Pool) {
+		pool.Spec.Replicas = 2
+		pool.ProviderSpec.Fake.FailureRate = 100
+		pool.ProviderSpec.Fake.FailureClass = string(provider.ErrorInvalidConfig)
+	})
+	waitFor(t, "pool to be scaled up", func() bool {
+		return count(c, isPending) == 1
+	})
+	syncInstances(t, c, isPending)
+	waitFor(t, "instance to fail", func() bool {
+		return count(c, func(i *spotcluster.Instance) bool {
+			return i.Status.InstanceStatus == controller.InstanceStatusFailed &&
+				i.Status.FailureReason == controller.ReasonInvalidConfig
+		}) == 1
+	})
+	waitFor(t, "pool to hold the scale up", func() bool {
+		return events.has(controller.KindPool, controller.ReasonInvalidConfig)
+	})
+	if n := count(c, isPending); n != 0 {
+		t.Fatalf("expected failed instance not to be replaced, got %d pending instances", n)
+	}
+
+	updatePool(t, clientset, func(pool *spotcluster.Pool) {
+		pool.ProviderSpec.Fake.FailureRate = 0
+	})
+	waitFor(t, "failed instance to be replaced", func() bool {
+		return count(c, isPending) == 1
+	})
+	syncInstances(t, c, isPending)
+	waitFor(t, "vms to be running", func() bool {
+		return count(c, isRunning) == 2
+	})
+
+	// VMs are about to be reclaimed, reclaimed instances are replaced while
+	// their nodes are drained.
+	updatePool(t, clientset, func(pool *spotcluster.Pool) {
+		pool.ProviderSpec.Fake.ReclaimAfter = &metav1.Duration{Duration: time.Hour}
+		pool.ProviderSpec.Fake.ReclaimNotice = &metav1.Duration{Duration: 2 * time.Hour}
+	})
+	waitFor(t, "pool to be updated", func() bool {
+		pool, err := c.poolLister.Get("pool")
+		return err == nil && pool.ProviderSpec.Fake.ReclaimAfter != nil
+	})
+	c.detectInterruptions()
+	waitFor(t, "instances to be reclaimed", func() bool {
+		return count(c, isReclaiming) == 2
+	})
+	waitFor(t, "reclaimed instances to be replaced", func() bool {
+		return count(c, isPending) == 2
+	})
+
+	syncInstances(t, c, isReclaiming)
+	waitFor(t, "reclaimed instances to be lost", func() bool {
+		return count(c, isLost) == 2
+	})
+	syncInstances(t, c, isLost)
+	waitFor(t, "vms of lost instances to be released", func() bool {
+		return count(c, isReleased) == 2
+	})
+	syncInstances(t, c, isPending)
+	waitFor(t, "vms to be running", func() bool {
+		return count(c, isRunning) == 2
+	})
+
+	instances, err := c.instanceLister.List(labels.Everything())
+	if err != nil {
+		t.Fatalf("error listing instances: %s", err)
+	}
+	running := map[string]bool{}
+	for _, i := range instances {
+		if isRunning(i) {
+			running[string(i.GetUID())] = true
+		}
+		if isLost(i) && i.Status.FailureReason != controller.ReasonInterrupted {
+			t.Fatalf("expected instance %s to be lost to an interruption, got %s",
+				i.GetName(), i.Status.FailureReason)
+		}
+	}
+
+	list := vms()
+	if len(list) != 2 {
+		t.Fatalf("expected 2 vms, got %d", len(list))
+	}
+	for _, vm := range list {
+		owned := false
+		for _, tag := range vm.Tags {
+			owned = owned || running[tag]
+		}
+		if !owned {
+			t.Fatalf("expected vm %s to belong to a running instance, got tags %v", vm.ID, vm.Tags)
+		}
+	}
+}
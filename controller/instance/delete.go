@@ -6,8 +6,10 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
+	controller "github.com/shovanmaity/spotcluster/controller/common"
 	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
-	"github.com/shovanmaity/spotcluster/provider/digitalocean"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	corev1 "k8s.io/api/core/v1"
 	k8serror "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -20,21 +22,31 @@ func (c *Controller) delete(instance *spotcluster.Instance, pool *spotcluster.Po
 
 	if pool == nil {
 		logrus.Error("unable to perform delete operation: got nil pool object")
+		c.recorder.Event(instance, corev1.EventTypeWarning, controller.ReasonDeleteFailed,
+			"Pool of the instance is not found")
 		return
 	}
 
 	if err := c.deleteVM(instance, pool); err != nil {
 		logrus.Errorf("unable to perform delete operation: %s", err)
+		c.recorder.Event(instance, corev1.EventTypeWarning, controller.ReasonDeleteFailed,
+			err.Error())
 		return
 	}
 
 	logrus.Infof("successfully deleted vm instance %s", instance.GetName())
+	c.recorder.Event(instance, corev1.EventTypeNormal, controller.ReasonVMDeleted,
+		"Deleted VM")
 	if err := c.deleteNode(instance); err != nil {
 		logrus.Errorf("unable to perform delete operation: %s", err)
+		c.recorder.Event(instance, corev1.EventTypeWarning, controller.ReasonDeleteFailed,
+			err.Error())
 		return
 	}
 
 	logrus.Infof("successfully deleted node %s", instance.GetName())
+	c.recorder.Event(instance, corev1.EventTypeNormal, controller.ReasonNodeDeleted,
+		"Deleted node")
 
 	instance.Finalizers = []string{}
 	gotInstance, err := c.clientset.SpotclusterV1alpha1().
@@ -54,14 +66,23 @@ func (c *Controller) deleteNode(instance *spotcluster.Instance) error {
 		return errors.New("unable to delete node: got nil instance object")
 	}
 
-	err := c.kubeClientset.CoreV1().
-		Nodes().
-		Delete(context.TODO(), instance.GetName(), metav1.DeleteOptions{})
+	node, err := c.getNode(instance)
 	if err != nil {
 		if k8serror.IsNotFound(err) {
 			logrus.Infof("unable to delete node: node %s not found", instance.GetName())
 			return nil
 		}
+		return err
+	}
+
+	err = c.kubeClientset.CoreV1().
+		Nodes().
+		Delete(context.TODO(), node.GetName(), metav1.DeleteOptions{})
+	if err != nil {
+		if k8serror.IsNotFound(err) {
+			logrus.Infof("unable to delete node: node %s not found", node.GetName())
+			return nil
+		}
 	}
 
 	return nil
@@ -76,6 +97,9 @@ func (c *Controller) deleteVM(instance *spotcluster.Instance, pool *spotcluster.
 		return errors.New("unable to delete VM: got nil pool object")
 	}
 
-	// TODO based on provider call delete function from different provider
-	return digitalocean.DeleteInstance(instance, pool)
+	p, _, err := provider.ForPool(pool)
+	if err != nil {
+		return err
+	}
+	return p.Delete(context.TODO(), string(instance.GetUID()))
 }
@@ -0,0 +1,105 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	controller "github.com/shovanmaity/spotcluster/controller/common"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// syncProvider compares every instance against its vm at the provider.
+// Instances whose vm is missing or stopped are reclaimed and the
+// attributes changed out of band are recorded in the instance status.
+func (c *Controller) syncProvider() {
+	instances, err := c.instanceLister.List(labels.Everything())
+	if err != nil {
+		logrus.Errorf("error listing instances: %s", err)
+		return
+	}
+
+	pools := make(map[string]*spotcluster.Pool)
+	for _, i := range instances {
+		if i.DeletionTimestamp != nil || controller.IsTerminated(i) ||
+			controller.IsReclaiming(i) || !i.Spec.InstanceAvailable {
+			continue
+		}
+
+		poolName := i.GetLabels()[controller.LabelClusterName]
+		pool, ok := pools[poolName]
+		if !ok {
+			pool, err = c.clientset.SpotclusterV1alpha1().
+				Pools().
+				Get(context.TODO(), poolName, metav1.GetOptions{})
+			if err != nil {
+				logrus.Errorf("error getting pool %s: %s", poolName, err)
+				continue
+			}
+			pools[poolName] = pool
+		}
+
+		c.syncProviderInstance(pool, i.DeepCopy())
+	}
+}
+
+func (c *Controller) syncProviderInstance(pool *spotcluster.Pool,
+	instance *spotcluster.Instance) {
+	vm, reclaimed := c.checkReclaimed(pool, instance)
+	if reclaimed || vm == nil {
+		return
+	}
+
+	drift := detectDrift(pool, instance, vm)
+	if reflect.DeepEqual(drift, instance.Status.Drift) {
+		return
+	}
+
+	instance.Status.Drift = drift
+	gotInstance, err := c.clientset.SpotclusterV1alpha1().
+		Instances().
+		Update(context.TODO(), instance, metav1.UpdateOptions{})
+	if err != nil {
+		logrus.Errorf("error updating instance %s with drift: %s", instance.GetName(), err)
+		return
+	}
+
+	logrus.Warnf("instance %s has drifted: %v", gotInstance.GetName(), drift)
+	if len(drift) != 0 {
+		c.recorder.Eventf(gotInstance, corev1.EventTypeWarning, controller.ReasonDriftDetected,
+			"VM has drifted: %s", strings.Join(drift, "; "))
+	}
+}
+
+// detectDrift returns the attributes of a vm which differ from what the
+// pool asks for or from what is recorded in the instance.
+func detectDrift(pool *spotcluster.Pool, instance *spotcluster.Instance,
+	vm *provider.InstanceConfig) []string {
+	drift := []string{}
+	compare := func(attribute, expected, got string) {
+		if expected != "" && got != "" && expected != got {
+			drift = append(drift,
+				fmt.Sprintf("%s: expected %s, got %s", attribute, expected, got))
+		}
+	}
+
+	if registration, ok := provider.GetRegistration(provider.ProviderName(pool)); ok {
+		template := registration.Template(pool)
+		compare("size", template.Size, vm.Size)
+		compare("image", template.Image, vm.Image)
+		compare("region", template.Region, vm.Region)
+	}
+	compare("internalIP", instance.Spec.InternalIP, vm.InternalIP)
+	compare("externalIP", instance.Spec.ExternalIP, vm.ExteralIP)
+
+	if len(drift) == 0 {
+		return nil
+	}
+	return drift
+}
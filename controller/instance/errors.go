@@ -0,0 +1,191 @@
+package instance
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	controller "github.com/shovanmaity/spotcluster/controller/common"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// requeueError asks the worker to sync the instance again after a delay
+type requeueError struct {
+	after time.Duration
+	err   error
+}
+
+func (e *requeueError) Error() string {
+	return e.err.Error()
+}
+
+// classify returns the class of an error returned by a provider, ssh or
+// kubernetes along with the delay asked for, if any.
+func classify(err error) (provider.ErrorClass, time.Duration) {
+	if class := provider.ClassOf(err); class != "" {
+		return class, provider.RetryAfterOf(err)
+	}
+
+	if delay, ok := k8serror.SuggestsClientDelay(err); ok || k8serror.IsTooManyRequests(err) {
+		return provider.ErrorThrottled, time.Duration(delay) * time.Second
+	}
+
+	switch {
+	case k8serror.IsInvalid(err), k8serror.IsBadRequest(err),
+		k8serror.IsForbidden(err), k8serror.IsUnauthorized(err):
+		return provider.ErrorInvalidConfig, 0
+	case k8serror.IsConflict(err), k8serror.IsNotFound(err),
+		k8serror.IsServerTimeout(err), k8serror.IsTimeout(err),
+		k8serror.IsInternalError(err), k8serror.IsServiceUnavailable(err):
+		return provider.ErrorTransient, 0
+	}
+
+	// Ssh and network errors are expected while a vm is booting, ssh key
+	// which is not accepted is handled by handleError once it repeats.
+	return provider.ErrorTransient, 0
+}
+
+// isAuthError returns true if ssh did not accept the key of the pool
+func isAuthError(err error) bool {
+	return strings.Contains(err.Error(), "unable to authenticate")
+}
+
+// handleError decides what happens to an instance after a failed
+// provisioning step. Invalid configuration and terminal errors mark the
+// instance as failed, so does a ssh key which is not accepted a few times
+// in a row. Other errors are retried with backoff. Condition of the failed
+// step, last error and attempts are kept in the status.
+func (c *Controller) handleError(pool *spotcluster.Pool, instance *spotcluster.Instance,
+	conditionType spotcluster.InstanceConditionType, reason string, err error) error {
+	key := instance.GetName()
+	controller.SetInstanceCondition(instance, conditionType, corev1.ConditionFalse,
+		reason, err.Error())
+	instance.Status.LastError = err.Error()
+	instance.Status.Attempts++
+
+	if isRegistrationTimedOut(pool, instance) {
+		c.retries.forget(key)
+		c.markFailed(instance, controller.ReasonNodeRegistrationTimeout, err.Error())
+		return nil
+	}
+
+	class, retryAfter := classify(err)
+	if c.retries.authFailed(key, isAuthError(err)) >= controller.AuthFailureLimit {
+		class = provider.ErrorInvalidConfig
+	}
+	switch class {
+	case provider.ErrorInvalidConfig:
+		c.retries.forget(key)
+		if pool != nil {
+			instance.Status.PoolGeneration = pool.GetGeneration()
+		}
+		c.markFailed(instance, controller.ReasonInvalidConfig, err.Error())
+		return nil
+	case provider.ErrorTerminal:
+		c.retries.forget(key)
+		c.markFailed(instance, controller.ReasonTerminalError, err.Error())
+		return nil
+	case provider.ErrorThrottled:
+		c.recorder.Event(instance, corev1.EventTypeWarning, controller.ReasonThrottled,
+			err.Error())
+	case provider.ErrorQuota:
+		c.recorder.Event(instance, corev1.EventTypeWarning, controller.ReasonQuotaExceeded,
+			err.Error())
+		retryAfter = controller.QuotaRetryPeriod
+	}
+
+	delay := c.retries.failed(key, retryAfter)
+	logrus.Warnf("%s error for instance %s, retrying after %s: %s",
+		class, key, delay, err)
+	_, updateErr := c.clientset.SpotclusterV1alpha1().
+		Instances().
+		Update(context.TODO(), instance, metav1.UpdateOptions{})
+	if updateErr != nil {
+		logrus.Errorf("error updating instance %s: %s", key, updateErr)
+	}
+	return &requeueError{
+		after: delay,
+		err:   err,
+	}
+}
+
+// retryTracker keeps the time before which provisioning of an instance is
+// not retried. It is required as informer resync adds every instance to
+// the workqueue irrespective of the backoff.
+type retryTracker struct {
+	sync.Mutex
+	limiter      workqueue.RateLimiter
+	notBefore    map[string]time.Time
+	authFailures map[string]int
+}
+
+func newRetryTracker() *retryTracker {
+	return &retryTracker{
+		limiter: workqueue.NewItemExponentialFailureRateLimiter(
+			controller.RetryBaseDelay, controller.RetryMaxDelay),
+		notBefore:    make(map[string]time.Time),
+		authFailures: make(map[string]int),
+	}
+}
+
+// failed records a failure and returns the delay before the next retry.
+// Exponential backoff is used if no delay is asked for.
+func (r *retryTracker) failed(key string, after time.Duration) time.Duration {
+	r.Lock()
+	defer r.Unlock()
+
+	delay := r.limiter.When(key)
+	if after > delay {
+		delay = after
+	}
+	r.notBefore[key] = time.Now().Add(delay)
+	return delay
+}
+
+// wait returns the time left before the next retry of a key
+func (r *retryTracker) wait(key string) (time.Duration, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	notBefore, ok := r.notBefore[key]
+	if !ok {
+		return 0, false
+	}
+
+	left := time.Until(notBefore)
+	if left <= 0 {
+		return 0, false
+	}
+	return left, true
+}
+
+// forget resets the backoff of a key
+func (r *retryTracker) forget(key string) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.limiter.Forget(key)
+	delete(r.notBefore, key)
+	delete(r.authFailures, key)
+}
+
+// authFailed records whether a failure is a ssh authentication failure and
+// returns the number of such failures in a row
+func (r *retryTracker) authFailed(key string, failed bool) int {
+	r.Lock()
+	defer r.Unlock()
+
+	if !failed {
+		delete(r.authFailures, key)
+		return 0
+	}
+	r.authFailures[key]++
+	return r.authFailures[key]
+}
@@ -0,0 +1,112 @@
+package instance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	controller "github.com/shovanmaity/spotcluster/controller/common"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHandleError(t *testing.T) {
+	authErr := errors.New("ssh: handshake failed: ssh: unable to authenticate, " +
+		"attempted methods [none publickey], no supported methods remain")
+	refusedErr := errors.New("dial tcp 203.0.113.10:22: connect: connection refused")
+
+	repeat := func(err error, n int) []error {
+		errs := []error{}
+		for i := 0; i < n; i++ {
+			errs = append(errs, err)
+		}
+		return errs
+	}
+
+	tests := map[string]struct {
+		errs       []error
+		created    time.Time
+		wantFailed bool
+		wantReason string
+	}{
+		"auth failure while the vm is booting": {
+			errs: []error{authErr},
+		},
+		"auth failures interrupted by another error": {
+			errs: append(append(repeat(authErr, controller.AuthFailureLimit-1), refusedErr),
+				repeat(authErr, controller.AuthFailureLimit-1)...),
+		},
+		"repeated auth failures": {
+			errs:       repeat(authErr, controller.AuthFailureLimit),
+			wantFailed: true,
+			wantReason: controller.ReasonInvalidConfig,
+		},
+		"auth failure after the registration timeout": {
+			errs:       []error{authErr},
+			created:    time.Now().Add(-2 * controller.DefaultNodeRegistrationTimeout),
+			wantFailed: true,
+			wantReason: controller.ReasonNodeRegistrationTimeout,
+		},
+		"invalid config": {
+			errs: []error{provider.NewError(provider.ErrorInvalidConfig, 0,
+				errors.New("image is not found"))},
+			wantFailed: true,
+			wantReason: controller.ReasonInvalidConfig,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			created := test.created
+			if created.IsZero() {
+				created = time.Now()
+			}
+			pool := &spotcluster.Pool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "pool",
+					Generation: 1,
+				},
+			}
+			instance := &spotcluster.Instance{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "instance",
+					CreationTimestamp: metav1.NewTime(created),
+				},
+			}
+			clientset := newTestClientset(pool, instance)
+
+			c, err := NewWithClientsets(Options{}, kubefake.NewSimpleClientset(), clientset)
+			if err != nil {
+				t.Fatalf("error creating controller: %s", err)
+			}
+
+			for _, err := range test.errs {
+				if instance.Status.InstanceStatus == controller.InstanceStatusFailed {
+					t.Fatalf("expected instance to be failed after the last error, "+
+						"got failed after %d attempts", instance.Status.Attempts)
+				}
+				c.handleError(pool, instance, spotcluster.InstanceBootstrapped,
+					controller.ReasonBootstrapFailed, err)
+			}
+
+			got, err := clientset.SpotclusterV1alpha1().
+				Instances().
+				Get(context.TODO(), instance.GetName(), metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting instance: %s", err)
+			}
+			failed := got.Status.InstanceStatus == controller.InstanceStatusFailed
+			if failed != test.wantFailed {
+				t.Fatalf("expected failed %t, got %t: %s", test.wantFailed, failed,
+					got.Status.FailureMessage)
+			}
+			if got.Status.FailureReason != test.wantReason {
+				t.Fatalf("expected failure reason %q, got %q",
+					test.wantReason, got.Status.FailureReason)
+			}
+		})
+	}
+}
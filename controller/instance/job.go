@@ -0,0 +1,138 @@
+package instance
+
+import (
+	"context"
+	"sync"
+
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+// jobFunc is the work done by a job. It must stop when the context is cancelled.
+type jobFunc func(ctx context.Context) (*spotcluster.Instance, *provider.BootstrapResult, error)
+
+// jobResult is the outcome of a finished job
+type jobResult struct {
+	instance  *spotcluster.Instance
+	bootstrap *provider.BootstrapResult
+	err       error
+}
+
+type job struct {
+	cancel context.CancelFunc
+	result *jobResult
+}
+
+// jobManager runs long running work of instances, like bootstrap over ssh,
+// in the background so that the workers are not blocked. Number of jobs
+// running at a time is limited globally and per pool. Once a job finishes
+// the instance is added back to the workqueue where the result is picked.
+type jobManager struct {
+	sync.Mutex
+	jobs      map[string]*job
+	global    chan struct{}
+	pools     map[string]chan struct{}
+	poolLimit int
+	notify    func(key string)
+}
+
+func newJobManager(globalLimit, poolLimit int, notify func(key string)) *jobManager {
+	return &jobManager{
+		jobs:      make(map[string]*job),
+		global:    make(chan struct{}, globalLimit),
+		pools:     make(map[string]chan struct{}),
+		poolLimit: poolLimit,
+		notify:    notify,
+	}
+}
+
+// start starts a job for a key if there is no job for that key
+func (m *jobManager) start(key, pool string, run jobFunc) {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, ok := m.jobs[key]; ok {
+		return
+	}
+
+	poolSlots, ok := m.pools[pool]
+	if !ok {
+		poolSlots = make(chan struct{}, m.poolLimit)
+		m.pools[pool] = poolSlots
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		cancel: cancel,
+	}
+	m.jobs[key] = j
+
+	go func() {
+		instance, bootstrap, err := func() (*spotcluster.Instance,
+			*provider.BootstrapResult, error) {
+			// Wait for a free slot in the pool and then globally.
+			select {
+			case poolSlots <- struct{}{}:
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			}
+			defer func() { <-poolSlots }()
+
+			select {
+			case m.global <- struct{}{}:
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			}
+			defer func() { <-m.global }()
+
+			return run(ctx)
+		}()
+
+		m.Lock()
+		j.result = &jobResult{
+			instance:  instance,
+			bootstrap: bootstrap,
+			err:       err,
+		}
+		cancelled := m.jobs[key] != j
+		m.Unlock()
+
+		if !cancelled {
+			m.notify(key)
+		}
+	}()
+}
+
+// result returns the result of a finished job and forgets that job.
+// It returns true if a job is still running for the key.
+func (m *jobManager) result(key string) (*jobResult, bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	j, ok := m.jobs[key]
+	if !ok {
+		return nil, false
+	}
+
+	if j.result == nil {
+		return nil, true
+	}
+
+	delete(m.jobs, key)
+	j.cancel()
+	return j.result, false
+}
+
+// cancel cancels the job of a key if there is any
+func (m *jobManager) cancel(key string) {
+	m.Lock()
+	defer m.Unlock()
+
+	j, ok := m.jobs[key]
+	if !ok {
+		return
+	}
+
+	delete(m.jobs, key)
+	j.cancel()
+}
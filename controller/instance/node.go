@@ -0,0 +1,65 @@
+package instance
+
+import (
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	providerIDIndex = "providerID"
+)
+
+// providerIDIndexFunc indexes nodes by their provider id
+func providerIDIndexFunc(obj interface{}) ([]string, error) {
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		return nil, errors.Errorf("expected node object but got %T", obj)
+	}
+
+	if node.Spec.ProviderID == "" {
+		return []string{}, nil
+	}
+	return []string{node.Spec.ProviderID}, nil
+}
+
+// getNode returns the node of an instance. Node is matched by provider id,
+// nodes which were registered without provider id are matched by name.
+func (c *Controller) getNode(instance *spotcluster.Instance) (*corev1.Node, error) {
+	if instance.Spec.ProviderID != "" {
+		objs, err := c.nodeIndexer.ByIndex(providerIDIndex, instance.Spec.ProviderID)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(objs) > 1 {
+			return nil, errors.Errorf("got %d nodes for provider id %s",
+				len(objs), instance.Spec.ProviderID)
+		}
+
+		if len(objs) == 1 {
+			node, ok := objs[0].(*corev1.Node)
+			if !ok {
+				return nil, errors.Errorf("expected node object but got %T", objs[0])
+			}
+			return node, nil
+		}
+	}
+
+	name := instance.Spec.NodeName
+	if name == "" {
+		name = instance.GetName()
+	}
+
+	node, err := c.nodeLister.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Node with the same name belongs to some other vm.
+	if instance.Spec.ProviderID != "" && node.Spec.ProviderID != "" {
+		return nil, k8serror.NewNotFound(corev1.Resource("nodes"), name)
+	}
+	return node, nil
+}
@@ -0,0 +1,116 @@
+package instance
+
+import (
+	"context"
+	"time"
+
+	controller "github.com/shovanmaity/spotcluster/controller/common"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// collectOrphans lists the vms created by spotcluster and deletes the ones
+// which are not referred by any instance once they are orphaned for longer
+// than the grace period. Orphans are only reported in report only mode.
+func (c *Controller) collectOrphans() {
+	pools, err := c.poolLister.List(labels.Everything())
+	if err != nil {
+		logrus.Errorf("error listing pools: %s", err)
+		return
+	}
+
+	instances, err := c.instanceLister.List(labels.Everything())
+	if err != nil {
+		logrus.Errorf("error listing instances: %s", err)
+		return
+	}
+
+	uids := make(map[string]bool)
+	for _, i := range instances {
+		uids[string(i.GetUID())] = true
+	}
+
+	// Pools sharing the same account see the same vms, list them once.
+	// Provider of a pool is created first so that a pool whose account can
+	// not be found, like one of a plugin which is down, is skipped.
+	accounts := make(map[string]*spotcluster.Pool)
+	for _, p := range pools {
+		_, registration, err := provider.ForPool(p)
+		if err != nil {
+			logrus.Errorf("error getting provider of pool %s: %s", p.GetName(), err)
+			continue
+		}
+		accounts[provider.ProviderName(p)+"/"+registration.Account(p)] = p
+	}
+
+	seen := make(map[string]bool)
+	for _, pool := range accounts {
+		p, _, err := provider.ForPool(pool)
+		if err != nil {
+			logrus.Errorf("error getting provider of pool %s: %s", pool.GetName(), err)
+			continue
+		}
+
+		vms, err := p.List(context.TODO(), provider.OwnerTag)
+		if err != nil {
+			logrus.Errorf("error listing vms of pool %s: %s", pool.GetName(), err)
+			continue
+		}
+
+		for _, vm := range vms {
+			if isOwned(vm.Tags, uids) {
+				continue
+			}
+
+			key := provider.ProviderName(pool) + "/" + vm.ID
+			seen[key] = true
+			orphanedAt, ok := c.orphans[key]
+			if !ok {
+				orphanedAt = time.Now()
+				c.orphans[key] = orphanedAt
+			}
+
+			deleter, canDelete := provider.AsIDDeleter(p)
+			orphanedFor := time.Since(orphanedAt)
+			if c.options.OrphanReportOnly || !canDelete ||
+				orphanedFor < c.options.OrphanGracePeriod {
+				logrus.Warnf("found orphaned vm %s (%s), orphaned for %s",
+					vm.Name, vm.ID, orphanedFor.Round(time.Second))
+				c.recorder.Eventf(pool, corev1.EventTypeWarning, controller.ReasonOrphanFound,
+					"Found orphaned VM %s (%s), orphaned for %s",
+					vm.Name, vm.ID, orphanedFor.Round(time.Second))
+				continue
+			}
+
+			if err := deleter.DeleteByID(context.TODO(), vm.ID); err != nil {
+				logrus.Errorf("error deleting orphaned vm %s (%s): %s", vm.Name, vm.ID, err)
+				continue
+			}
+
+			delete(c.orphans, key)
+			logrus.Infof("deleted orphaned vm %s (%s)", vm.Name, vm.ID)
+			c.recorder.Eventf(pool, corev1.EventTypeNormal, controller.ReasonOrphanDeleted,
+				"Deleted orphaned VM %s (%s)", vm.Name, vm.ID)
+		}
+	}
+
+	// Forget the vms which are not orphaned anymore.
+	for id := range c.orphans {
+		if !seen[id] {
+			delete(c.orphans, id)
+		}
+	}
+}
+
+// isOwned returns true if any of the tags is the uid of an instance
+func isOwned(tags []string, uids map[string]bool) bool {
+	for _, t := range tags {
+		if uids[t] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,306 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	controller "github.com/shovanmaity/spotcluster/controller/common"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// detectInterruptions asks the providers which support interruption
+// notices whether the vm of any instance is going to be reclaimed.
+func (c *Controller) detectInterruptions() {
+	instances, err := c.instanceLister.List(labels.Everything())
+	if err != nil {
+		logrus.Errorf("error listing instances: %s", err)
+		return
+	}
+
+	for _, i := range instances {
+		if i.DeletionTimestamp != nil || controller.IsTerminated(i) ||
+			controller.IsReclaiming(i) || !i.Spec.InstanceAvailable {
+			continue
+		}
+
+		pool, err := c.poolLister.Get(i.GetLabels()[controller.LabelClusterName])
+		if err != nil {
+			logrus.Errorf("error getting pool of instance %s: %s", i.GetName(), err)
+			continue
+		}
+
+		p, _, err := provider.ForPool(pool)
+		if err != nil {
+			logrus.Errorf("error getting provider of pool %s: %s", pool.GetName(), err)
+			continue
+		}
+
+		notifier, ok := provider.AsInterruptionNotifier(p)
+		if !ok {
+			continue
+		}
+
+		id, err := vmID(i)
+		if err != nil {
+			logrus.Errorf("error getting interruption notice of instance %s: %s", i.GetName(), err)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), controller.ReclaimSyncPeriod)
+		notice, err := notifier.Interruption(ctx, id)
+		cancel()
+		if err != nil {
+			logrus.Errorf("error getting interruption notice of instance %s: %s", i.GetName(), err)
+			continue
+		}
+
+		if notice != nil {
+			c.reclaim(i.DeepCopy(), controller.ReasonInterrupted,
+				fmt.Sprintf("VM is reclaimed at %s: %s",
+					notice.Time.Format(time.RFC3339), notice.Reason))
+		}
+	}
+}
+
+// checkReclaimed looks up the vm of an instance at the provider and starts
+// the reclaim if the vm is gone or stopped. It returns true if the instance
+// is being reclaimed.
+func (c *Controller) checkReclaimed(pool *spotcluster.Pool,
+	instance *spotcluster.Instance) (*provider.InstanceConfig, bool) {
+	vm, found, err := getVM(pool, instance)
+	if err != nil {
+		logrus.Errorf("error getting vm of instance %s: %s", instance.GetName(), err)
+		return nil, false
+	}
+
+	if !found {
+		controller.SetInstanceCondition(instance, spotcluster.InstanceVMProvisioned,
+			corev1.ConditionFalse, controller.ReasonInstanceNotFound, "VM is not found")
+		c.reclaim(instance, controller.ReasonInstanceNotFound,
+			"vm with tag "+string(instance.GetUID())+" not found")
+		return nil, true
+	}
+
+	// A vm which has not been running yet is still booting.
+	if instance.Spec.InstanceReady && !vm.IsRunning {
+		controller.SetInstanceCondition(instance, spotcluster.InstanceVMRunning,
+			corev1.ConditionFalse, controller.ReasonInstanceNotRunning, "VM is "+vm.Status)
+		c.reclaim(instance, controller.ReasonInstanceNotRunning,
+			"vm "+vm.Name+" is "+vm.Status)
+		return nil, true
+	}
+	return vm, false
+}
+
+// reclaim marks an instance as reclaiming and taints its node. Pool
+// controller creates a replacement as soon as the instance is reclaiming,
+// the node is drained meanwhile.
+func (c *Controller) reclaim(instance *spotcluster.Instance, reason, message string) {
+	c.startReclaim(instance, reason, message)
+
+	gotInstance, err := c.clientset.SpotclusterV1alpha1().
+		Instances().
+		Update(context.TODO(), instance, metav1.UpdateOptions{})
+	if err != nil {
+		logrus.Errorf("error marking instance %s as reclaiming: %s", instance.GetName(), err)
+		return
+	}
+
+	logrus.Warnf("instance %s is being reclaimed: %s: %s",
+		gotInstance.GetName(), reason, message)
+	c.recorder.Eventf(gotInstance, corev1.EventTypeWarning, controller.ReasonReclaiming,
+		"Instance is being reclaimed: %s", message)
+}
+
+// startReclaim taints the node of an instance and sets the reclaiming
+// status, instance is not updated.
+func (c *Controller) startReclaim(instance *spotcluster.Instance, reason, message string) {
+	instance.Status.InstanceStatus = controller.InstanceStatusReclaiming
+	instance.Status.FailureReason = reason
+	instance.Status.FailureMessage = message
+	controller.SetInstanceCondition(instance, spotcluster.InstanceDraining,
+		corev1.ConditionTrue, controller.ReasonReclaiming, message)
+
+	if err := c.taintNode(instance); err != nil {
+		logrus.Errorf("error tainting node of instance %s: %s", instance.GetName(), err)
+	}
+}
+
+// drain evicts the pods of a reclaimed node. Instance is marked as lost
+// once the node is drained or the drain timeout is over. Node which is not
+// reachable is not drained as its pods can not be stopped anyway.
+func (c *Controller) drain(instance *spotcluster.Instance) error {
+	node, err := c.getNode(instance)
+	if k8serror.IsNotFound(err) {
+		c.finishDrain(instance, controller.ReasonDrained, "Node is gone")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := c.taintNode(instance); err != nil {
+		logrus.Errorf("error tainting node %s: %s", node.GetName(), err)
+	}
+
+	if !isNodeReachable(node) {
+		c.finishDrain(instance, controller.ReasonDrained,
+			"Node is not reachable, pods are not evicted")
+		return nil
+	}
+
+	remaining, err := c.evictPods(node)
+	if err != nil {
+		logrus.Errorf("error draining node %s: %s", node.GetName(), err)
+	}
+
+	if err == nil && remaining == 0 {
+		c.finishDrain(instance, controller.ReasonDrained, "Node is drained")
+		return nil
+	}
+
+	started := time.Now()
+	if condition := controller.GetInstanceCondition(instance,
+		spotcluster.InstanceDraining); condition != nil {
+		started = condition.LastTransitionTime.Time
+	}
+	if time.Since(started) > controller.DrainTimeout {
+		c.finishDrain(instance, controller.ReasonDrainTimeout,
+			fmt.Sprintf("Node is not drained within %s, %d pods are left",
+				controller.DrainTimeout, remaining))
+		return nil
+	}
+
+	return &requeueError{
+		after: controller.DrainCheckPeriod,
+		err:   errors.Errorf("draining node %s, %d pods are left", node.GetName(), remaining),
+	}
+}
+
+// finishDrain marks a reclaimed instance as lost, its vm and node are
+// released afterwards.
+func (c *Controller) finishDrain(instance *spotcluster.Instance, reason, message string) {
+	eventType := corev1.EventTypeNormal
+	if reason != controller.ReasonDrained {
+		eventType = corev1.EventTypeWarning
+	}
+
+	controller.SetInstanceCondition(instance, spotcluster.InstanceDraining,
+		corev1.ConditionFalse, reason, message)
+	c.recorder.Event(instance, eventType, reason, message)
+	c.markLost(instance, instance.Status.FailureReason, instance.Status.FailureMessage)
+}
+
+// taintNode adds the reclaiming taint to the node of an instance
+func (c *Controller) taintNode(instance *spotcluster.Instance) error {
+	node, err := c.getNode(instance)
+	if k8serror.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == controller.TaintReclaiming {
+			return nil
+		}
+	}
+
+	now := metav1.Now()
+	node = node.DeepCopy()
+	node.Spec.Taints = append(node.Spec.Taints, corev1.Taint{
+		Key:       controller.TaintReclaiming,
+		Effect:    corev1.TaintEffectNoSchedule,
+		TimeAdded: &now,
+	})
+	_, err = c.kubeClientset.CoreV1().
+		Nodes().
+		Update(context.TODO(), node, metav1.UpdateOptions{})
+	return err
+}
+
+// evictPods evicts the pods of a node and returns the number of pods which
+// are still running on it. Daemonset and mirror pods are not evicted.
+func (c *Controller) evictPods(node *corev1.Node) (int, error) {
+	pods, err := c.kubeClientset.CoreV1().
+		Pods(metav1.NamespaceAll).
+		List(context.TODO(), metav1.ListOptions{
+			FieldSelector: "spec.nodeName=" + node.GetName(),
+		})
+	if err != nil {
+		return 0, err
+	}
+
+	remaining := 0
+	for _, pod := range pods.Items {
+		if !isEvictable(&pod) {
+			continue
+		}
+
+		remaining++
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		err := c.kubeClientset.PolicyV1beta1().
+			Evictions(pod.GetNamespace()).
+			Evict(context.TODO(), &policyv1beta1.Eviction{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      pod.GetName(),
+					Namespace: pod.GetNamespace(),
+				},
+			})
+		switch {
+		case err == nil:
+			logrus.Infof("evicted pod %s/%s from node %s",
+				pod.GetNamespace(), pod.GetName(), node.GetName())
+		case k8serror.IsNotFound(err):
+			remaining--
+		case k8serror.IsTooManyRequests(err):
+			// Pod disruption budget does not allow the eviction yet.
+			logrus.Warnf("eviction of pod %s/%s is not allowed yet: %s",
+				pod.GetNamespace(), pod.GetName(), err)
+		default:
+			logrus.Errorf("error evicting pod %s/%s: %s",
+				pod.GetNamespace(), pod.GetName(), err)
+		}
+	}
+	return remaining, nil
+}
+
+// isEvictable returns false for pods which are not evicted by a drain
+func isEvictable(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if _, ok := pod.GetAnnotations()[corev1.MirrorPodAnnotationKey]; ok {
+		return false
+	}
+	for _, owner := range pod.GetOwnerReferences() {
+		if owner.Kind == "DaemonSet" {
+			return false
+		}
+	}
+	return true
+}
+
+// isNodeReachable returns false if the kubelet of a node has stopped
+// posting its status.
+func isNodeReachable(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status != corev1.ConditionUnknown
+		}
+	}
+	return true
+}
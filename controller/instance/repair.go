@@ -0,0 +1,73 @@
+package instance
+
+import (
+	"sync"
+	"time"
+
+	controller "github.com/shovanmaity/spotcluster/controller/common"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+)
+
+// repairLimiter keeps the time of recent repairs of every pool so that the
+// number of repairs can be capped within a time window. History is kept in
+// memory, it starts empty when spot-manager restarts.
+type repairLimiter struct {
+	sync.Mutex
+	history map[string][]time.Time
+}
+
+func newRepairLimiter() *repairLimiter {
+	return &repairLimiter{
+		history: make(map[string][]time.Time),
+	}
+}
+
+// allow returns true if the pool has not reached the maximum number of
+// repairs within the window. A repair is charged only when it is recorded.
+func (r *repairLimiter) allow(pool string, max int, window time.Duration) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	now := time.Now()
+	recent := []time.Time{}
+	for _, t := range r.history[pool] {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+
+	r.history[pool] = recent
+	return len(recent) < max
+}
+
+// record charges a repair of the pool
+func (r *repairLimiter) record(pool string) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.history[pool] = append(r.history[pool], time.Now())
+}
+
+func isAutoRepairEnabled(pool *spotcluster.Pool) bool {
+	return pool != nil && pool.Spec.AutoRepair != nil && pool.Spec.AutoRepair.Enabled
+}
+
+// repairPolicy returns not ready threshold, maximum repairs and repair window
+// of a pool. Defaults are used for the values which are not set.
+func repairPolicy(pool *spotcluster.Pool) (time.Duration, int, time.Duration) {
+	threshold := controller.DefaultNotReadyThreshold
+	maxRepairs := controller.DefaultMaxRepairs
+	window := controller.DefaultRepairWindow
+
+	policy := pool.Spec.AutoRepair
+	if policy.NotReadyThreshold != nil && policy.NotReadyThreshold.Duration > 0 {
+		threshold = policy.NotReadyThreshold.Duration
+	}
+	if policy.MaxRepairs > 0 {
+		maxRepairs = policy.MaxRepairs
+	}
+	if policy.RepairWindow != nil && policy.RepairWindow.Duration > 0 {
+		window = policy.RepairWindow.Duration
+	}
+	return threshold, maxRepairs, window
+}
@@ -2,11 +2,14 @@ package instance
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 	controller "github.com/shovanmaity/spotcluster/controller/common"
 	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 	k8serror "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/runtime"
@@ -16,6 +19,8 @@ func (c *Controller) sync(key string) error {
 
 	instance, err := c.instanceLister.Get(key)
 	if k8serror.IsNotFound(err) {
+		c.jobs.cancel(key)
+		c.retries.forget(key)
 		runtime.HandleError(errors.Errorf("instance '%s' has been deleted", key))
 		return nil
 	}
@@ -36,16 +41,54 @@ func (c *Controller) sync(key string) error {
 
 	// If deletion timestamp is set then delete that instance
 	if cloneInstance.DeletionTimestamp != nil {
+		c.jobs.cancel(key)
+		c.retries.forget(key)
 		c.delete(cloneInstance, pool)
 		return nil
 	}
 
+	// Failed and lost instances are kept for debugging. Only the vm and the
+	// node are released, pool controller takes care of the replacement.
+	if controller.IsTerminated(cloneInstance) {
+		c.jobs.cancel(key)
+		c.retries.forget(key)
+		c.release(cloneInstance, pool)
+		return nil
+	}
+
+	// Node of a reclaimed instance is drained before the instance is lost.
+	if controller.IsReclaiming(cloneInstance) {
+		c.jobs.cancel(key)
+		return c.drain(cloneInstance)
+	}
+
+	// Actions asked for by an operator are run before anything else.
+	if action := cloneInstance.GetAnnotations()[controller.AnnotationAction]; action != "" {
+		return c.runAction(pool, cloneInstance, action)
+	}
+
 	// If node is available and instance is ready then update the node status.
 	if cloneInstance.Spec.NodeAvailable && cloneInstance.Spec.InstanceReady {
-		c.updateNodeStatus(cloneInstance)
+		c.updateNodeStatus(pool, cloneInstance)
 		return nil
 	}
 
+	// If node is not ready within the registration timeout then mark the
+	// instance as failed.
+	if isRegistrationTimedOut(pool, cloneInstance) {
+		c.markFailed(cloneInstance, controller.ReasonNodeRegistrationTimeout,
+			"node did not become ready within "+registrationTimeout(pool).String())
+		return nil
+	}
+
+	// Provisioning is not retried before the backoff of the last failure.
+	if after, ok := c.retries.wait(key); ok {
+		return &requeueError{
+			after: after,
+			err:   errors.Errorf("instance %s is backing off", key),
+		}
+	}
+
 	// If instance is not available then we need to create instance.
 	if !cloneInstance.Spec.InstanceAvailable ||
 		!cloneInstance.Spec.InstanceReady {
@@ -56,23 +99,90 @@ func (c *Controller) sync(key string) error {
 	return c.provisionWorker(pool, cloneInstance)
 }
 
-func (c *Controller) updateNodeStatus(instance *spotcluster.Instance) {
-	node, err := c.kubeClientset.CoreV1().
-		Nodes().
-		Get(context.TODO(), instance.GetName(), metav1.GetOptions{})
-	if err != nil {
+func (c *Controller) updateNodeStatus(pool *spotcluster.Pool,
+	instance *spotcluster.Instance) {
+	node, err := c.getNode(instance)
+	if err != nil && !k8serror.IsNotFound(err) {
 		logrus.Errorf("error getting node %s: %s", instance.GetName(), err)
 		return
 	}
 
-	nodeReady := isNodeReady(node)
+	// A node which is not found is treated as a not ready node.
+	if err != nil {
+		node = nil
+	}
 
-	if instance.Spec.NodeReady && nodeReady {
+	// Node which is gone or has lost its heartbeat is most likely a
+	// reclaimed spot vm, provider is asked right away.
+	if (node == nil || !isNodeReachable(node)) && pool != nil {
+		if _, reclaimed := c.checkReclaimed(pool, instance); reclaimed {
+			return
+		}
+	}
+
+	nodeReady := isNodeReady(node)
+	conditionsReady := controller.IsInstanceConditionTrue(instance, spotcluster.InstanceNodeRegistered) &&
+		controller.IsInstanceConditionTrue(instance, spotcluster.InstanceNodeReady)
+	setNodeConditions(instance, node)
+
+	if nodeReady {
+		if instance.Spec.NodeReady && instance.Status.NodeNotReadySince == nil && conditionsReady {
+			return
+		}
+
+		instance.Spec.NodeReady = true
+		instance.Status.NodeNotReadySince = nil
+		clearRepairLimited(instance)
+		gotInstance, err := c.clientset.SpotclusterV1alpha1().
+			Instances().
+			Update(context.TODO(), instance, metav1.UpdateOptions{})
+		if err != nil {
+			logrus.Errorf("error updating instance %s with node details: %s", instance.GetName(), err)
+			return
+		}
+
+		logrus.Infof("node '%s' is ready: updated instance %s with node status",
+			instance.GetName(), gotInstance.GetName())
+		c.recorder.Event(gotInstance, corev1.EventTypeNormal, controller.ReasonNodeReady,
+			"Node is ready")
 		return
 	}
 
-	instance.Spec.NodeReady = nodeReady
-	instance.Spec.InstanceReady = false
+	if instance.Status.NodeNotReadySince == nil {
+		now := metav1.Now()
+		instance.Status.NodeNotReadySince = &now
+	}
+	instance.Spec.NodeReady = false
+
+	if !isAutoRepairEnabled(pool) {
+		// Without auto repair the worker is provisioned again.
+		instance.Spec.InstanceReady = false
+	} else {
+		threshold, maxRepairs, window := repairPolicy(pool)
+		notReadyFor := time.Since(instance.Status.NodeNotReadySince.Time)
+		if notReadyFor > threshold {
+			if c.repairs.allow(pool.GetName(), maxRepairs, window) {
+				err := c.markFailed(instance, controller.ReasonNodeNotReady,
+					"node "+instance.GetName()+" is not ready for "+
+						notReadyFor.Round(time.Second).String())
+				if err == nil {
+					c.repairs.record(pool.GetName())
+				}
+				return
+			}
+			// Event is emitted only when the repair is limited for the
+			// first time, the condition tells the later syncs apart.
+			if !controller.IsInstanceConditionTrue(instance, spotcluster.InstanceRepairLimited) {
+				logrus.Warnf("not repairing instance %s: pool %s reached %d repairs within %s",
+					instance.GetName(), pool.GetName(), maxRepairs, window)
+				c.recorder.Eventf(instance, corev1.EventTypeWarning, controller.ReasonRepairLimited,
+					"Not repairing, pool reached %d repairs within %s", maxRepairs, window)
+			}
+			controller.SetInstanceCondition(instance, spotcluster.InstanceRepairLimited,
+				corev1.ConditionTrue, controller.ReasonRepairLimited,
+				fmt.Sprintf("Pool reached %d repairs within %s", maxRepairs, window))
+		}
+	}
 
 	gotInstance, err := c.clientset.SpotclusterV1alpha1().
 		Instances().
@@ -83,6 +193,8 @@ func (c *Controller) updateNodeStatus(instance *spotcluster.Instance) {
 	}
 
 	logrus.Infof("node '%s' is not ready: updated instance %s with node status",
-		node.GetName(), gotInstance.GetName())
+		instance.GetName(), gotInstance.GetName())
+	c.recorder.Event(gotInstance, corev1.EventTypeWarning, controller.ReasonNodeNotReady,
+		"Node is not ready")
 	return
 }
@@ -0,0 +1,85 @@
+package instance
+
+import (
+	"context"
+
+	controller "github.com/shovanmaity/spotcluster/controller/common"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// markFailed marks an instance as failed and keeps the reason of failure
+// in the status.
+func (c *Controller) markFailed(instance *spotcluster.Instance, reason, message string) error {
+	return c.terminate(instance, controller.InstanceStatusFailed, reason, message)
+}
+
+// markLost marks an instance as lost when its vm is gone or stopped
+// without spotcluster asking for it.
+func (c *Controller) markLost(instance *spotcluster.Instance, reason, message string) error {
+	return c.terminate(instance, controller.InstanceStatusLost, reason, message)
+}
+
+func (c *Controller) terminate(instance *spotcluster.Instance,
+	status, reason, message string) error {
+	instance.Status.InstanceStatus = status
+	instance.Status.FailureReason = reason
+	instance.Status.FailureMessage = message
+
+	gotInstance, err := c.clientset.SpotclusterV1alpha1().
+		Instances().
+		Update(context.TODO(), instance, metav1.UpdateOptions{})
+	if err != nil {
+		logrus.Errorf("error marking instance %s as %s: %s", instance.GetName(), status, err)
+		return err
+	}
+
+	logrus.Warnf("instance %s marked as %s: %s: %s",
+		gotInstance.GetName(), status, reason, message)
+	c.recorder.Eventf(gotInstance, corev1.EventTypeWarning, reason,
+		"Instance is %s: %s", status, message)
+	return nil
+}
+
+// release deletes the vm and the node of a failed or lost instance.
+// Instance object is kept so that the reason of failure can be inspected.
+func (c *Controller) release(instance *spotcluster.Instance, pool *spotcluster.Pool) {
+	if !instance.Spec.InstanceAvailable {
+		return
+	}
+
+	if err := c.deleteVM(instance, pool); err != nil {
+		logrus.Errorf("unable to release instance %s: %s", instance.GetName(), err)
+		c.recorder.Event(instance, corev1.EventTypeWarning, controller.ReasonDeleteFailed,
+			err.Error())
+		return
+	}
+
+	if err := c.deleteNode(instance); err != nil {
+		logrus.Errorf("unable to release instance %s: %s", instance.GetName(), err)
+		c.recorder.Event(instance, corev1.EventTypeWarning, controller.ReasonDeleteFailed,
+			err.Error())
+		return
+	}
+
+	instance.Spec.InstanceAvailable = false
+	instance.Spec.InstanceReady = false
+	instance.Spec.NodeAvailable = false
+	instance.Spec.NodeReady = false
+	resetVMConditions(instance, controller.ReasonReleased, "VM and node are deleted")
+
+	gotInstance, err := c.clientset.SpotclusterV1alpha1().
+		Instances().
+		Update(context.TODO(), instance, metav1.UpdateOptions{})
+	if err != nil {
+		logrus.Errorf("error updating instance %s: %s", instance.GetName(), err)
+		return
+	}
+
+	logrus.Infof("released vm and node of %s instance %s",
+		gotInstance.Status.InstanceStatus, gotInstance.GetName())
+	c.recorder.Event(gotInstance, corev1.EventTypeNormal, controller.ReasonReleased,
+		"Deleted VM and node, instance is kept for debugging")
+}
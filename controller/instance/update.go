@@ -3,9 +3,12 @@ package instance
 import (
 	"context"
 
+	controller "github.com/shovanmaity/spotcluster/controller/common"
 	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
-	"github.com/shovanmaity/spotcluster/provider/digitalocean"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -15,53 +18,166 @@ func (c *Controller) update() {
 
 func (c *Controller) provisionInstance(pool *spotcluster.Pool,
 	instance *spotcluster.Instance) error {
-	i, err := digitalocean.ProvisionInstance(pool, instance)
+	wasAvailable := instance.Spec.InstanceAvailable
+	wasReady := instance.Spec.InstanceReady
+
+	i, err := provisionVM(pool, instance)
 	if err != nil {
 		logrus.Errorf("error provisioning instance: %s", err)
-		return nil
+		c.recorder.Event(instance, corev1.EventTypeWarning,
+			controller.ReasonProvisionFailed, err.Error())
+		conditionType := spotcluster.InstanceVMProvisioned
+		if wasAvailable {
+			conditionType = spotcluster.InstanceVMRunning
+		}
+		return c.handleError(pool, instance, conditionType,
+			controller.ReasonProvisionFailed, err)
 	}
 
+	setVMConditions(i)
+	if !wasAvailable {
+		controller.SetInstanceCondition(i, spotcluster.InstanceBootstrapped,
+			corev1.ConditionFalse, controller.ReasonPending, "Worker is not provisioned yet")
+	}
+	i.Status.Attempts = 0
+
 	gotInstance, err := c.clientset.SpotclusterV1alpha1().
 		Instances().
 		Update(context.TODO(), i, metav1.UpdateOptions{})
 	if err != nil {
 		logrus.Errorf("error updating instance %s: %s", instance.GetName(), err)
-		return nil
+		return c.handleError(pool, instance, spotcluster.InstanceVMProvisioned,
+			controller.ReasonProvisionFailed, err)
 	}
 
+	c.retries.forget(gotInstance.GetName())
 	logrus.WithField("operation", "create/get").
 		Infof("updated instance %s with instance details", gotInstance.GetName())
+	if !wasAvailable && gotInstance.Spec.InstanceAvailable {
+		c.recorder.Eventf(gotInstance, corev1.EventTypeNormal, controller.ReasonVMCreated,
+			"VM %s (%s) is provisioned", gotInstance.Spec.InstanceName,
+			gotInstance.Spec.InstanceID)
+	}
+	if !wasReady && gotInstance.Spec.InstanceReady {
+		c.recorder.Eventf(gotInstance, corev1.EventTypeNormal, controller.ReasonVMRunning,
+			"VM %s is running at %s", gotInstance.Spec.InstanceName, gotInstance.Spec.ExternalIP)
+	}
 	return nil
 }
 
 func (c *Controller) provisionWorker(pool *spotcluster.Pool,
 	instance *spotcluster.Instance) error {
-	i, err := digitalocean.ProvisionWorker(pool, instance)
-	if err != nil {
-		logrus.Errorf("error provisioning worker on node %s: %s", instance.GetName(), err)
+	// Worker is provisioned by a background job, instance is synced
+	// again once the job is finished.
+	result, running := c.jobs.result(instance.GetName())
+	if running {
 		return nil
 	}
 
-	node, err := c.kubeClientset.CoreV1().
-		Nodes().
-		Get(context.TODO(), instance.GetName(), metav1.GetOptions{})
-	if err != nil {
-		logrus.Errorf("error getting node %s: %s", instance.GetName(), err)
+	// Worker of the current vm is already provisioned, only its node is
+	// waited for. Bootstrapped condition is cleared when the vm is created,
+	// rebuilt or bootstrapped again and when the bootstrap fails.
+	if result == nil && controller.IsInstanceConditionTrue(instance, spotcluster.InstanceBootstrapped) {
+		return c.registerNode(pool, instance)
+	}
+
+	if result == nil {
+		_, registration, err := provider.ForPool(pool)
+		if err != nil {
+			return c.handleError(pool, instance, spotcluster.InstanceBootstrapped,
+				controller.ReasonBootstrapFailed, err)
+		}
+
+		provision := provider.ProvisionWorker
+		// Adopted instances already run a worker, it is not installed again.
+		if instance.GetAnnotations()[controller.AnnotationAdoptedID] != "" {
+			provision = provider.AdoptWorker
+		}
+
+		copyInstance := instance.DeepCopy()
+		c.jobs.start(instance.GetName(), instance.GetLabels()[controller.LabelClusterName],
+			func(ctx context.Context) (*spotcluster.Instance, *provider.BootstrapResult, error) {
+				return provision(ctx, registration.SSHUser(pool), pool, copyInstance)
+			})
+		logrus.Infof("started provisioning worker on node %s", instance.GetName())
+		c.recorder.Event(instance, corev1.EventTypeNormal, controller.ReasonBootstrapStarted,
+			"Started provisioning worker")
 		return nil
 	}
 
-	i.Spec.NodeName = node.GetName()
+	if result.bootstrap != nil {
+		c.recordBootstrap(instance, result.bootstrap)
+	}
+
+	if result.err != nil {
+		logrus.Errorf("error provisioning worker on node %s: %s", instance.GetName(), result.err)
+		c.recorder.Event(instance, corev1.EventTypeWarning, controller.ReasonBootstrapFailed,
+			result.err.Error())
+		// Bootstrap status of the failed attempt is kept along with the error.
+		return c.handleError(pool, instance, spotcluster.InstanceBootstrapped,
+			controller.ReasonBootstrapFailed, result.err)
+	}
+
+	instance.Spec.NodePassword = result.instance.Spec.NodePassword
+	controller.SetInstanceCondition(instance, spotcluster.InstanceBootstrapped,
+		corev1.ConditionTrue, controller.ReasonBootstrapped, "Worker is provisioned")
+	instance.Status.Attempts = 0
+	c.recorder.Event(instance, corev1.EventTypeNormal, controller.ReasonBootstrapped,
+		"Provisioned worker")
+	return c.registerNode(pool, instance)
+}
+
+// registerNode updates an instance whose worker is provisioned with its
+// node
+func (c *Controller) registerNode(pool *spotcluster.Pool,
+	instance *spotcluster.Instance) error {
+	i := instance
+	node, err := c.getNode(i)
+	if err != nil && !k8serror.IsNotFound(err) {
+		logrus.Errorf("error getting node %s: %s", instance.GetName(), err)
+		return c.handleError(pool, instance, spotcluster.InstanceNodeRegistered,
+			controller.ReasonNodeNotFound, err)
+	}
+
+	// Node may not be registered yet, instance is updated anyway to keep
+	// the bootstrap details.
+	if err != nil {
+		logrus.Infof("node of instance %s is not registered yet", instance.GetName())
+		node = nil
+	} else {
+		i.Spec.NodeName = node.GetName()
+	}
+
+	setNodeConditions(i, node)
 	i.Spec.NodeAvailable = isNodeReady(node)
 	i.Spec.NodeReady = isNodeReady(node)
+	joined := false
+	if i.Spec.NodeReady && i.Status.NodeRegisteredAt == nil {
+		now := metav1.Now()
+		i.Status.NodeRegisteredAt = &now
+		joined = true
+	}
+
+	if !i.Spec.NodeReady && isRegistrationTimedOut(pool, i) {
+		c.markFailed(i, controller.ReasonNodeRegistrationTimeout,
+			"node of instance "+i.GetName()+" is not ready")
+		return nil
+	}
 
 	gotInstance, err := c.clientset.SpotclusterV1alpha1().
 		Instances().
 		Update(context.TODO(), i, metav1.UpdateOptions{})
 	if err != nil {
 		logrus.Errorf("error updating instance %s: %s", instance.GetName(), err)
-		return nil
+		return c.handleError(pool, instance, spotcluster.InstanceNodeReady,
+			controller.ReasonNodeNotReady, err)
 	}
 
+	c.retries.forget(gotInstance.GetName())
 	logrus.Infof("successfully provisioned worker node %s", gotInstance.GetName())
+	if joined {
+		c.recorder.Eventf(gotInstance, corev1.EventTypeNormal, controller.ReasonNodeJoined,
+			"Node %s joined the cluster", gotInstance.Spec.NodeName)
+	}
 	return nil
 }
@@ -1,6 +1,10 @@
 package instance
 
 import (
+	"time"
+
+	controller "github.com/shovanmaity/spotcluster/controller/common"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -10,9 +14,41 @@ func isNodeReady(node *corev1.Node) bool {
 	}
 	taints := node.Spec.Taints
 	for _, t := range taints {
-		if t.Effect == "NoExecute" {
+		if t.Effect == corev1.TaintEffectNoExecute {
 			return false
 		}
 	}
-	return true
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// registrationTimeout returns node registration timeout of a pool
+func registrationTimeout(pool *spotcluster.Pool) time.Duration {
+	if pool == nil || pool.Spec.NodeRegistrationTimeout == nil ||
+		pool.Spec.NodeRegistrationTimeout.Duration <= 0 {
+		return controller.DefaultNodeRegistrationTimeout
+	}
+	return pool.Spec.NodeRegistrationTimeout.Duration
+}
+
+// isRegistrationTimedOut returns true if the instance has not produced a
+// ready node within the node registration timeout of the pool. Timeout is
+// counted from the last rebuild or rebootstrap if there is any.
+func isRegistrationTimedOut(pool *spotcluster.Pool,
+	instance *spotcluster.Instance) bool {
+	if instance.Status.NodeRegisteredAt != nil {
+		return false
+	}
+
+	started := instance.GetCreationTimestamp().Time
+	action := instance.Status.LastAction
+	if action != nil && action.Time != nil && action.Result == controller.ActionSucceeded &&
+		(action.Name == controller.ActionRebuild || action.Name == controller.ActionRebootstrap) {
+		started = action.Time.Time
+	}
+	return time.Since(started) > registrationTimeout(pool)
 }
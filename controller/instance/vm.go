@@ -0,0 +1,151 @@
+package instance
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	controller "github.com/shovanmaity/spotcluster/controller/common"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+// provisionVM creates the vm of an instance if it is not present and
+// populates the details of the vm in the instance. It is called until the
+// vm is running.
+func provisionVM(pool *spotcluster.Pool,
+	instance *spotcluster.Instance) (*spotcluster.Instance, error) {
+	if instance == nil {
+		return nil, errors.New("got nil instance object")
+	}
+
+	p, registration, err := provider.ForPool(pool)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.TODO()
+	vm, found, err := p.Get(ctx, string(instance.GetUID()))
+	if err != nil {
+		return nil, err
+	}
+
+	// Adopted vm is tagged with the instance uid before it is used.
+	adoptedID := instance.GetAnnotations()[controller.AnnotationAdoptedID]
+	if !found && adoptedID != "" {
+		adopter, ok := provider.AsAdopter(p)
+		if !ok {
+			return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+				errors.Errorf("provider %s does not support adoption",
+					provider.ProviderName(pool)))
+		}
+
+		err := adopter.Tag(ctx, adoptedID, string(instance.GetUID()), provider.OwnerTag)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to tag adopted vm %s", adoptedID)
+		}
+
+		vm, found, err = p.Get(ctx, string(instance.GetUID()))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !found {
+		config := registration.Template(pool)
+		config.Name = instance.GetName()
+		config.Tags = []string{string(instance.GetUID()), provider.OwnerTag}
+		config.SSHFingerprint = pool.Spec.SSHFingerprint
+		vm, err = p.Create(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	populateInstance(instance, provider.ProviderName(pool), registration, *vm)
+	return instance, nil
+}
+
+// populateInstance populates instance details for a given vm
+func populateInstance(instance *spotcluster.Instance, name string,
+	registration *provider.Registration, vm provider.InstanceConfig) {
+	instance.Spec.Provider = name
+	instance.Spec.InstanceName = vm.Name
+	instance.Spec.ProviderID = registration.ProviderIDPrefix + vm.ID
+	instance.Spec.RemoteAddress = func() string {
+		if vm.SSHAddress != "" {
+			return vm.SSHAddress
+		}
+		if vm.ExteralIP != "" {
+			return vm.ExteralIP + ":22"
+		}
+		return ""
+	}()
+	instance.Spec.ExternalIP = vm.ExteralIP
+	instance.Spec.InternalIP = vm.InternalIP
+	instance.Spec.InstanceAvailable = true
+	instance.Spec.InstanceReady = vm.IsRunning
+	instance.Spec.NodeAvailable = false
+	instance.Finalizers = func() []string {
+		return []string{controller.InstanceProtectionFinalizer}
+	}()
+	instance.Spec.InstanceID = vm.ID
+	instance.Labels[controller.LabelInstanceID] = controller.InstanceIDLabel(vm.ID)
+}
+
+// getVM returns the vm of an instance if it is found
+func getVM(pool *spotcluster.Pool,
+	instance *spotcluster.Instance) (*provider.InstanceConfig, bool, error) {
+	p, _, err := provider.ForPool(pool)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return p.Get(context.TODO(), string(instance.GetUID()))
+}
+
+// rebootVM power cycles the vm of an instance
+func rebootVM(pool *spotcluster.Pool, instance *spotcluster.Instance) error {
+	p, _, err := provider.ForPool(pool)
+	if err != nil {
+		return err
+	}
+
+	id, err := vmID(instance)
+	if err != nil {
+		return err
+	}
+	return p.Reboot(context.TODO(), id)
+}
+
+// rebuildVM reimages the vm of an instance with the image of the pool
+func rebuildVM(pool *spotcluster.Pool, instance *spotcluster.Instance) error {
+	p, registration, err := provider.ForPool(pool)
+	if err != nil {
+		return err
+	}
+
+	rebuilder, ok := provider.AsRebuilder(p)
+	if !ok {
+		return errors.Errorf("provider %s does not support rebuild", provider.ProviderName(pool))
+	}
+
+	id, err := vmID(instance)
+	if err != nil {
+		return err
+	}
+	return rebuilder.Rebuild(context.TODO(), id, registration.Template(pool).Image)
+}
+
+// vmID returns the provider id of the vm of an instance
+func vmID(instance *spotcluster.Instance) (string, error) {
+	id := instance.Spec.InstanceID
+	// Instances provisioned before the id was kept in the spec only have
+	// the id in the label.
+	if id == "" {
+		id = instance.GetLabels()[controller.LabelInstanceID]
+	}
+	if id == "" {
+		return "", errors.Errorf("instance %s has no vm", instance.GetName())
+	}
+	return id, nil
+}
@@ -0,0 +1,73 @@
+package instance
+
+import (
+	"strings"
+	"testing"
+
+	controller "github.com/shovanmaity/spotcluster/controller/common"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestPopulateInstance(t *testing.T) {
+	tests := map[string]struct {
+		id string
+	}{
+		"numeric id": {
+			id: "123456789",
+		},
+		"id with slashes": {
+			id: "group/zone/name",
+		},
+		"gcp vm id": {
+			id: "my-project/us-central1-a/pool-abcde",
+		},
+		"azure vm id": {
+			id: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/spotcluster" +
+				"/providers/Microsoft.Compute/virtualMachines/pool-abcde",
+		},
+		"container id": {
+			id: "4f66ad9a0b2e",
+		},
+		"static host id": {
+			id: "203.0.113.10:22",
+		},
+		"id longer than a label value": {
+			id: strings.Repeat("a", validation.LabelValueMaxLength+1),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			instance := &spotcluster.Instance{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "instance",
+					Labels: map[string]string{},
+				},
+			}
+
+			populateInstance(instance, provider.Fake,
+				&provider.Registration{ProviderIDPrefix: provider.FakeProviderID},
+				provider.InstanceConfig{ID: test.id, Name: "instance"})
+
+			label := instance.GetLabels()[controller.LabelInstanceID]
+			if errs := validation.IsValidLabelValue(label); len(errs) != 0 {
+				t.Fatalf("expected a valid label value for id %s, got %s: %s",
+					test.id, label, strings.Join(errs, "; "))
+			}
+			if label != controller.InstanceIDLabel(test.id) {
+				t.Fatalf("expected label %s, got %s", controller.InstanceIDLabel(test.id), label)
+			}
+
+			id, err := vmID(instance)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			if id != test.id {
+				t.Fatalf("expected vm id %s, got %s", test.id, id)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package pool
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	controller "github.com/shovanmaity/spotcluster/controller/common"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// invalidNameChars are the characters which are not allowed in the name of
+// an instance
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// adopt takes the droplets listed in the adopt annotation of a pool into
+// the pool. Adoption is recorded in the pool before any instance is created
+// so that a failed sync neither loses nor repeats it, the adopt annotation
+// is cleared in the same update. Instances of the recorded droplets are
+// created on the next syncs. Adopted droplets are counted as replicas, they
+// are not adopted if the replicas of the pool have no room for them. It
+// returns true if the pool has been updated or an adoption is pending.
+func (c *Controller) adopt(pool *spotcluster.Pool) (bool, error) {
+	adoptions := parseAdoptions(pool.GetAnnotations()[controller.AnnotationAdopting])
+	if len(adoptions) != 0 {
+		return true, c.createAdopted(pool, adoptions)
+	}
+
+	refs := []string{}
+	for _, ref := range strings.Split(pool.GetAnnotations()[controller.AnnotationAdopt], ",") {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+
+	if len(refs) == 0 {
+		return false, nil
+	}
+
+	p, _, err := provider.ForPool(pool)
+	if err != nil {
+		return false, err
+	}
+
+	adopter, ok := provider.AsAdopter(p)
+	if !ok {
+		return false, errors.Errorf("provider %s does not support adoption",
+			provider.ProviderName(pool))
+	}
+
+	droplets, err := adopter.Find(context.TODO(), refs)
+	if err != nil {
+		return false, err
+	}
+
+	instanceList, err := c.clientset.SpotclusterV1alpha1().
+		Instances().
+		List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	managed := map[string]bool{}
+	names := map[string]bool{}
+	replicas := 0
+	for _, i := range instanceList.Items {
+		managed[i.Spec.InstanceID] = true
+		// Adopted vm is not recorded in the spec until its instance is
+		// provisioned.
+		if id := i.GetAnnotations()[controller.AnnotationAdoptedID]; id != "" {
+			managed[id] = true
+		}
+		names[i.GetName()] = true
+		if i.GetLabels()[controller.LabelClusterName] == pool.GetName() &&
+			!controller.IsTerminated(&i) && !controller.IsReclaiming(&i) {
+			replicas++
+		}
+	}
+
+	adoptions = map[string]string{}
+	for _, droplet := range droplets {
+		// Droplet is already managed by an instance.
+		if managed[droplet.ID] {
+			continue
+		}
+
+		name := instanceName(pool, droplet)
+		if names[name] {
+			name = pool.GetName() + "-" + strings.ToLower(droplet.ID)
+		}
+		names[name] = true
+		adoptions[droplet.ID] = name
+	}
+
+	if len(adoptions) != 0 && replicas+len(adoptions) > pool.Spec.Replicas {
+		return false, errors.Errorf("pool has %d replicas and %d instances, "+
+			"raise replicas to %d to adopt %d droplets",
+			pool.Spec.Replicas, replicas, replicas+len(adoptions), len(adoptions))
+	}
+
+	delete(pool.Annotations, controller.AnnotationAdopt)
+	if len(adoptions) != 0 {
+		pool.Annotations[controller.AnnotationAdopting] = formatAdoptions(adoptions)
+	}
+	_, err = c.clientset.SpotclusterV1alpha1().
+		Pools().
+		Update(context.TODO(), pool, metav1.UpdateOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	logrus.Infof("Adopting %d droplets into pool %s", len(adoptions), pool.GetName())
+	return true, nil
+}
+
+// createAdopted creates the instances of the adopted droplets recorded in
+// a pool. Pool is updated once every instance is created.
+func (c *Controller) createAdopted(pool *spotcluster.Pool, adoptions map[string]string) error {
+	failed := false
+	for _, id := range sortedIDs(adoptions) {
+		instanceList, err := c.clientset.SpotclusterV1alpha1().
+			Instances().
+			List(context.TODO(),
+				metav1.ListOptions{
+					LabelSelector: controller.LabelInstanceID + "=" +
+						controller.InstanceIDLabel(id),
+				})
+		if err != nil {
+			return err
+		}
+
+		// Instance is created by an earlier sync.
+		if len(instanceList.Items) != 0 {
+			continue
+		}
+
+		instance := &spotcluster.Instance{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       controller.KindInstace,
+				APIVersion: controller.InstaceAPIVersion,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: adoptions[id],
+				Labels: map[string]string{
+					controller.LabelClusterName: pool.GetName(),
+					controller.LabelClusterUID:  string(pool.GetUID()),
+					controller.LabelInstanceID:  controller.InstanceIDLabel(id),
+				},
+				Annotations: map[string]string{
+					controller.AnnotationAdoptedID: id,
+				},
+			},
+			Spec:   spotcluster.InstanceSpec{},
+			Status: spotcluster.InstanceStatus{},
+		}
+
+		instanceCreated, err := c.clientset.SpotclusterV1alpha1().
+			Instances().
+			Create(context.TODO(), instance, metav1.CreateOptions{})
+		// Name is taken by an instance created after the adoption is
+		// recorded, a name is generated instead.
+		if k8serror.IsAlreadyExists(err) {
+			instance.Name = ""
+			instance.GenerateName = pool.GetName() + "-"
+			instanceCreated, err = c.clientset.SpotclusterV1alpha1().
+				Instances().
+				Create(context.TODO(), instance, metav1.CreateOptions{})
+		}
+		if err != nil {
+			logrus.Errorf("Error adopting droplet %s: %s", id, err)
+			c.recorder.Eventf(pool, corev1.EventTypeWarning, controller.ReasonAdoptFailed,
+				"Failed to adopt droplet %s: %s", id, err)
+			failed = true
+			continue
+		}
+
+		logrus.Infof("Droplet %s adopted by instance %s", id, instanceCreated.GetName())
+		c.recorder.Eventf(pool, corev1.EventTypeNormal, controller.ReasonDropletAdopted,
+			"Droplet %s adopted by instance %s", id, instanceCreated.GetName())
+	}
+
+	if failed {
+		return errors.Errorf("failed to adopt droplets into pool %s", pool.GetName())
+	}
+
+	delete(pool.Annotations, controller.AnnotationAdopting)
+	_, err := c.clientset.SpotclusterV1alpha1().
+		Pools().
+		Update(context.TODO(), pool, metav1.UpdateOptions{})
+	return err
+}
+
+// instanceName returns the name of the instance of an adopted droplet. It
+// is the droplet name so that it matches the existing node, made a valid
+// name if needed.
+func instanceName(pool *spotcluster.Pool, droplet provider.InstanceConfig) string {
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(droplet.Name), "-")
+	name = strings.Trim(name, "-.")
+	if len(name) > validation.DNS1123SubdomainMaxLength {
+		name = strings.Trim(name[:validation.DNS1123SubdomainMaxLength], "-.")
+	}
+
+	if len(validation.IsDNS1123Subdomain(name)) != 0 {
+		return pool.GetName() + "-" + strings.ToLower(droplet.ID)
+	}
+	return name
+}
+
+// parseAdoptions returns the droplet ids and instance names recorded in the
+// adopting annotation
+func parseAdoptions(value string) map[string]string {
+	adoptions := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+			adoptions[parts[0]] = parts[1]
+		}
+	}
+	return adoptions
+}
+
+// formatAdoptions returns the adopting annotation of droplet ids and
+// instance names
+func formatAdoptions(adoptions map[string]string) string {
+	entries := []string{}
+	for _, id := range sortedIDs(adoptions) {
+		entries = append(entries, id+"="+adoptions[id])
+	}
+	return strings.Join(entries, ",")
+}
+
+func sortedIDs(adoptions map[string]string) []string {
+	ids := []string{}
+	for id := range adoptions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
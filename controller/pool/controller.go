@@ -6,15 +6,18 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
+	controller "github.com/shovanmaity/spotcluster/controller/common"
 	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
 	clientset "github.com/shovanmaity/spotcluster/pkg/client/clientset/versioned"
 	informer "github.com/shovanmaity/spotcluster/pkg/client/informers/externalversions"
 	lister "github.com/shovanmaity/spotcluster/pkg/client/listers/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 )
 
@@ -25,7 +28,9 @@ type Controller struct {
 	informerFactory informer.SharedInformerFactory
 	poolLister      lister.PoolLister
 	poolSynced      cache.InformerSynced
+	instanceSynced  cache.InformerSynced
 	workqueue       workqueue.RateLimitingInterface
+	recorder        record.EventRecorder
 }
 
 // New returns an instance of Controller object
@@ -44,7 +49,15 @@ func New() (*Controller, error) {
 	if err != nil {
 		return nil, err
 	}
+	provider.SetKubeClient(kubeClientset)
 
+	return NewWithClientsets(kubeClientset, clientset), nil
+}
+
+// NewWithClientsets returns a pool controller which uses the given
+// clientsets
+func NewWithClientsets(kubeClientset kubernetes.Interface,
+	clientset clientset.Interface) *Controller {
 	informerFactory := informer.NewSharedInformerFactory(clientset, 30*time.Second)
 	poolLister := informerFactory.Spotcluster().
 		V1alpha1().
@@ -55,6 +68,11 @@ func New() (*Controller, error) {
 		Pools().
 		Informer().
 		HasSynced
+	instanceSynced := informerFactory.Spotcluster().
+		V1alpha1().
+		Instances().
+		Informer().
+		HasSynced
 	workqueue := workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "POOL")
 
 	c := &Controller{
@@ -63,7 +81,9 @@ func New() (*Controller, error) {
 		informerFactory: informerFactory,
 		poolLister:      poolLister,
 		poolSynced:      poolSynced,
+		instanceSynced:  instanceSynced,
 		workqueue:       workqueue,
+		recorder:        controller.NewEventRecorder(kubeClientset),
 	}
 
 	c.informerFactory.Spotcluster().
@@ -119,7 +139,38 @@ func New() (*Controller, error) {
 			},
 		})
 
-	return c, nil
+	// Pool is synced as soon as one of its instances changes its phase so
+	// that failed, lost and reclaiming instances are replaced right away.
+	c.informerFactory.Spotcluster().
+		V1alpha1().
+		Instances().
+		Informer().
+		AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				oldInstance, ok := oldObj.(*spotcluster.Instance)
+				if !ok {
+					runtime.HandleError(errors.Errorf("Couldn't get instance object %v", oldObj))
+					return
+				}
+
+				newInstance, ok := newObj.(*spotcluster.Instance)
+				if !ok {
+					runtime.HandleError(errors.Errorf("Couldn't get instance object %v", newObj))
+					return
+				}
+
+				if oldInstance.Status.InstanceStatus == newInstance.Status.InstanceStatus {
+					return
+				}
+
+				poolName := newInstance.GetLabels()[controller.LabelClusterName]
+				if poolName != "" {
+					c.workqueue.Add(poolName)
+				}
+			},
+		})
+
+	return c
 }
 
 // Run runs pool controller
@@ -130,7 +181,7 @@ func (c *Controller) Run(stopCh <-chan struct{}) error {
 	c.informerFactory.Start(stopCh)
 	logrus.WithField("controller", "pool").
 		Info("Waiting for informer caches to sync.")
-	if ok := cache.WaitForCacheSync(stopCh, c.poolSynced); !ok {
+	if ok := cache.WaitForCacheSync(stopCh, c.poolSynced, c.instanceSynced); !ok {
 		return errors.New("failed to wait for caches to sync")
 	}
 
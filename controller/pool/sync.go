@@ -2,11 +2,13 @@ package pool
 
 import (
 	"context"
+	"sort"
 
 	"github.com/pkg/errors"
 	controller "github.com/shovanmaity/spotcluster/controller/common"
 	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 	k8serror "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/runtime"
@@ -24,6 +26,21 @@ func (c *Controller) sync(key string) error {
 	}
 
 	clonePool := pool.DeepCopy()
+
+	// Adopt existing droplets first. Pool is synced again after the
+	// adoption is recorded and after the adopted instances are created.
+	if clonePool.DeletionTimestamp == nil {
+		adopted, err := c.adopt(clonePool)
+		if err != nil {
+			logrus.Errorf("Error adopting droplets into pool %s: %s", clonePool.GetName(), err)
+			c.recorder.Event(clonePool, corev1.EventTypeWarning, controller.ReasonAdoptFailed,
+				err.Error())
+		}
+		if adopted {
+			return nil
+		}
+	}
+
 	instanceList, err := c.clientset.SpotclusterV1alpha1().
 		Instances().
 		List(context.TODO(),
@@ -34,8 +51,25 @@ func (c *Controller) sync(key string) error {
 		return err
 	}
 
+	// Failed, lost and reclaiming instances are not counted as replicas,
+	// they are replaced. Reclaiming instances are replaced while their
+	// node is still being drained.
+	instances := []spotcluster.Instance{}
+	failedInstances := []spotcluster.Instance{}
+	reclaimingInstances := []spotcluster.Instance{}
+	for _, i := range instanceList.Items {
+		switch {
+		case controller.IsTerminated(&i):
+			failedInstances = append(failedInstances, i)
+		case controller.IsReclaiming(&i):
+			reclaimingInstances = append(reclaimingInstances, i)
+		default:
+			instances = append(instances, i)
+		}
+	}
+
 	desiredReplicas := clonePool.Spec.Replicas
-	replicas := len(instanceList.Items)
+	replicas := len(instances)
 
 	// Check node password file if any mismatch found then remove that entry.
 	nodepwd := make(map[string]string)
@@ -51,7 +85,7 @@ func (c *Controller) sync(key string) error {
 	}
 
 	if clonePool.DeletionTimestamp != nil {
-		if replicas == 0 {
+		if len(instanceList.Items) == 0 {
 			clonePool.Finalizers = []string{}
 			_, err := c.clientset.SpotclusterV1alpha1().
 				Pools().
@@ -62,10 +96,39 @@ func (c *Controller) sync(key string) error {
 			return nil
 		}
 
+		// Failed and lost instances are only kept for debugging, delete them
+		// along with the pool.
+		c.deleteInstances(clonePool, failedInstances)
+		c.deleteInstances(clonePool, reclaimingInstances)
 		logrus.Info("Waiting fot instances to be deleted")
 		return nil
 	}
 
+	// Keep only a few recent failed and lost instances.
+	if len(failedInstances) > controller.FailedInstanceHistoryLimit {
+		sort.Slice(failedInstances, func(i, j int) bool {
+			return failedInstances[i].CreationTimestamp.After(
+				failedInstances[j].CreationTimestamp.Time)
+		})
+		c.deleteInstances(clonePool, failedInstances[controller.FailedInstanceHistoryLimit:])
+	}
+
+	// Instances failing on an invalid configuration are not replaced until
+	// the pool is updated, new instances would fail the same way.
+	if desiredReplicas > replicas {
+		for _, i := range failedInstances {
+			if i.Status.FailureReason == controller.ReasonInvalidConfig &&
+				i.Status.PoolGeneration == clonePool.GetGeneration() {
+				logrus.Warnf("Not scaling pool %s: instance %s failed with invalid configuration",
+					clonePool.GetName(), i.GetName())
+				c.recorder.Eventf(clonePool, corev1.EventTypeWarning, controller.ReasonInvalidConfig,
+					"Not creating instances until the pool is updated, instance %s failed: %s",
+					i.GetName(), i.Status.FailureMessage)
+				return nil
+			}
+		}
+	}
+
 	if desiredReplicas > replicas {
 		// If desired replicas are greater than available replicas
 		// then we need to create some new replicas.
@@ -91,22 +154,36 @@ func (c *Controller) sync(key string) error {
 				Create(context.TODO(), instance, metav1.CreateOptions{})
 			if err != nil {
 				logrus.Errorf("Error creating new instance: %s", err)
+				c.recorder.Event(clonePool, corev1.EventTypeWarning,
+					controller.ReasonInstanceCreateFailed, err.Error())
+				continue
 			}
 
 			logrus.Infof("New instance %s successfully created", instanceCreated.GetName())
+			c.recorder.Eventf(clonePool, corev1.EventTypeNormal, controller.ReasonInstanceCreated,
+				"Created instance %s, scaling to %d replicas", instanceCreated.GetName(), desiredReplicas)
 		}
 	} else if desiredReplicas < replicas {
 		// If available replicas are greater than desired replicas
 		// then we need to delete some older replicas.
-		for i := desiredReplicas; i < replicas; i++ {
-			instance := instanceList.Items[i]
-			err := c.clientset.SpotclusterV1alpha1().
-				Instances().
-				Delete(context.TODO(), instance.GetName(), metav1.DeleteOptions{})
-			if err != nil {
-				logrus.Errorf("Error deleting instance %s: %s", instance.GetName(), err)
-			}
-		}
+		c.deleteInstances(clonePool, instances[desiredReplicas:])
 	}
 	return nil
 }
+
+func (c *Controller) deleteInstances(pool *spotcluster.Pool, instances []spotcluster.Instance) {
+	for _, instance := range instances {
+		if instance.DeletionTimestamp != nil {
+			continue
+		}
+		err := c.clientset.SpotclusterV1alpha1().
+			Instances().
+			Delete(context.TODO(), instance.GetName(), metav1.DeleteOptions{})
+		if err != nil {
+			logrus.Errorf("Error deleting instance %s: %s", instance.GetName(), err)
+			continue
+		}
+		c.recorder.Eventf(pool, corev1.EventTypeNormal, controller.ReasonInstanceDeleted,
+			"Deleted instance %s", instance.GetName())
+	}
+}
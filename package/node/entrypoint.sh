@@ -0,0 +1,8 @@
+#!/bin/sh
+# Authorize the key given by the container provider and hand over to systemd.
+set -e
+if [ -n "$SSH_PUBLIC_KEY" ]; then
+	echo "$SSH_PUBLIC_KEY" > /root/.ssh/authorized_keys
+	chmod 600 /root/.ssh/authorized_keys
+fi
+exec /sbin/init
@@ -1,6 +1,7 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -23,9 +24,11 @@ type InstanceSpec struct {
 	InternalIP        string `json:"internalIP,omitempty"`
 	ExternalIP        string `json:"externalIP,omitempty"`
 	Provider          string `json:"provider,omitempty"`
+	ProviderID        string `json:"providerID,omitempty"`
 	NodeName          string `json:"nodeName,omitempty"`
 	NodePassword      string `json:"nodePassword,omitempty"`
 	InstanceName      string `json:"instanceName,omitempty"`
+	InstanceID        string `json:"instanceID,omitempty"`
 	InstanceAvailable bool   `json:"instanceAvailable"`
 	NodeAvailable     bool   `json:"nodeAvailable"`
 	InstanceReady     bool   `json:"instanceReady"`
@@ -35,6 +38,87 @@ type InstanceSpec struct {
 type InstanceStatus struct {
 	NodeStatus     string `json:"nodeStatus,omitempty"`
 	InstanceStatus string `json:"instanceStatus,omitempty"`
+
+	// NodeRegisteredAt is the time when the node of this instance
+	// became ready for the first time.
+	NodeRegisteredAt *metav1.Time `json:"nodeRegisteredAt,omitempty"`
+
+	// NodeNotReadySince is the time since the node of this instance is
+	// not ready or unreachable.
+	NodeNotReadySince *metav1.Time `json:"nodeNotReadySince,omitempty"`
+
+	// FailureReason and FailureMessage are kept when the instance is
+	// marked as failed so that it can be debugged later.
+	FailureReason  string `json:"failureReason,omitempty"`
+	FailureMessage string `json:"failureMessage,omitempty"`
+
+	// PoolGeneration is the generation of the pool when the instance
+	// failed because of an invalid configuration.
+	PoolGeneration int64 `json:"poolGeneration,omitempty"`
+
+	// Drift lists the attributes of the vm which differ from the pool or
+	// from the instance since they were changed out of band.
+	Drift []string `json:"drift,omitempty"`
+
+	// Bootstrap is the outcome of the last worker bootstrap
+	Bootstrap *BootstrapStatus `json:"bootstrap,omitempty"`
+
+	// Conditions are the latest observations of each provisioning step
+	Conditions []InstanceCondition `json:"conditions,omitempty"`
+
+	// LastError is the error of the last failed provisioning attempt and
+	// Attempts is the number of failed attempts of the current step.
+	LastError string `json:"lastError,omitempty"`
+	Attempts  int    `json:"attempts,omitempty"`
+
+	// LastAction is the outcome of the last action asked for through the
+	// action annotation
+	LastAction *ActionStatus `json:"lastAction,omitempty"`
+}
+
+// ActionStatus is the outcome of an action run on an instance
+type ActionStatus struct {
+	Name    string       `json:"name"`
+	Time    *metav1.Time `json:"time,omitempty"`
+	Result  string       `json:"result,omitempty"`
+	Message string       `json:"message,omitempty"`
+}
+
+// InstanceConditionType is a provisioning step of an instance
+type InstanceConditionType string
+
+// These are valid conditions of an instance
+const (
+	InstanceVMProvisioned  InstanceConditionType = "VMProvisioned"
+	InstanceVMRunning      InstanceConditionType = "VMRunning"
+	InstanceBootstrapped   InstanceConditionType = "Bootstrapped"
+	InstanceNodeRegistered InstanceConditionType = "NodeRegistered"
+	InstanceNodeReady      InstanceConditionType = "NodeReady"
+	InstanceDraining       InstanceConditionType = "Draining"
+	InstanceRepairLimited  InstanceConditionType = "RepairLimited"
+)
+
+// InstanceCondition contains details of the current state of a
+// provisioning step of an instance
+type InstanceCondition struct {
+	Type               InstanceConditionType  `json:"type"`
+	Status             corev1.ConditionStatus `json:"status"`
+	LastTransitionTime metav1.Time            `json:"lastTransitionTime,omitempty"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
+}
+
+type BootstrapStatus struct {
+	StartTime *metav1.Time     `json:"startTime,omitempty"`
+	Duration  *metav1.Duration `json:"duration,omitempty"`
+	ExitCode  int              `json:"exitCode"`
+
+	// OutputTail is the last few lines of the bootstrap output
+	OutputTail string `json:"outputTail,omitempty"`
+
+	// ConfigMap is the namespace/name of the config map which keeps
+	// the full bootstrap output
+	ConfigMap string `json:"configMap,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
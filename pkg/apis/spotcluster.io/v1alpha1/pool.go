@@ -23,10 +23,231 @@ type ClusterSpec struct {
 	SSHFingerprint string `json:"sshFingerprint,omitempty"`
 	NodeToken      string `json:"nodeToken,omitempty"`
 	MasterURL      string `json:"masterUrl,omitempty"`
+
+	// NodeRegistrationTimeout is the time an instance gets to produce a
+	// ready node. Instances which exceed it are marked as failed and replaced.
+	NodeRegistrationTimeout *metav1.Duration `json:"nodeRegistrationTimeout,omitempty"`
+
+	// AutoRepair replaces instances whose node stays not ready.
+	AutoRepair *AutoRepairPolicy `json:"autoRepair,omitempty"`
+}
+
+type AutoRepairPolicy struct {
+	Enabled bool `json:"enabled,omitempty"`
+
+	// NotReadyThreshold is the time a node can stay not ready or
+	// unreachable before its instance is replaced.
+	NotReadyThreshold *metav1.Duration `json:"notReadyThreshold,omitempty"`
+
+	// MaxRepairs is the maximum number of instances of the pool that
+	// can be replaced within RepairWindow.
+	MaxRepairs   int              `json:"maxRepairs,omitempty"`
+	RepairWindow *metav1.Duration `json:"repairWindow,omitempty"`
 }
 
 type ProviderSpec struct {
 	DigitalOcean *DigitalOcean `json:"digitalOcean,omitempty"`
+	Fake         *Fake         `json:"fake,omitempty"`
+	AWS          *AWS          `json:"aws,omitempty"`
+	GCP          *GCP          `json:"gcp,omitempty"`
+	Azure        *Azure        `json:"azure,omitempty"`
+	Hetzner      *Hetzner      `json:"hetzner,omitempty"`
+	OpenStack    *OpenStack    `json:"openStack,omitempty"`
+	Static       *Static       `json:"static,omitempty"`
+	Container    *Container    `json:"container,omitempty"`
+	Plugin       *Plugin       `json:"plugin,omitempty"`
+}
+
+// Plugin uses a provider plugin which spot-manager is connected to
+type Plugin struct {
+	// Name is the name of the plugin given to spot-manager
+	Name string `json:"name,omitempty"`
+	// Config is passed to the plugin as it is
+	Config map[string]string `json:"config,omitempty"`
+}
+
+// SecretKeyRef refers to a key of a secret
+type SecretKeyRef struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Key       string `json:"key,omitempty"`
+}
+
+// ConfigMapKeyRef refers to a key of a config map
+type ConfigMapKeyRef struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Key       string `json:"key,omitempty"`
+}
+
+// GCP launches spot compute engine vms
+type GCP struct {
+	Project string `json:"project,omitempty"`
+	// Zones are tried in order until one of them has capacity
+	Zones       []string `json:"zones,omitempty"`
+	MachineType string   `json:"machineType,omitempty"`
+	// Image is the self link or the family url of the boot disk image
+	Image      string `json:"image,omitempty"`
+	DiskSizeGB int64  `json:"diskSizeGB,omitempty"`
+	// Network and Subnetwork are the default network if empty
+	Network    string `json:"network,omitempty"`
+	Subnetwork string `json:"subnetwork,omitempty"`
+	// CredentialsSecret holds the json key of a service account
+	CredentialsSecret *SecretKeyRef `json:"credentialsSecret,omitempty"`
+	// SSHPublicKey is added to the vm metadata for SSHUser
+	SSHPublicKey string `json:"sshPublicKey,omitempty"`
+	// SSHUser is the user used to provision the worker, ubuntu if empty
+	SSHUser string `json:"sshUser,omitempty"`
+	// Endpoint is the url of the compute api, it is only set to use an api
+	// stand-in.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// AWS launches EC2 spot instances
+type AWS struct {
+	Region string `json:"region,omitempty"`
+	// CredentialsSecret holds the json of an access key with accessKeyId,
+	// secretAccessKey and an optional sessionToken. Default credential
+	// chain of the sdk is used if it is not set.
+	CredentialsSecret *SecretKeyRef `json:"credentialsSecret,omitempty"`
+	AMI               string        `json:"ami,omitempty"`
+	// InstanceTypes are tried in order until one of them has spot capacity
+	InstanceTypes []string `json:"instanceTypes,omitempty"`
+	// Subnets are tried in order along with the instance types
+	Subnets        []string `json:"subnets,omitempty"`
+	SecurityGroups []string `json:"securityGroups,omitempty"`
+	// KeyName is the name of the key pair which is allowed to ssh
+	KeyName string `json:"keyName,omitempty"`
+	// MaxPrice is the maximum hourly spot price, on demand price if empty
+	MaxPrice string `json:"maxPrice,omitempty"`
+	// SSHUser is the user of the ami, ubuntu if empty
+	SSHUser string `json:"sshUser,omitempty"`
+	// Endpoint is the url of the ec2 api, it is only set to use an api
+	// stand-in.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// Azure launches spot virtual machines
+type Azure struct {
+	SubscriptionID string `json:"subscriptionID,omitempty"`
+	ResourceGroup  string `json:"resourceGroup,omitempty"`
+	Location       string `json:"location,omitempty"`
+	// VMSizes are tried in order until one of them has spot capacity
+	VMSizes []string `json:"vmSizes,omitempty"`
+	// Image is the urn publisher:offer:sku:version or the resource id of
+	// an image
+	Image string `json:"image,omitempty"`
+	// Subnet is the resource id of the subnet of the vms
+	Subnet string `json:"subnet,omitempty"`
+	// EvictionPolicy is Deallocate or Delete, Delete if empty
+	EvictionPolicy string `json:"evictionPolicy,omitempty"`
+	// MaxPrice is the maximum hourly price in US dollars, on demand price
+	// if empty
+	MaxPrice string `json:"maxPrice,omitempty"`
+	// CredentialsSecret holds the json of a service principal with
+	// tenantId, clientId and clientSecret
+	CredentialsSecret *SecretKeyRef `json:"credentialsSecret,omitempty"`
+	// SSHPublicKey is authorized for SSHUser
+	SSHPublicKey string `json:"sshPublicKey,omitempty"`
+	// SSHUser is the admin user of the vms, ubuntu if empty
+	SSHUser string `json:"sshUser,omitempty"`
+	// ResourceManagerURL is the base url of the azure resource manager, it
+	// is only set to use an api stand-in.
+	ResourceManagerURL string `json:"resourceManagerURL,omitempty"`
+}
+
+// Hetzner launches hetzner cloud servers
+type Hetzner struct {
+	APIToken string `json:"apiToken,omitempty"`
+	// ServerTypes are tried in order along with the locations until one of
+	// them is available
+	ServerTypes []string `json:"serverTypes,omitempty"`
+	Locations   []string `json:"locations,omitempty"`
+	Image       string   `json:"image,omitempty"`
+	// SSHKeys are the names or ids of ssh keys added to the servers along
+	// with the key of the cluster ssh fingerprint
+	SSHKeys []string `json:"sshKeys,omitempty"`
+	// Networks are the names or ids of private networks the servers are
+	// attached to
+	Networks []string `json:"networks,omitempty"`
+	// Endpoint is the url of the hetzner cloud api, it is only set to use
+	// an api stand-in.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// OpenStack launches nova servers of an openstack cloud
+type OpenStack struct {
+	// CloudsSecret holds a clouds.yaml with the keystone credentials
+	CloudsSecret *SecretKeyRef `json:"cloudsSecret,omitempty"`
+	// Cloud is the entry of the clouds.yaml used, openstack if empty
+	Cloud string `json:"cloud,omitempty"`
+	// Region overrides the region of the cloud entry
+	Region string `json:"region,omitempty"`
+	// Flavor is the name of the flavor of the servers
+	Flavor string `json:"flavor,omitempty"`
+	// Image is the name or id of the image of the servers
+	Image string `json:"image,omitempty"`
+	// Networks are the names or ids of the networks the servers are
+	// attached to, the networks of the project if empty
+	Networks []string `json:"networks,omitempty"`
+	// KeyPair is the name of the key pair which is allowed to ssh, the key
+	// pair of the cluster ssh fingerprint if empty
+	KeyPair        string   `json:"keyPair,omitempty"`
+	SecurityGroups []string `json:"securityGroups,omitempty"`
+	// AvailabilityZone is chosen by nova if empty
+	AvailabilityZone string `json:"availabilityZone,omitempty"`
+	// SSHUser is the user of the image, ubuntu if empty
+	SSHUser string `json:"sshUser,omitempty"`
+}
+
+// Static joins existing machines which are reachable over ssh. A host is
+// claimed by an instance in place of creating a vm.
+type Static struct {
+	// Hosts are the host:port of the ssh servers of the machines
+	Hosts []string `json:"hosts,omitempty"`
+	// HostsConfigMap holds more hosts, one host:port per line
+	HostsConfigMap *ConfigMapKeyRef `json:"hostsConfigMap,omitempty"`
+	// SSHUser is the user used to provision the worker, root if empty.
+	// Other users need passwordless sudo.
+	SSHUser string `json:"sshUser,omitempty"`
+}
+
+// Container runs vms as privileged containers of a local docker engine.
+// It is used to run development clusters on one machine.
+type Container struct {
+	// Image runs systemd and a ssh server, package/node/Dockerfile builds
+	// such an image
+	Image string `json:"image,omitempty"`
+	// Network is the docker network shared with the k3s server, bridge if
+	// empty
+	Network string `json:"network,omitempty"`
+	// Host is the url of the docker engine, unix:///var/run/docker.sock
+	// if empty
+	Host string `json:"host,omitempty"`
+	// SSHPublicKey is authorized for root by the image
+	SSHPublicKey string `json:"sshPublicKey,omitempty"`
+}
+
+// Fake is an in-memory provider for tests, demos and dry runs. Its vms
+// point at local ssh test servers.
+type Fake struct {
+	// BootDelay is the time a vm takes to become running
+	BootDelay *metav1.Duration `json:"bootDelay,omitempty"`
+	// Capacity is the maximum number of vms, zero means no limit
+	Capacity int `json:"capacity,omitempty"`
+	// FailureRate is the percentage of create calls which fail
+	FailureRate int `json:"failureRate,omitempty"`
+	// FailureClass is the class of injected failures, one of Transient,
+	// Throttled, Quota, InvalidConfig or Terminal
+	FailureClass string `json:"failureClass,omitempty"`
+	// ReclaimAfter stops a vm after it has been running for this time
+	ReclaimAfter *metav1.Duration `json:"reclaimAfter,omitempty"`
+	// ReclaimNotice is the time an interruption notice is given before a
+	// vm is reclaimed
+	ReclaimNotice *metav1.Duration `json:"reclaimNotice,omitempty"`
+	// SSHAddresses are the host:port of local ssh test servers, vms are
+	// assigned to them in turn
+	SSHAddresses []string `json:"sshAddresses,omitempty"`
 }
 
 type DigitalOcean struct {
@@ -34,6 +255,9 @@ type DigitalOcean struct {
 	InstanceSize string `json:"instanceSize,omitempty"`
 	APIKey       string `json:"apiKey,omitempty"`
 	Region       string `json:"region,omitempty"`
+	// APIURL is the base url of the digitalocean api, it is only set to
+	// use an api stand-in.
+	APIURL string `json:"apiURL,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
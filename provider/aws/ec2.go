@@ -0,0 +1,344 @@
+package aws
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+const (
+	// spotInterruptionNotice is the time aws gives before a spot instance
+	// is interrupted
+	spotInterruptionNotice = 2 * time.Minute
+)
+
+// Client launches ec2 spot instances for a pool
+type Client struct {
+	Provider ec2iface.EC2API
+	spec     spotcluster.AWS
+}
+
+// Create launches a spot instance. Instance types and subnets of the pool
+// are tried in order until one of them has capacity.
+func (c *Client) Create(ctx context.Context,
+	config provider.InstanceConfig) (*provider.InstanceConfig, error) {
+	instanceTypes := c.spec.InstanceTypes
+	if len(instanceTypes) == 0 {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.New("no instance type is given"))
+	}
+
+	subnets := c.spec.Subnets
+	if len(subnets) == 0 {
+		subnets = []string{""}
+	}
+
+	var lastErr error
+	for _, instanceType := range instanceTypes {
+		for _, subnet := range subnets {
+			instance, err := c.run(ctx, config, instanceType, subnet)
+			if err == nil {
+				return toInstanceConfig(instance), nil
+			}
+
+			// Try the next instance type or subnet only if there is no
+			// capacity, other errors are the same for all of them.
+			lastErr = err
+			if !isCapacityError(err) {
+				return nil, classify(err)
+			}
+		}
+	}
+	return nil, classify(lastErr)
+}
+
+// run launches a spot instance of an instance type in a subnet
+func (c *Client) run(ctx context.Context, config provider.InstanceConfig,
+	instanceType, subnet string) (*ec2.Instance, error) {
+	tags := []*ec2.Tag{
+		{
+			Key:   aws.String(provider.AWSNameTag),
+			Value: aws.String(config.Name),
+		},
+	}
+	for _, tag := range config.Tags {
+		tags = append(tags, &ec2.Tag{
+			Key:   aws.String(tag),
+			Value: aws.String(""),
+		})
+	}
+
+	spotOptions := &ec2.SpotMarketOptions{
+		SpotInstanceType:             aws.String(ec2.SpotInstanceTypeOneTime),
+		InstanceInterruptionBehavior: aws.String(ec2.InstanceInterruptionBehaviorTerminate),
+	}
+	if c.spec.MaxPrice != "" {
+		spotOptions.MaxPrice = aws.String(c.spec.MaxPrice)
+	}
+
+	input := &ec2.RunInstancesInput{
+		ImageId:      aws.String(config.Image),
+		InstanceType: aws.String(instanceType),
+		MinCount:     aws.Int64(1),
+		MaxCount:     aws.Int64(1),
+		InstanceMarketOptions: &ec2.InstanceMarketOptionsRequest{
+			MarketType:  aws.String(ec2.MarketTypeSpot),
+			SpotOptions: spotOptions,
+		},
+		TagSpecifications: []*ec2.TagSpecification{
+			{
+				ResourceType: aws.String(ec2.ResourceTypeInstance),
+				Tags:         tags,
+			},
+		},
+	}
+	if c.spec.KeyName != "" {
+		input.KeyName = aws.String(c.spec.KeyName)
+	}
+	if subnet != "" {
+		input.SubnetId = aws.String(subnet)
+	}
+	if len(c.spec.SecurityGroups) != 0 {
+		input.SecurityGroupIds = aws.StringSlice(c.spec.SecurityGroups)
+	}
+
+	reservation, err := c.Provider.RunInstancesWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reservation.Instances) != 1 {
+		return nil, errors.Errorf("Got %d instances for a launch", len(reservation.Instances))
+	}
+	return reservation.Instances[0], nil
+}
+
+// Get returns the instance with the given tag
+func (c *Client) Get(ctx context.Context, tag string) (*provider.InstanceConfig, bool, error) {
+	list, err := c.List(ctx, tag)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(list) == 0 {
+		return nil, false, nil
+	}
+
+	if len(list) != 1 {
+		return nil, false,
+			errors.Errorf("Got %d instances for the given tag %s", len(list), tag)
+	}
+	return &list[0], true, nil
+}
+
+// Delete terminates the instance with the given tag if found
+func (c *Client) Delete(ctx context.Context, tag string) error {
+	instance, found, err := c.Get(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return nil
+	}
+	return c.DeleteByID(ctx, instance.ID)
+}
+
+// List returns all the instances with the given tag which are not
+// terminated
+func (c *Client) List(ctx context.Context, tag string) ([]provider.InstanceConfig, error) {
+	return c.describe(ctx, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("tag-key"),
+				Values: aws.StringSlice([]string{tag}),
+			},
+		},
+	})
+}
+
+// Reboot reboots the instance with the given id
+func (c *Client) Reboot(ctx context.Context, id string) error {
+	_, err := c.Provider.RebootInstancesWithContext(ctx, &ec2.RebootInstancesInput{
+		InstanceIds: aws.StringSlice([]string{id}),
+	})
+	if err != nil {
+		return classify(err)
+	}
+	return nil
+}
+
+// Find returns the instances for given instance ids or tags
+func (c *Client) Find(ctx context.Context, refs []string) ([]provider.InstanceConfig, error) {
+	list := []provider.InstanceConfig{}
+	for _, ref := range refs {
+		if strings.HasPrefix(ref, "i-") {
+			instances, err := c.describe(ctx, &ec2.DescribeInstancesInput{
+				InstanceIds: aws.StringSlice([]string{ref}),
+			})
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, instances...)
+			continue
+		}
+
+		instances, err := c.List(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, instances...)
+	}
+	return list, nil
+}
+
+// Tag adds the given tags to an instance
+func (c *Client) Tag(ctx context.Context, id string, tags ...string) error {
+	ec2Tags := []*ec2.Tag{}
+	for _, tag := range tags {
+		ec2Tags = append(ec2Tags, &ec2.Tag{
+			Key:   aws.String(tag),
+			Value: aws.String(""),
+		})
+	}
+
+	_, err := c.Provider.CreateTagsWithContext(ctx, &ec2.CreateTagsInput{
+		Resources: aws.StringSlice([]string{id}),
+		Tags:      ec2Tags,
+	})
+	if err != nil {
+		return classify(err)
+	}
+	return nil
+}
+
+// DeleteByID terminates the instance with the given id
+func (c *Client) DeleteByID(ctx context.Context, id string) error {
+	_, err := c.Provider.TerminateInstancesWithContext(ctx, &ec2.TerminateInstancesInput{
+		InstanceIds: aws.StringSlice([]string{id}),
+	})
+	if err != nil && !isNotFound(err) {
+		return classify(err)
+	}
+	return nil
+}
+
+// Interruption returns a notice once aws has marked the spot instance for
+// interruption. Instance is interrupted two minutes after the notice.
+func (c *Client) Interruption(ctx context.Context,
+	id string) (*provider.InterruptionNotice, error) {
+	output, err := c.Provider.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: aws.StringSlice([]string{id}),
+	})
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	requestID := ""
+	for _, reservation := range output.Reservations {
+		for _, instance := range reservation.Instances {
+			requestID = aws.StringValue(instance.SpotInstanceRequestId)
+		}
+	}
+	if requestID == "" {
+		return nil, nil
+	}
+
+	requests, err := c.Provider.DescribeSpotInstanceRequestsWithContext(ctx,
+		&ec2.DescribeSpotInstanceRequestsInput{
+			SpotInstanceRequestIds: aws.StringSlice([]string{requestID}),
+		})
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	for _, request := range requests.SpotInstanceRequests {
+		if request.Status == nil {
+			continue
+		}
+
+		code := aws.StringValue(request.Status.Code)
+		if !strings.HasPrefix(code, "marked-for-") {
+			continue
+		}
+
+		noticeTime := aws.TimeValue(request.Status.UpdateTime)
+		if noticeTime.IsZero() {
+			noticeTime = time.Now()
+		}
+		return &provider.InterruptionNotice{
+			Time:   noticeTime.Add(spotInterruptionNotice),
+			Reason: code + ": " + aws.StringValue(request.Status.Message),
+		}, nil
+	}
+	return nil, nil
+}
+
+// describe returns all the instances which match the given input, pages
+// are followed until the last one.
+func (c *Client) describe(ctx context.Context,
+	input *ec2.DescribeInstancesInput) ([]provider.InstanceConfig, error) {
+	list := []provider.InstanceConfig{}
+	err := c.Provider.DescribeInstancesPagesWithContext(ctx, input,
+		func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+			for _, reservation := range page.Reservations {
+				for _, instance := range reservation.Instances {
+					state := ""
+					if instance.State != nil {
+						state = aws.StringValue(instance.State.Name)
+					}
+					if state == ec2.InstanceStateNameTerminated ||
+						state == ec2.InstanceStateNameShuttingDown {
+						continue
+					}
+					list = append(list, *toInstanceConfig(instance))
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return nil, classify(err)
+	}
+	return list, nil
+}
+
+func toInstanceConfig(instance *ec2.Instance) *provider.InstanceConfig {
+	config := &provider.InstanceConfig{
+		ID:         aws.StringValue(instance.InstanceId),
+		Image:      aws.StringValue(instance.ImageId),
+		Size:       aws.StringValue(instance.InstanceType),
+		InternalIP: aws.StringValue(instance.PrivateIpAddress),
+		ExteralIP:  aws.StringValue(instance.PublicIpAddress),
+		Labels:     make(map[string]string),
+	}
+
+	if instance.Placement != nil {
+		config.Zone = aws.StringValue(instance.Placement.AvailabilityZone)
+		if len(config.Zone) > 1 {
+			config.Region = config.Zone[:len(config.Zone)-1]
+		}
+	}
+
+	if instance.State != nil {
+		config.Status = aws.StringValue(instance.State.Name)
+		config.IsRunning = config.Status == ec2.InstanceStateNameRunning
+	}
+
+	for _, tag := range instance.Tags {
+		key := aws.StringValue(tag.Key)
+		if key == provider.AWSNameTag {
+			config.Name = aws.StringValue(tag.Value)
+			continue
+		}
+		config.Tags = append(config.Tags, key)
+		config.Labels[key] = aws.StringValue(tag.Value)
+	}
+	return config
+}
@@ -0,0 +1,62 @@
+package aws
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+// classify wraps an ec2 api error with its error class
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return provider.NewError(provider.ErrorTransient, 0, err)
+	}
+
+	code := awsErr.Code()
+	switch {
+	case code == "RequestLimitExceeded" || code == "Throttling":
+		return provider.NewError(provider.ErrorThrottled, 0, err)
+	case isCapacityError(err) || code == "MaxSpotInstanceCountExceeded" ||
+		code == "InstanceLimitExceeded" || code == "VcpuLimitExceeded":
+		return provider.NewError(provider.ErrorQuota, 0, err)
+	case code == "AuthFailure" || code == "UnauthorizedOperation" ||
+		code == "OptInRequired" || code == "InvalidParameterValue" ||
+		code == "InvalidParameterCombination" || code == "MissingParameter" ||
+		strings.HasPrefix(code, "InvalidAMIID") ||
+		strings.HasPrefix(code, "InvalidSubnetID") ||
+		strings.HasPrefix(code, "InvalidGroup") ||
+		strings.HasPrefix(code, "InvalidKeyPair"):
+		return provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	case isNotFound(err):
+		return provider.NewError(provider.ErrorTerminal, 0, err)
+	}
+	return provider.NewError(provider.ErrorTransient, 0, err)
+}
+
+// isCapacityError returns true if there is no spot capacity for an
+// instance type in a subnet
+func isCapacityError(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+
+	switch awsErr.Code() {
+	case "InsufficientInstanceCapacity", "SpotMaxPriceTooLow",
+		"InsufficientFreeAddressesInSubnet", "Unsupported":
+		return true
+	}
+	return false
+}
+
+// isNotFound returns true if the instance is not found
+func isNotFound(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && strings.HasPrefix(awsErr.Code(), "InvalidInstanceID")
+}
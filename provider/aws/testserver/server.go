@@ -0,0 +1,638 @@
+// Package testserver is an in-memory stand-in for the ec2 query api. It
+// serves the instance, tag and spot request actions used by spotcluster so
+// that the aws provider can be exercised without an aws account.
+package testserver
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	xmlNamespace = "http://ec2.amazonaws.com/doc/2016-11-15/"
+
+	stateCodePending    = 0
+	stateCodeRunning    = 16
+	stateCodeTerminated = 48
+)
+
+// Server is an ec2 api stand-in which keeps its state in memory
+type Server struct {
+	sync.Mutex
+	server *httptest.Server
+
+	// AccessKeyID is the access key accepted by the server, any key is
+	// accepted if it is empty.
+	AccessKeyID string
+	// Region is the region of the instances, us-east-1 if empty
+	Region string
+	// BootDelay is the time a new instance takes to be running
+	BootDelay time.Duration
+	// InstanceLimit is the maximum number of spot instances which are not
+	// terminated, zero means no limit
+	InstanceLimit int
+	// NoCapacity lists the instance types which have no spot capacity
+	NoCapacity []string
+	// PageSize is the number of reservations in a page of described
+	// instances, all of them are returned in one page if it is zero.
+	PageSize int
+
+	instances map[string]*instance
+	images    map[string]bool
+	failures  []failure
+	lastID    int
+}
+
+// Instance is an ec2 instance as it is seen through the api
+type Instance struct {
+	InstanceID            string    `xml:"instanceId"`
+	ImageID               string    `xml:"imageId"`
+	State                 State     `xml:"instanceState"`
+	InstanceType          string    `xml:"instanceType"`
+	KeyName               string    `xml:"keyName,omitempty"`
+	Placement             Placement `xml:"placement"`
+	SubnetID              string    `xml:"subnetId,omitempty"`
+	PrivateIPAddress      string    `xml:"privateIpAddress,omitempty"`
+	PublicIPAddress       string    `xml:"ipAddress,omitempty"`
+	InstanceLifecycle     string    `xml:"instanceLifecycle,omitempty"`
+	SpotInstanceRequestID string    `xml:"spotInstanceRequestId,omitempty"`
+	Tags                  []Tag     `xml:"tagSet>item"`
+}
+
+// State is the state of an instance
+type State struct {
+	Code int    `xml:"code"`
+	Name string `xml:"name"`
+}
+
+// Placement is the availability zone of an instance
+type Placement struct {
+	AvailabilityZone string `xml:"availabilityZone"`
+}
+
+// Tag is a tag of an instance
+type Tag struct {
+	Key   string `xml:"key"`
+	Value string `xml:"value"`
+}
+
+type instance struct {
+	Instance
+	createdAt time.Time
+	// spotStatus is the status code of the spot request of the instance
+	spotStatus     string
+	spotStatusTime time.Time
+}
+
+// failure is an error returned for the next request which matches it
+type failure struct {
+	action string
+	code   string
+	status int
+}
+
+type reservation struct {
+	ReservationID string     `xml:"reservationId"`
+	OwnerID       string     `xml:"ownerId"`
+	Instances     []Instance `xml:"instancesSet>item"`
+}
+
+type spotRequest struct {
+	SpotInstanceRequestID string     `xml:"spotInstanceRequestId"`
+	State                 string     `xml:"state"`
+	Status                spotStatus `xml:"status"`
+	InstanceID            string     `xml:"instanceId"`
+}
+
+type spotStatus struct {
+	Code       string `xml:"code"`
+	UpdateTime string `xml:"updateTime"`
+	Message    string `xml:"message"`
+}
+
+type stateChange struct {
+	InstanceID    string `xml:"instanceId"`
+	CurrentState  State  `xml:"currentState"`
+	PreviousState State  `xml:"previousState"`
+}
+
+// NewServer starts a new api stand-in with the image ami-12345678. It must
+// be closed once done.
+func NewServer() *Server {
+	s := &Server{
+		instances: make(map[string]*instance),
+		images:    map[string]bool{"ami-12345678": true},
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// URL returns the endpoint of the api which is given to the sdk
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Close shuts down the server
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// AddImage registers an ami, instances can only be launched from
+// registered amis.
+func (s *Server) AddImage(id string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.images[id] = true
+}
+
+// AddInstance adds an existing spot instance, e.g. one which is adopted
+// later. It is running right away.
+func (s *Server) AddInstance(name, instanceType string, tags ...string) Instance {
+	s.Lock()
+	defer s.Unlock()
+
+	i := s.newInstance("ami-12345678", instanceType, "", "", nameTags(name, tags))
+	i.createdAt = time.Time{}
+	return s.view(i)
+}
+
+// Instances returns all the instances including the terminated ones
+func (s *Server) Instances() []Instance {
+	s.Lock()
+	defer s.Unlock()
+
+	list := []Instance{}
+	for _, id := range s.instanceIDs() {
+		list = append(list, s.view(s.instances[id]))
+	}
+	return list
+}
+
+// MarkForInterruption marks the spot request of an instance for
+// termination as aws does two minutes before a spot instance is reclaimed
+func (s *Server) MarkForInterruption(id string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if i, ok := s.instances[id]; ok {
+		i.spotStatus = "marked-for-termination"
+		i.spotStatusTime = time.Now()
+	}
+}
+
+// Terminate terminates an instance as if it was reclaimed
+func (s *Server) Terminate(id string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if i, ok := s.instances[id]; ok {
+		i.State = State{Code: stateCodeTerminated, Name: "terminated"}
+	}
+}
+
+// Fail makes the next request of the given action fail with the given
+// error code and status, e.g. Fail("RunInstances", "RequestLimitExceeded",
+// 503).
+func (s *Server) Fail(action, code string, status int) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.failures = append(s.failures, failure{
+		action: action,
+		code:   code,
+		status: status,
+	})
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "MalformedQueryString", err.Error())
+		return
+	}
+
+	if s.AccessKeyID != "" &&
+		!strings.Contains(r.Header.Get("Authorization"), "Credential="+s.AccessKeyID+"/") {
+		writeError(w, http.StatusUnauthorized, "AuthFailure",
+			"AWS was not able to validate the provided access credentials")
+		return
+	}
+
+	action := r.Form.Get("Action")
+	for i, f := range s.failures {
+		if f.action == action {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			writeError(w, f.status, f.code, "injected failure")
+			return
+		}
+	}
+
+	switch action {
+	case "RunInstances":
+		s.runInstances(w, r)
+	case "DescribeInstances":
+		s.describeInstances(w, r)
+	case "TerminateInstances":
+		s.terminateInstances(w, r)
+	case "RebootInstances":
+		s.rebootInstances(w, r)
+	case "CreateTags":
+		s.createTags(w, r)
+	case "DescribeSpotInstanceRequests":
+		s.describeSpotInstanceRequests(w, r)
+	default:
+		writeError(w, http.StatusBadRequest, "InvalidAction",
+			"The action "+action+" is not valid for this web service.")
+	}
+}
+
+func (s *Server) runInstances(w http.ResponseWriter, r *http.Request) {
+	image := r.Form.Get("ImageId")
+	instanceType := r.Form.Get("InstanceType")
+	if image == "" || instanceType == "" {
+		writeError(w, http.StatusBadRequest, "MissingParameter",
+			"ImageId and InstanceType are required")
+		return
+	}
+
+	if !s.images[image] {
+		writeError(w, http.StatusBadRequest, "InvalidAMIID.NotFound",
+			"The image id '["+image+"]' does not exist")
+		return
+	}
+
+	if r.Form.Get("InstanceMarketOptions.MarketType") != "spot" {
+		writeError(w, http.StatusBadRequest, "InvalidParameterValue",
+			"only spot instances are supported by the stand-in")
+		return
+	}
+
+	for _, t := range s.NoCapacity {
+		if t == instanceType {
+			writeError(w, http.StatusBadRequest, "InsufficientInstanceCapacity",
+				"There is no Spot capacity available that matches your request.")
+			return
+		}
+	}
+
+	if s.InstanceLimit > 0 && s.liveInstances() >= s.InstanceLimit {
+		writeError(w, http.StatusBadRequest, "MaxSpotInstanceCountExceeded",
+			"Max spot instance count exceeded")
+		return
+	}
+
+	tags := []Tag{}
+	for n := 1; r.Form.Get(fmt.Sprintf("TagSpecification.1.Tag.%d.Key", n)) != ""; n++ {
+		tags = append(tags, Tag{
+			Key:   r.Form.Get(fmt.Sprintf("TagSpecification.1.Tag.%d.Key", n)),
+			Value: r.Form.Get(fmt.Sprintf("TagSpecification.1.Tag.%d.Value", n)),
+		})
+	}
+
+	i := s.newInstance(image, instanceType, r.Form.Get("SubnetId"), r.Form.Get("KeyName"), tags)
+	writeXML(w, "RunInstancesResponse", reservation{
+		ReservationID: "r-" + strings.TrimPrefix(i.InstanceID, "i-"),
+		OwnerID:       "123456789012",
+		Instances:     []Instance{s.view(i)},
+	})
+}
+
+func (s *Server) describeInstances(w http.ResponseWriter, r *http.Request) {
+	ids := values(r, "InstanceId")
+	for _, id := range ids {
+		if _, ok := s.instances[id]; !ok {
+			writeError(w, http.StatusBadRequest, "InvalidInstanceID.NotFound",
+				"The instance ID '"+id+"' does not exist")
+			return
+		}
+	}
+
+	reservations := []reservation{}
+	for _, id := range s.instanceIDs() {
+		i := s.instances[id]
+		if len(ids) != 0 && !contains(ids, id) {
+			continue
+		}
+
+		matched, err := s.matches(r, i)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidParameterValue", err.Error())
+			return
+		}
+		if matched {
+			reservations = append(reservations, reservation{
+				ReservationID: "r-" + strings.TrimPrefix(id, "i-"),
+				OwnerID:       "123456789012",
+				Instances:     []Instance{s.view(i)},
+			})
+		}
+	}
+
+	start, _ := strconv.Atoi(r.Form.Get("NextToken"))
+	if start > len(reservations) {
+		start = len(reservations)
+	}
+	end := len(reservations)
+	nextToken := ""
+	if s.PageSize > 0 && start+s.PageSize < end {
+		end = start + s.PageSize
+		nextToken = strconv.Itoa(end)
+	}
+
+	writeXML(w, "DescribeInstancesResponse", struct {
+		Reservations []reservation `xml:"reservationSet>item"`
+		NextToken    string        `xml:"nextToken,omitempty"`
+	}{
+		Reservations: reservations[start:end],
+		NextToken:    nextToken,
+	})
+}
+
+func (s *Server) terminateInstances(w http.ResponseWriter, r *http.Request) {
+	changes := []stateChange{}
+	for _, id := range values(r, "InstanceId") {
+		i, ok := s.instances[id]
+		if !ok {
+			writeError(w, http.StatusBadRequest, "InvalidInstanceID.NotFound",
+				"The instance ID '"+id+"' does not exist")
+			return
+		}
+
+		previous := s.view(i).State
+		i.State = State{Code: stateCodeTerminated, Name: "terminated"}
+		changes = append(changes, stateChange{
+			InstanceID:    id,
+			CurrentState:  i.State,
+			PreviousState: previous,
+		})
+	}
+
+	writeXML(w, "TerminateInstancesResponse", struct {
+		Instances []stateChange `xml:"instancesSet>item"`
+	}{
+		Instances: changes,
+	})
+}
+
+func (s *Server) rebootInstances(w http.ResponseWriter, r *http.Request) {
+	for _, id := range values(r, "InstanceId") {
+		if _, ok := s.instances[id]; !ok {
+			writeError(w, http.StatusBadRequest, "InvalidInstanceID.NotFound",
+				"The instance ID '"+id+"' does not exist")
+			return
+		}
+	}
+	writeReturn(w, "RebootInstancesResponse")
+}
+
+func (s *Server) createTags(w http.ResponseWriter, r *http.Request) {
+	for _, id := range values(r, "ResourceId") {
+		if _, ok := s.instances[id]; !ok {
+			writeError(w, http.StatusBadRequest, "InvalidInstanceID.NotFound",
+				"The instance ID '"+id+"' does not exist")
+			return
+		}
+	}
+
+	for _, id := range values(r, "ResourceId") {
+		i := s.instances[id]
+		for n := 1; r.Form.Get(fmt.Sprintf("Tag.%d.Key", n)) != ""; n++ {
+			i.Tags = setTag(i.Tags, Tag{
+				Key:   r.Form.Get(fmt.Sprintf("Tag.%d.Key", n)),
+				Value: r.Form.Get(fmt.Sprintf("Tag.%d.Value", n)),
+			})
+		}
+	}
+	writeReturn(w, "CreateTagsResponse")
+}
+
+func (s *Server) describeSpotInstanceRequests(w http.ResponseWriter, r *http.Request) {
+	requests := []spotRequest{}
+	for _, requestID := range values(r, "SpotInstanceRequestId") {
+		var found *instance
+		for _, i := range s.instances {
+			if i.SpotInstanceRequestID == requestID {
+				found = i
+			}
+		}
+		if found == nil {
+			writeError(w, http.StatusBadRequest, "InvalidSpotInstanceRequestID.NotFound",
+				"The spot instance request ID '"+requestID+"' does not exist")
+			return
+		}
+
+		requests = append(requests, spotRequest{
+			SpotInstanceRequestID: requestID,
+			State:                 "active",
+			Status: spotStatus{
+				Code:       found.spotStatus,
+				UpdateTime: found.spotStatusTime.UTC().Format(time.RFC3339),
+				Message:    "spot request status is " + found.spotStatus,
+			},
+			InstanceID: found.InstanceID,
+		})
+	}
+
+	writeXML(w, "DescribeSpotInstanceRequestsResponse", struct {
+		Requests []spotRequest `xml:"spotInstanceRequestSet>item"`
+	}{
+		Requests: requests,
+	})
+}
+
+// matches returns true if an instance matches the filters of a describe
+// request. Only the tag-key, tag:<key> and instance-state-name filters are
+// supported.
+func (s *Server) matches(r *http.Request, i *instance) (bool, error) {
+	view := s.view(i)
+	for n := 1; r.Form.Get(fmt.Sprintf("Filter.%d.Name", n)) != ""; n++ {
+		name := r.Form.Get(fmt.Sprintf("Filter.%d.Name", n))
+		filterValues := values(r, fmt.Sprintf("Filter.%d.Value", n))
+
+		matched := false
+		switch {
+		case name == "tag-key":
+			for _, tag := range view.Tags {
+				matched = matched || contains(filterValues, tag.Key)
+			}
+		case strings.HasPrefix(name, "tag:"):
+			for _, tag := range view.Tags {
+				matched = matched || (tag.Key == strings.TrimPrefix(name, "tag:") &&
+					contains(filterValues, tag.Value))
+			}
+		case name == "instance-state-name":
+			matched = contains(filterValues, view.State.Name)
+		default:
+			return false, fmt.Errorf("the filter '%s' is invalid", name)
+		}
+
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (s *Server) newInstance(image, instanceType, subnet, keyName string, tags []Tag) *instance {
+	s.lastID++
+	n := s.lastID
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	i := &instance{
+		Instance: Instance{
+			InstanceID:            fmt.Sprintf("i-%017x", n),
+			ImageID:               image,
+			State:                 State{Code: stateCodePending, Name: "pending"},
+			InstanceType:          instanceType,
+			KeyName:               keyName,
+			Placement:             Placement{AvailabilityZone: region + "a"},
+			SubnetID:              subnet,
+			PrivateIPAddress:      fmt.Sprintf("10.0.%d.%d", n/256%256, n%256),
+			PublicIPAddress:       fmt.Sprintf("198.51.100.%d", n%256),
+			InstanceLifecycle:     "spot",
+			SpotInstanceRequestID: fmt.Sprintf("sir-%08d", n),
+			Tags:                  append([]Tag{}, tags...),
+		},
+		createdAt:      time.Now(),
+		spotStatus:     "fulfilled",
+		spotStatusTime: time.Now(),
+	}
+	s.instances[i.InstanceID] = i
+	return i
+}
+
+// view returns an instance as it is seen through the api. New instances
+// are running after the boot delay.
+func (s *Server) view(i *instance) Instance {
+	if i.State.Code == stateCodePending && time.Since(i.createdAt) >= s.BootDelay {
+		i.State = State{Code: stateCodeRunning, Name: "running"}
+	}
+
+	view := i.Instance
+	view.Tags = append([]Tag{}, i.Tags...)
+	return view
+}
+
+// instanceIDs returns the ids of all instances in the order of launch
+func (s *Server) instanceIDs() []string {
+	ids := []string{}
+	for n := 1; n <= s.lastID; n++ {
+		id := fmt.Sprintf("i-%017x", n)
+		if _, ok := s.instances[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// liveInstances returns the number of instances which are not terminated
+func (s *Server) liveInstances() int {
+	count := 0
+	for _, i := range s.instances {
+		if i.State.Code != stateCodeTerminated {
+			count++
+		}
+	}
+	return count
+}
+
+// values returns the members of a list parameter, e.g. InstanceId.1,
+// InstanceId.2 and so on
+func values(r *http.Request, name string) []string {
+	list := []string{}
+	for n := 1; r.Form.Get(fmt.Sprintf("%s.%d", name, n)) != ""; n++ {
+		list = append(list, r.Form.Get(fmt.Sprintf("%s.%d", name, n)))
+	}
+	return list
+}
+
+func nameTags(name string, tags []string) []Tag {
+	list := []Tag{{Key: "Name", Value: name}}
+	for _, tag := range tags {
+		list = append(list, Tag{Key: tag})
+	}
+	return list
+}
+
+func setTag(tags []Tag, tag Tag) []Tag {
+	for i := range tags {
+		if tags[i].Key == tag.Key {
+			tags[i].Value = tag.Value
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// writeXML writes a response of an action, body is written within the
+// root element of the response along with a request id
+func writeXML(w http.ResponseWriter, root string, body interface{}) {
+	var content strings.Builder
+	err := xml.NewEncoder(&content).EncodeElement(body,
+		xml.StartElement{Name: xml.Name{Local: root}})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	// Root element of the body is written along with the namespace and
+	// the request id.
+	inner := content.String()
+	if start := strings.Index(inner, ">"); start != -1 {
+		inner = inner[start+1:]
+	}
+	if end := strings.LastIndex(inner, "</"); end != -1 {
+		inner = inner[:end]
+	}
+
+	w.Header().Set("Content-Type", "text/xml;charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `%s<%s xmlns="%s"><requestId>%s</requestId>%s</%s>`,
+		xml.Header, root, xmlNamespace, requestID(), inner, root)
+}
+
+func writeReturn(w http.ResponseWriter, root string) {
+	writeXML(w, root, struct {
+		Return bool `xml:"return"`
+	}{
+		Return: true,
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "text/xml;charset=UTF-8")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `%s<Response><Errors><Error><Code>%s</Code><Message>%s</Message></Error></Errors><RequestID>%s</RequestID></Response>`,
+		xml.Header, code, escape(message), requestID())
+}
+
+func escape(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+func requestID() string {
+	return fmt.Sprintf("%08x-0000-4000-8000-000000000000", time.Now().UnixNano()&0xffffffff)
+}
@@ -0,0 +1,118 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+var (
+	_ provider.InstanceProvider     = &Client{}
+	_ provider.Adopter              = &Client{}
+	_ provider.IDDeleter            = &Client{}
+	_ provider.InterruptionNotifier = &Client{}
+)
+
+// accessKey is the json kept in the credentials secret of a pool
+type accessKey struct {
+	AccessKeyID     string `json:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey"`
+	SessionToken    string `json:"sessionToken,omitempty"`
+}
+
+func init() {
+	provider.Register(provider.AWS, &provider.Registration{
+		New:              newProvider,
+		Template:         template,
+		Account:          account,
+		SSHUser:          sshUser,
+		ProviderIDPrefix: provider.AWSProviderID,
+	})
+}
+
+// newProvider returns an ec2 client for the credentials of a pool
+func newProvider(pool *spotcluster.Pool) (provider.InstanceProvider, error) {
+	return newClient(pool)
+}
+
+// template returns the instance config asked for by a pool. Size is not
+// given as any of the instance types of the pool can be launched.
+func template(pool *spotcluster.Pool) provider.InstanceConfig {
+	return provider.InstanceConfig{
+		Region: pool.ProviderSpec.AWS.Region,
+		Image:  pool.ProviderSpec.AWS.AMI,
+	}
+}
+
+// account returns the credentials secret and the region of a pool
+func account(pool *spotcluster.Pool) string {
+	spec := pool.ProviderSpec.AWS
+	if spec.CredentialsSecret == nil {
+		return spec.Region
+	}
+	return spec.CredentialsSecret.Namespace + "/" + spec.CredentialsSecret.Name +
+		"/" + spec.Region
+}
+
+// sshUser returns the user of the ami of a pool
+func sshUser(pool *spotcluster.Pool) string {
+	if pool.ProviderSpec.AWS.SSHUser != "" {
+		return pool.ProviderSpec.AWS.SSHUser
+	}
+	return provider.AWSDefaultUser
+}
+
+// newClient returns an ec2 client for the credentials of a pool. Default
+// credential chain is used if the pool has no credentials secret.
+func newClient(pool *spotcluster.Pool) (*Client, error) {
+	spec := pool.ProviderSpec.AWS
+	if spec == nil {
+		return nil, errors.Errorf("pool %s has no aws provider spec", pool.GetName())
+	}
+
+	if spec.Region == "" {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Errorf("pool %s has no aws region", pool.GetName()))
+	}
+
+	config := aws.NewConfig().WithRegion(spec.Region)
+	if spec.CredentialsSecret != nil {
+		data, err := provider.ReadSecret(context.TODO(), spec.CredentialsSecret)
+		if err != nil {
+			return nil, err
+		}
+
+		key := accessKey{}
+		if err := json.Unmarshal(data, &key); err != nil {
+			return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+				errors.Wrap(err, "failed to decode aws access key"))
+		}
+		if key.AccessKeyID == "" || key.SecretAccessKey == "" {
+			return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+				errors.New("aws access key has no accessKeyId or secretAccessKey"))
+		}
+
+		config = config.WithCredentials(credentials.NewStaticCredentials(
+			key.AccessKeyID, key.SecretAccessKey, key.SessionToken))
+	}
+	if spec.Endpoint != "" {
+		config = config.WithEndpoint(spec.Endpoint)
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	}
+
+	return &Client{
+		Provider: ec2.New(sess),
+		spec:     *spec,
+	}, nil
+}
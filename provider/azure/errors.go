@@ -0,0 +1,91 @@
+package azure
+
+import (
+	"net/http"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+// classify wraps an azure api error with its error class
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if isCapacityError(err) {
+		return provider.NewError(provider.ErrorQuota, 0, err)
+	}
+
+	statusCode, code := errorCode(err)
+	switch code {
+	case "TooManyRequests", "SubscriptionRequestsThrottled":
+		return provider.NewError(provider.ErrorThrottled, 0, err)
+	case "QuotaExceeded", "OperationNotAllowed":
+		return provider.NewError(provider.ErrorQuota, 0, err)
+	case "AuthorizationFailed", "InvalidAuthenticationToken", "InvalidParameter",
+		"ResourceGroupNotFound", "InvalidResourceReference", "ImageNotFound",
+		"PlatformImageNotFound", "InvalidSubscriptionId", "SubscriptionNotFound":
+		return provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	}
+
+	switch statusCode {
+	case http.StatusTooManyRequests:
+		return provider.NewError(provider.ErrorThrottled, 0, err)
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden:
+		return provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	case http.StatusNotFound:
+		return provider.NewError(provider.ErrorTerminal, 0, err)
+	}
+	return provider.NewError(provider.ErrorTransient, 0, err)
+}
+
+// isCapacityError returns true if there is no spot capacity for a vm size
+func isCapacityError(err error) bool {
+	_, code := errorCode(err)
+	return isCapacityCode(code)
+}
+
+// isCapacityCode returns true if an error code tells that there is no spot
+// capacity for a vm size
+func isCapacityCode(code string) bool {
+	switch code {
+	case "SkuNotAvailable", "AllocationFailed", "ZonalAllocationFailed",
+		"OverconstrainedAllocationRequest", "OverconstrainedZonalAllocationRequest":
+		return true
+	}
+	return false
+}
+
+// isNotFound returns true if the resource is not found
+func isNotFound(err error) bool {
+	statusCode, code := errorCode(err)
+	return statusCode == http.StatusNotFound ||
+		code == "ResourceNotFound" || code == "NotFound"
+}
+
+// errorCode returns the http status code and the service error code of an
+// azure api error. Errors of long running operations only have the service
+// error code.
+func errorCode(err error) (int, string) {
+	statusCode := 0
+	if detailed, ok := err.(autorest.DetailedError); ok {
+		if code, ok := detailed.StatusCode.(int); ok {
+			statusCode = code
+		}
+		err = detailed.Original
+	}
+
+	switch e := err.(type) {
+	case *azure.RequestError:
+		if e.ServiceError != nil {
+			return statusCode, e.ServiceError.Code
+		}
+	case *azure.ServiceError:
+		return statusCode, e.Code
+	case azure.ServiceError:
+		return statusCode, e.Code
+	}
+	return statusCode, ""
+}
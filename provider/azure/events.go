@@ -0,0 +1,140 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/shovanmaity/spotcluster/remotedial"
+)
+
+const (
+	// scheduledEventsCommand reads the scheduled events of a vm from the
+	// instance metadata service which is only reachable from the vm.
+	scheduledEventsCommand = "curl -sf -H Metadata:true " +
+		"'http://169.254.169.254/metadata/scheduledevents?api-version=2019-08-01'"
+	// preemptEvent is scheduled before a spot vm is evicted
+	preemptEvent = "Preempt"
+)
+
+// scheduledEvents is the response of the scheduled events api
+type scheduledEvents struct {
+	Events []struct {
+		EventID   string `json:"EventId"`
+		EventType string `json:"EventType"`
+		NotBefore string `json:"NotBefore"`
+	} `json:"Events"`
+}
+
+// Interruption returns a notice once azure schedules the eviction of a spot
+// vm. Scheduled events are read from the vm over ssh. Vm which is already
+// deallocated or is being deleted as per the eviction policy is evicted
+// right away, it is found from the instance view.
+func (c *Client) Interruption(ctx context.Context,
+	id string) (*provider.InterruptionNotice, error) {
+	resourceGroup, name, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	vm, err := c.vms.Get(ctx, resourceGroup, name, compute.InstanceView)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, classify(err)
+	}
+
+	if vm.VirtualMachineProperties == nil || vm.Priority != compute.Spot {
+		return nil, nil
+	}
+
+	if strings.EqualFold(to.String(vm.ProvisioningState), "Deleting") {
+		return &provider.InterruptionNotice{
+			Time:   time.Now(),
+			Reason: "vm is evicted, it is being deleted",
+		}, nil
+	}
+
+	switch state := powerState(vm); state {
+	case powerRunning:
+	case powerDeallocated, "deallocating", "stopped", "stopping":
+		return &provider.InterruptionNotice{
+			Time:   time.Now(),
+			Reason: "vm is evicted, power state is " + state,
+		}, nil
+	default:
+		return nil, nil
+	}
+
+	config, err := c.toInstanceConfig(ctx, vm)
+	if err != nil {
+		return nil, err
+	}
+	if config.ExteralIP == "" {
+		return nil, nil
+	}
+	return c.scheduledEviction(ctx, net.JoinHostPort(config.ExteralIP, "22"))
+}
+
+// scheduledEviction does a ssh into the vm and returns a notice if an
+// eviction is scheduled
+func (c *Client) scheduledEviction(ctx context.Context,
+	address string) (*provider.InterruptionNotice, error) {
+	client, err := remotedial.NewSSHClientWithContext(ctx, sshUser(c.spec), address)
+	if err != nil {
+		return nil, provider.NewError(provider.ErrorTransient, 0,
+			errors.Wrap(err, "failed to read scheduled events"))
+	}
+
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, provider.NewError(provider.ErrorTransient, 0,
+			errors.Wrap(err, "failed to read scheduled events"))
+	}
+
+	defer session.Close()
+
+	var stdout bytes.Buffer
+	session.Stdout = &stdout
+	if err := session.Run(scheduledEventsCommand); err != nil {
+		return nil, provider.NewError(provider.ErrorTransient, 0,
+			errors.Wrap(err, "failed to read scheduled events"))
+	}
+	return preemption(stdout.Bytes())
+}
+
+// preemption returns a notice if the scheduled events of a vm have a
+// preempt event
+func preemption(data []byte) (*provider.InterruptionNotice, error) {
+	events := scheduledEvents{}
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, errors.Wrap(err, "failed to decode scheduled events")
+	}
+
+	for _, event := range events.Events {
+		if event.EventType != preemptEvent {
+			continue
+		}
+
+		// NotBefore is empty once the event has started.
+		noticeTime := time.Now()
+		if notBefore, err := time.Parse(time.RFC1123, event.NotBefore); err == nil {
+			noticeTime = notBefore
+		}
+		return &provider.InterruptionNotice{
+			Time:   noticeTime,
+			Reason: "eviction is scheduled by event " + event.EventID,
+		}, nil
+	}
+	return nil, nil
+}
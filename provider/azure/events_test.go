@@ -0,0 +1,67 @@
+package azure
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPreemption(t *testing.T) {
+	notBefore := time.Now().Add(30 * time.Second).UTC().Truncate(time.Second)
+
+	tests := map[string]struct {
+		events     string
+		wantNotice bool
+		wantTime   time.Time
+		wantErr    bool
+	}{
+		"no event": {
+			events: `{"DocumentIncarnation": 1, "Events": []}`,
+		},
+		"other event": {
+			events: `{"Events": [{"EventId": "1", "EventType": "Reboot",
+				"NotBefore": "` + notBefore.Format(time.RFC1123) + `"}]}`,
+		},
+		"scheduled preempt event": {
+			events: `{"Events": [{"EventId": "2", "EventType": "Preempt",
+				"NotBefore": "` + notBefore.Format(time.RFC1123) + `"}]}`,
+			wantNotice: true,
+			wantTime:   notBefore,
+		},
+		"started preempt event": {
+			events:     `{"Events": [{"EventId": "3", "EventType": "Preempt", "NotBefore": ""}]}`,
+			wantNotice: true,
+		},
+		"invalid response": {
+			events:  `<html></html>`,
+			wantErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			notice, err := preemption([]byte(test.events))
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			if (notice != nil) != test.wantNotice {
+				t.Fatalf("expected notice %t, got %+v", test.wantNotice, notice)
+			}
+			if notice == nil {
+				return
+			}
+			if !test.wantTime.IsZero() && !notice.Time.Equal(test.wantTime) {
+				t.Fatalf("expected eviction at %s, got %s", test.wantTime, notice.Time)
+			}
+			if test.wantTime.IsZero() && time.Since(notice.Time) > time.Minute {
+				t.Fatalf("expected eviction now, got %s", notice.Time)
+			}
+		})
+	}
+}
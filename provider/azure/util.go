@@ -0,0 +1,132 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2020-05-01/network"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+var (
+	_ provider.InstanceProvider     = &Client{}
+	_ provider.Adopter              = &Client{}
+	_ provider.IDDeleter            = &Client{}
+	_ provider.InterruptionNotifier = &Client{}
+)
+
+// servicePrincipal is the json kept in the credentials secret of a pool
+type servicePrincipal struct {
+	TenantID     string `json:"tenantId"`
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	// ActiveDirectoryURL is the public cloud login url if empty
+	ActiveDirectoryURL string `json:"activeDirectoryEndpointUrl,omitempty"`
+}
+
+func init() {
+	provider.Register(provider.Azure, &provider.Registration{
+		New:      newProvider,
+		Template: template,
+		Account:  account,
+		SSHUser: func(pool *spotcluster.Pool) string {
+			return sshUser(*pool.ProviderSpec.Azure)
+		},
+		ProviderIDPrefix: provider.AzureProviderID,
+	})
+}
+
+// newProvider returns an azure client for the credentials of a pool
+func newProvider(pool *spotcluster.Pool) (provider.InstanceProvider, error) {
+	return newClient(pool)
+}
+
+// template returns the vm config asked for by a pool. Size is not given
+// as any of the vm sizes of the pool can be launched.
+func template(pool *spotcluster.Pool) provider.InstanceConfig {
+	return provider.InstanceConfig{
+		Region: pool.ProviderSpec.Azure.Location,
+		Image:  pool.ProviderSpec.Azure.Image,
+	}
+}
+
+// account returns the subscription and the resource group of a pool
+func account(pool *spotcluster.Pool) string {
+	return pool.ProviderSpec.Azure.SubscriptionID + "/" + pool.ProviderSpec.Azure.ResourceGroup
+}
+
+// sshUser returns the admin user of the vms of a pool
+func sshUser(spec spotcluster.Azure) string {
+	if spec.SSHUser != "" {
+		return spec.SSHUser
+	}
+	return provider.AzureDefaultUser
+}
+
+// newClient returns an azure client for the credentials of a pool. Api
+// stand-in is used without authentication if the pool has no credentials.
+func newClient(pool *spotcluster.Pool) (*Client, error) {
+	spec := pool.ProviderSpec.Azure
+	if spec == nil {
+		return nil, errors.Errorf("pool %s has no azure provider spec", pool.GetName())
+	}
+
+	if spec.SubscriptionID == "" || spec.ResourceGroup == "" {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Errorf("pool %s has no azure subscription or resource group", pool.GetName()))
+	}
+
+	baseURL := azure.PublicCloud.ResourceManagerEndpoint
+	if spec.ResourceManagerURL != "" {
+		baseURL = spec.ResourceManagerURL
+	}
+
+	var authorizer autorest.Authorizer
+	switch {
+	case spec.CredentialsSecret != nil:
+		data, err := provider.ReadSecret(context.TODO(), spec.CredentialsSecret)
+		if err != nil {
+			return nil, err
+		}
+
+		principal := servicePrincipal{}
+		if err := json.Unmarshal(data, &principal); err != nil {
+			return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+				errors.Wrap(err, "failed to decode azure service principal"))
+		}
+
+		config := auth.NewClientCredentialsConfig(principal.ClientID,
+			principal.ClientSecret, principal.TenantID)
+		if principal.ActiveDirectoryURL != "" {
+			config.AADEndpoint = principal.ActiveDirectoryURL
+		}
+		authorizer, err = config.Authorizer()
+		if err != nil {
+			return nil, provider.NewError(provider.ErrorInvalidConfig, 0, err)
+		}
+	case spec.ResourceManagerURL != "":
+		authorizer = autorest.NullAuthorizer{}
+	default:
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Errorf("pool %s has no azure credentials secret", pool.GetName()))
+	}
+
+	c := &Client{
+		vms:        compute.NewVirtualMachinesClientWithBaseURI(baseURL, spec.SubscriptionID),
+		disks:      compute.NewDisksClientWithBaseURI(baseURL, spec.SubscriptionID),
+		interfaces: network.NewInterfacesClientWithBaseURI(baseURL, spec.SubscriptionID),
+		publicIPs:  network.NewPublicIPAddressesClientWithBaseURI(baseURL, spec.SubscriptionID),
+		spec:       *spec,
+	}
+	c.vms.Authorizer = authorizer
+	c.disks.Authorizer = authorizer
+	c.interfaces.Authorizer = authorizer
+	c.publicIPs.Authorizer = authorizer
+	return c, nil
+}
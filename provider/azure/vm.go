@@ -0,0 +1,662 @@
+package azure
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2020-05-01/network"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+const (
+	// operationTimeout is the time a long running operation is waited for
+	operationTimeout = 5 * time.Minute
+
+	powerStatePrefix = "PowerState/"
+	powerRunning     = "running"
+	powerDeallocated = "deallocated"
+
+	// Status of a vm which is not provisioned is its provisioning state
+	statusCreating = "creating"
+	statusUpdating = "updating"
+	statusFailed   = "failed"
+
+	// provisioningFailedPrefix is the prefix of the instance view status of
+	// a vm which failed to provision, it is followed by the error code
+	provisioningFailedPrefix = "ProvisioningState/failed/"
+)
+
+// Client launches azure spot vms for a pool. Every vm has its own public
+// ip, network interface and os disk which are named after the vm. Vm id
+// is the resource id of the vm.
+type Client struct {
+	vms        compute.VirtualMachinesClient
+	disks      compute.DisksClient
+	interfaces network.InterfacesClient
+	publicIPs  network.PublicIPAddressesClient
+	spec       spotcluster.Azure
+}
+
+// Create launches a spot vm. It returns once azure accepts the vm, which is
+// not running yet. Vm sizes of the pool are tried in order until one of
+// them is accepted, vm which fails to allocate later is moved to the next
+// size by Get.
+func (c *Client) Create(ctx context.Context,
+	config provider.InstanceConfig) (*provider.InstanceConfig, error) {
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+
+	if len(c.spec.VMSizes) == 0 {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.New("no vm size is given"))
+	}
+
+	tags := make(map[string]*string)
+	for _, tag := range config.Tags {
+		tags[tag] = to.StringPtr("")
+	}
+
+	nicID, err := c.createNetwork(ctx, config.Name, tags)
+	if err != nil {
+		if deleteErr := c.deleteResources(ctx, c.spec.ResourceGroup, config.Name); deleteErr != nil {
+			return nil, deleteErr
+		}
+		return nil, classify(err)
+	}
+
+	var lastErr error
+	for _, size := range c.spec.VMSizes {
+		err := c.createVM(ctx, config, size, nicID, tags)
+		if err == nil {
+			// Vm is found as soon as the create is accepted.
+			vm, err := c.vms.Get(ctx, c.spec.ResourceGroup, config.Name, compute.InstanceView)
+			if err != nil {
+				return nil, classify(err)
+			}
+			return c.toInstanceConfig(ctx, vm)
+		}
+
+		// Try the next vm size only if there is no capacity, other errors
+		// are the same for all of them.
+		lastErr = err
+		if !isCapacityError(err) {
+			break
+		}
+	}
+
+	// Network resources of a vm which could not be created are not found
+	// by any tag, they are removed right away.
+	if err := c.deleteResources(ctx, c.spec.ResourceGroup, config.Name); err != nil {
+		return nil, err
+	}
+	return nil, classify(lastErr)
+}
+
+// createNetwork creates the public ip and the network interface of a vm
+// and returns the id of the network interface
+func (c *Client) createNetwork(ctx context.Context, name string,
+	tags map[string]*string) (string, error) {
+	ipFuture, err := c.publicIPs.CreateOrUpdate(ctx, c.spec.ResourceGroup, publicIPName(name),
+		network.PublicIPAddress{
+			Location: to.StringPtr(c.spec.Location),
+			Tags:     tags,
+			PublicIPAddressPropertiesFormat: &network.PublicIPAddressPropertiesFormat{
+				PublicIPAllocationMethod: network.Static,
+			},
+		})
+	if err != nil {
+		return "", err
+	}
+	if err := ipFuture.WaitForCompletionRef(ctx, c.publicIPs.Client); err != nil {
+		return "", err
+	}
+	publicIP, err := ipFuture.Result(c.publicIPs)
+	if err != nil {
+		return "", err
+	}
+
+	nicFuture, err := c.interfaces.CreateOrUpdate(ctx, c.spec.ResourceGroup, interfaceName(name),
+		network.Interface{
+			Location: to.StringPtr(c.spec.Location),
+			Tags:     tags,
+			InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
+				IPConfigurations: &[]network.InterfaceIPConfiguration{
+					{
+						Name: to.StringPtr("ipconfig"),
+						InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{
+							Subnet:                    &network.Subnet{ID: to.StringPtr(c.spec.Subnet)},
+							PrivateIPAllocationMethod: network.Dynamic,
+							PublicIPAddress:           &network.PublicIPAddress{ID: publicIP.ID},
+						},
+					},
+				},
+			},
+		})
+	if err != nil {
+		return "", err
+	}
+	if err := nicFuture.WaitForCompletionRef(ctx, c.interfaces.Client); err != nil {
+		return "", err
+	}
+	nic, err := nicFuture.Result(c.interfaces)
+	if err != nil {
+		return "", err
+	}
+	return to.String(nic.ID), nil
+}
+
+// createVM asks azure to create a spot vm of a size and returns without
+// waiting for the vm to be allocated
+func (c *Client) createVM(ctx context.Context, config provider.InstanceConfig,
+	size, nicID string, tags map[string]*string) error {
+	imageReference, err := parseImage(config.Image)
+	if err != nil {
+		return err
+	}
+
+	evictionPolicy := compute.Delete
+	if strings.EqualFold(c.spec.EvictionPolicy, string(compute.Deallocate)) {
+		evictionPolicy = compute.Deallocate
+	}
+
+	maxPrice := float64(-1)
+	if c.spec.MaxPrice != "" {
+		maxPrice, err = strconv.ParseFloat(c.spec.MaxPrice, 64)
+		if err != nil {
+			return provider.NewError(provider.ErrorInvalidConfig, 0,
+				errors.Wrapf(err, "invalid max price %s", c.spec.MaxPrice))
+		}
+	}
+
+	user := sshUser(c.spec)
+	linuxConfiguration := &compute.LinuxConfiguration{
+		DisablePasswordAuthentication: to.BoolPtr(true),
+	}
+	if c.spec.SSHPublicKey != "" {
+		linuxConfiguration.SSH = &compute.SSHConfiguration{
+			PublicKeys: &[]compute.SSHPublicKey{
+				{
+					Path:    to.StringPtr("/home/" + user + "/.ssh/authorized_keys"),
+					KeyData: to.StringPtr(strings.TrimSpace(c.spec.SSHPublicKey)),
+				},
+			},
+		}
+	}
+
+	_, err = c.vms.CreateOrUpdate(ctx, c.spec.ResourceGroup, config.Name, compute.VirtualMachine{
+		Location: to.StringPtr(c.spec.Location),
+		Tags:     tags,
+		VirtualMachineProperties: &compute.VirtualMachineProperties{
+			Priority:       compute.Spot,
+			EvictionPolicy: evictionPolicy,
+			BillingProfile: &compute.BillingProfile{
+				MaxPrice: to.Float64Ptr(maxPrice),
+			},
+			HardwareProfile: &compute.HardwareProfile{
+				VMSize: compute.VirtualMachineSizeTypes(size),
+			},
+			StorageProfile: &compute.StorageProfile{
+				ImageReference: imageReference,
+				OsDisk: &compute.OSDisk{
+					Name:         to.StringPtr(diskName(config.Name)),
+					CreateOption: compute.DiskCreateOptionTypesFromImage,
+				},
+			},
+			OsProfile: &compute.OSProfile{
+				ComputerName:       to.StringPtr(config.Name),
+				AdminUsername:      to.StringPtr(user),
+				LinuxConfiguration: linuxConfiguration,
+			},
+			NetworkProfile: &compute.NetworkProfile{
+				NetworkInterfaces: &[]compute.NetworkInterfaceReference{
+					{
+						ID: to.StringPtr(nicID),
+						NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{
+							Primary: to.BoolPtr(true),
+						},
+					},
+				},
+			},
+		},
+	})
+	return err
+}
+
+// Get returns the vm with the given tag. Vm which failed to allocate is
+// moved to the next vm size of the pool, vm which failed for any other
+// reason or has no next size is returned as a terminal error so that it
+// is replaced.
+func (c *Client) Get(ctx context.Context, tag string) (*provider.InstanceConfig, bool, error) {
+	vm, found, err := c.get(ctx, tag)
+	if err != nil || !found || vm.Status != statusFailed {
+		return vm, found, err
+	}
+
+	vm, err = c.resize(ctx, *vm)
+	if err != nil {
+		return nil, false, err
+	}
+	return vm, true, nil
+}
+
+// resize moves a vm which failed to allocate to the next vm size of the
+// pool
+func (c *Client) resize(ctx context.Context,
+	config provider.InstanceConfig) (*provider.InstanceConfig, error) {
+	resourceGroup, name, err := parseID(config.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	vm, err := c.vms.Get(ctx, resourceGroup, name, compute.InstanceView)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	code := provisioningError(vm)
+	if !isCapacityCode(code) {
+		return nil, provider.NewError(provider.ErrorTerminal, 0,
+			errors.Errorf("vm %s failed to provision: %s", name, code))
+	}
+
+	size := nextSize(c.spec.VMSizes, config.Size)
+	if size == "" {
+		return nil, provider.NewError(provider.ErrorTerminal, 0,
+			errors.Errorf("vm %s failed to allocate with every vm size: %s", name, code))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+
+	_, err = c.vms.Update(ctx, resourceGroup, name, compute.VirtualMachineUpdate{
+		VirtualMachineProperties: &compute.VirtualMachineProperties{
+			HardwareProfile: &compute.HardwareProfile{
+				VMSize: compute.VirtualMachineSizeTypes(size),
+			},
+		},
+	})
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	config.Size = size
+	config.Status = statusUpdating
+	config.IsRunning = false
+	return &config, nil
+}
+
+// get returns the vm with the given tag as it is
+func (c *Client) get(ctx context.Context, tag string) (*provider.InstanceConfig, bool, error) {
+	list, err := c.List(ctx, tag)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(list) == 0 {
+		return nil, false, nil
+	}
+
+	if len(list) != 1 {
+		return nil, false,
+			errors.Errorf("Got %d vms for the given tag %s", len(list), tag)
+	}
+	return &list[0], true, nil
+}
+
+// Delete deletes the vm with the given tag along with its resources
+func (c *Client) Delete(ctx context.Context, tag string) error {
+	vm, found, err := c.get(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return nil
+	}
+	return c.DeleteByID(ctx, vm.ID)
+}
+
+// List returns all the vms of the resource group with the given tag
+func (c *Client) List(ctx context.Context, tag string) ([]provider.InstanceConfig, error) {
+	list := []provider.InstanceConfig{}
+	iterator, err := c.vms.ListComplete(ctx, c.spec.ResourceGroup)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	for ; iterator.NotDone(); err = iterator.NextWithContext(ctx) {
+		if err != nil {
+			return nil, classify(err)
+		}
+
+		vm := iterator.Value()
+		if _, ok := vm.Tags[tag]; !ok {
+			continue
+		}
+
+		// Listed vms do not have the instance view which has the power
+		// state of the vm.
+		vm, err = c.vms.Get(ctx, c.spec.ResourceGroup, to.String(vm.Name), compute.InstanceView)
+		if err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return nil, classify(err)
+		}
+
+		config, err := c.toInstanceConfig(ctx, vm)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *config)
+	}
+	return list, nil
+}
+
+// Reboot restarts the vm with the given id
+func (c *Client) Reboot(ctx context.Context, id string) error {
+	resourceGroup, name, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.vms.Restart(ctx, resourceGroup, name)
+	if err != nil {
+		return classify(err)
+	}
+	return nil
+}
+
+// Find returns the vms for given vm ids or tags
+func (c *Client) Find(ctx context.Context, refs []string) ([]provider.InstanceConfig, error) {
+	list := []provider.InstanceConfig{}
+	for _, ref := range refs {
+		if resourceGroup, name, err := parseID(ref); err == nil {
+			vm, err := c.vms.Get(ctx, resourceGroup, name, compute.InstanceView)
+			if err != nil {
+				if isNotFound(err) {
+					continue
+				}
+				return nil, classify(err)
+			}
+
+			config, err := c.toInstanceConfig(ctx, vm)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, *config)
+			continue
+		}
+
+		vms, err := c.List(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, vms...)
+	}
+	return list, nil
+}
+
+// Tag adds the given tags to a vm
+func (c *Client) Tag(ctx context.Context, id string, tags ...string) error {
+	resourceGroup, name, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	vm, err := c.vms.Get(ctx, resourceGroup, name, "")
+	if err != nil {
+		return classify(err)
+	}
+
+	vmTags := make(map[string]*string)
+	for key, value := range vm.Tags {
+		vmTags[key] = value
+	}
+	for _, tag := range tags {
+		vmTags[tag] = to.StringPtr("")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+
+	future, err := c.vms.Update(ctx, resourceGroup, name, compute.VirtualMachineUpdate{
+		Tags: vmTags,
+	})
+	if err != nil {
+		return classify(err)
+	}
+	if err := future.WaitForCompletionRef(ctx, c.vms.Client); err != nil {
+		return classify(err)
+	}
+	return nil
+}
+
+// DeleteByID deletes the vm with the given id along with its resources. Vm
+// is deleted from the resource group in its id, which is not the resource
+// group of the pool for an adopted vm.
+func (c *Client) DeleteByID(ctx context.Context, id string) error {
+	resourceGroup, name, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+
+	if err := c.deleteVM(ctx, resourceGroup, name); err != nil {
+		return err
+	}
+	return c.deleteResources(ctx, resourceGroup, name)
+}
+
+// deleteVM deletes a vm and waits until it is deleted
+func (c *Client) deleteVM(ctx context.Context, resourceGroup, name string) error {
+	future, err := c.vms.Delete(ctx, resourceGroup, name)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return classify(err)
+	}
+	if err := future.WaitForCompletionRef(ctx, c.vms.Client); err != nil && !isNotFound(err) {
+		return classify(err)
+	}
+	return nil
+}
+
+// deleteResources deletes the network interface, the public ip and the os
+// disk of a deleted vm. Missing resources are ignored.
+func (c *Client) deleteResources(ctx context.Context, resourceGroup, name string) error {
+	nicFuture, err := c.interfaces.Delete(ctx, resourceGroup, interfaceName(name))
+	if err == nil {
+		err = nicFuture.WaitForCompletionRef(ctx, c.interfaces.Client)
+	}
+	if err != nil && !isNotFound(err) {
+		return classify(err)
+	}
+
+	ipFuture, err := c.publicIPs.Delete(ctx, resourceGroup, publicIPName(name))
+	if err == nil {
+		err = ipFuture.WaitForCompletionRef(ctx, c.publicIPs.Client)
+	}
+	if err != nil && !isNotFound(err) {
+		return classify(err)
+	}
+
+	diskFuture, err := c.disks.Delete(ctx, resourceGroup, diskName(name))
+	if err == nil {
+		err = diskFuture.WaitForCompletionRef(ctx, c.disks.Client)
+	}
+	if err != nil && !isNotFound(err) {
+		return classify(err)
+	}
+	return nil
+}
+
+// toInstanceConfig returns the config of a vm along with the addresses of
+// its network interface
+func (c *Client) toInstanceConfig(ctx context.Context,
+	vm compute.VirtualMachine) (*provider.InstanceConfig, error) {
+	config := &provider.InstanceConfig{
+		ID:     to.String(vm.ID),
+		Name:   to.String(vm.Name),
+		Region: to.String(vm.Location),
+		Labels: make(map[string]string),
+	}
+
+	for key, value := range vm.Tags {
+		config.Tags = append(config.Tags, key)
+		config.Labels[key] = to.String(value)
+	}
+
+	properties := vm.VirtualMachineProperties
+	if properties == nil {
+		return config, nil
+	}
+
+	if properties.HardwareProfile != nil {
+		config.Size = string(properties.HardwareProfile.VMSize)
+	}
+
+	config.Status = powerState(vm)
+	config.IsRunning = config.Status == powerRunning
+	switch state := strings.ToLower(to.String(properties.ProvisioningState)); state {
+	case statusFailed, statusCreating, statusUpdating:
+		// Vm which is not provisioned yet has no power state.
+		config.Status = state
+		config.IsRunning = false
+	}
+
+	if properties.NetworkProfile == nil || properties.NetworkProfile.NetworkInterfaces == nil {
+		return config, nil
+	}
+
+	for _, reference := range *properties.NetworkProfile.NetworkInterfaces {
+		resourceGroup, name, err := parseResourceID(to.String(reference.ID))
+		if err != nil {
+			continue
+		}
+
+		nic, err := c.interfaces.Get(ctx, resourceGroup, name, "ipConfigurations/publicIPAddress")
+		if err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return nil, classify(err)
+		}
+
+		if nic.InterfacePropertiesFormat == nil || nic.IPConfigurations == nil {
+			continue
+		}
+		for _, ipConfiguration := range *nic.IPConfigurations {
+			ipProperties := ipConfiguration.InterfaceIPConfigurationPropertiesFormat
+			if ipProperties == nil {
+				continue
+			}
+			config.InternalIP = to.String(ipProperties.PrivateIPAddress)
+			if ipProperties.PublicIPAddress != nil &&
+				ipProperties.PublicIPAddress.PublicIPAddressPropertiesFormat != nil {
+				config.ExteralIP = to.String(ipProperties.PublicIPAddress.IPAddress)
+			}
+		}
+	}
+	return config, nil
+}
+
+// powerState returns the power state of a vm from its instance view
+func powerState(vm compute.VirtualMachine) string {
+	if vm.VirtualMachineProperties == nil || vm.InstanceView == nil ||
+		vm.InstanceView.Statuses == nil {
+		return ""
+	}
+
+	for _, status := range *vm.InstanceView.Statuses {
+		code := to.String(status.Code)
+		if strings.HasPrefix(code, powerStatePrefix) {
+			return strings.TrimPrefix(code, powerStatePrefix)
+		}
+	}
+	return ""
+}
+
+// provisioningError returns the error code of a vm which failed to
+// provision from its instance view
+func provisioningError(vm compute.VirtualMachine) string {
+	if vm.VirtualMachineProperties == nil || vm.InstanceView == nil ||
+		vm.InstanceView.Statuses == nil {
+		return ""
+	}
+
+	for _, status := range *vm.InstanceView.Statuses {
+		code := to.String(status.Code)
+		if strings.HasPrefix(code, provisioningFailedPrefix) {
+			return strings.TrimPrefix(code, provisioningFailedPrefix)
+		}
+	}
+	return ""
+}
+
+// nextSize returns the vm size after the given one, empty if it is the last
+func nextSize(sizes []string, size string) string {
+	for i := range sizes {
+		if strings.EqualFold(sizes[i], size) && i+1 < len(sizes) {
+			return sizes[i+1]
+		}
+	}
+	return ""
+}
+
+// parseImage returns the image reference for an image urn or resource id
+func parseImage(image string) (*compute.ImageReference, error) {
+	if strings.HasPrefix(image, "/") {
+		return &compute.ImageReference{ID: to.StringPtr(image)}, nil
+	}
+
+	parts := strings.Split(image, ":")
+	if len(parts) != 4 {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Errorf("invalid image %s, expected publisher:offer:sku:version", image))
+	}
+	return &compute.ImageReference{
+		Publisher: to.StringPtr(parts[0]),
+		Offer:     to.StringPtr(parts[1]),
+		Sku:       to.StringPtr(parts[2]),
+		Version:   to.StringPtr(parts[3]),
+	}, nil
+}
+
+// parseID returns the resource group and the name of a vm from its id
+func parseID(id string) (string, string, error) {
+	if !strings.Contains(strings.ToLower(id), "/providers/microsoft.compute/virtualmachines/") {
+		return "", "", errors.Errorf("invalid vm id %s", id)
+	}
+	return parseResourceID(id)
+}
+
+// parseResourceID returns the resource group and the name of a resource
+func parseResourceID(id string) (string, string, error) {
+	parts := strings.Split(strings.Trim(id, "/"), "/")
+	if len(parts) < 8 || !strings.EqualFold(parts[2], "resourceGroups") {
+		return "", "", errors.Errorf("invalid resource id %s", id)
+	}
+	return parts[3], parts[len(parts)-1], nil
+}
+
+func publicIPName(name string) string {
+	return name + "-ip"
+}
+
+func interfaceName(name string) string {
+	return name + "-nic"
+}
+
+func diskName(name string) string {
+	return name + "-osdisk"
+}
@@ -0,0 +1,74 @@
+package azure
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+func TestProvisioningError(t *testing.T) {
+	tests := map[string]struct {
+		statuses []string
+		want     string
+	}{
+		"no instance view": {},
+		"running vm": {
+			statuses: []string{"ProvisioningState/succeeded", "PowerState/running"},
+		},
+		"vm which failed to allocate": {
+			statuses: []string{"ProvisioningState/failed/AllocationFailed"},
+			want:     "AllocationFailed",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			vm := compute.VirtualMachine{
+				VirtualMachineProperties: &compute.VirtualMachineProperties{},
+			}
+			if test.statuses != nil {
+				statuses := []compute.InstanceViewStatus{}
+				for _, code := range test.statuses {
+					statuses = append(statuses, compute.InstanceViewStatus{Code: to.StringPtr(code)})
+				}
+				vm.InstanceView = &compute.VirtualMachineInstanceView{Statuses: &statuses}
+			}
+
+			if got := provisioningError(vm); got != test.want {
+				t.Fatalf("expected error code %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestNextSize(t *testing.T) {
+	sizes := []string{"Standard_D2s_v3", "Standard_D2as_v4"}
+	tests := map[string]struct {
+		size string
+		want string
+	}{
+		"first size": {
+			size: "Standard_D2s_v3",
+			want: "Standard_D2as_v4",
+		},
+		"size in another case": {
+			size: "standard_d2s_v3",
+			want: "Standard_D2as_v4",
+		},
+		"last size": {
+			size: "Standard_D2as_v4",
+		},
+		"size which is not in the pool": {
+			size: "Standard_B2s",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := nextSize(sizes, test.size); got != test.want {
+				t.Fatalf("expected next size %q, got %q", test.want, got)
+			}
+		})
+	}
+}
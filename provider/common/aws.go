@@ -0,0 +1,9 @@
+package common
+
+const (
+	// AWS is the name of aws provider
+	AWS            = "aws"
+	AWSDefaultUser = "ubuntu"
+	AWSProviderID  = "aws:///"
+	AWSNameTag     = "Name"
+)
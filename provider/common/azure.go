@@ -0,0 +1,8 @@
+package common
+
+const (
+	// Azure is the name of azure provider
+	Azure            = "azure"
+	AzureDefaultUser = "ubuntu"
+	AzureProviderID  = "azure://"
+)
@@ -0,0 +1,13 @@
+package common
+
+import (
+	"time"
+)
+
+// BootstrapResult is the transcript of a worker bootstrap
+type BootstrapResult struct {
+	Output    []byte
+	ExitCode  int
+	StartTime time.Time
+	Duration  time.Duration
+}
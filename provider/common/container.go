@@ -0,0 +1,8 @@
+package common
+
+const (
+	// Container is the name of local container provider
+	Container           = "container"
+	ContainerRootUser   = "root"
+	ContainerProviderID = "container://"
+)
@@ -1,8 +1,12 @@
 package common
 
+// DigitalOcean is the name of digitalocean provider
+const DigitalOcean = "digitalocean"
+
 const (
 	DoRootUser    = "root"
 	PublicIPType  = "public"
 	PrivateIPType = "private"
 	DropletActive = "active"
+	DoProviderID  = "digitalocean://"
 )
@@ -0,0 +1,75 @@
+package common
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorClass tells how an error should be handled
+type ErrorClass string
+
+const (
+	// ErrorTransient is retried with exponential backoff
+	ErrorTransient ErrorClass = "Transient"
+	// ErrorThrottled is retried after the time asked by the provider
+	ErrorThrottled ErrorClass = "Throttled"
+	// ErrorQuota is retried after a long delay
+	ErrorQuota ErrorClass = "Quota"
+	// ErrorInvalidConfig is not retried until the configuration is fixed
+	ErrorInvalidConfig ErrorClass = "InvalidConfig"
+	// ErrorTerminal is never retried
+	ErrorTerminal ErrorClass = "Terminal"
+)
+
+// Error is an error classified by a provider
+type Error struct {
+	Class      ErrorClass
+	RetryAfter time.Duration
+	Err        error
+}
+
+// NewError returns a classified error
+func NewError(class ErrorClass, retryAfter time.Duration, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{
+		Class:      class,
+		RetryAfter: retryAfter,
+		Err:        err,
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// ClassOf returns the class of an error, empty if the error is not
+// classified
+func ClassOf(err error) ErrorClass {
+	var providerErr *Error
+	if errors.As(err, &providerErr) {
+		return providerErr.Class
+	}
+	return ""
+}
+
+// RetryAfterOf returns the delay asked for by a classified error
+func RetryAfterOf(err error) time.Duration {
+	var providerErr *Error
+	if errors.As(err, &providerErr) {
+		return providerErr.RetryAfter
+	}
+	return 0
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Cause returns the underlying error
+func (e *Error) Cause() error {
+	return e.Err
+}
@@ -0,0 +1,42 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestClassOf(t *testing.T) {
+	tests := map[string]struct {
+		err            error
+		wantClass      ErrorClass
+		wantRetryAfter time.Duration
+	}{
+		"classified error": {
+			err:            NewError(ErrorThrottled, time.Minute, errors.New("rate limited")),
+			wantClass:      ErrorThrottled,
+			wantRetryAfter: time.Minute,
+		},
+		"wrapped classified error": {
+			err: errors.Wrap(NewError(ErrorQuota, 0, errors.New("quota exceeded")),
+				"failed to create vm"),
+			wantClass: ErrorQuota,
+		},
+		"error which is not classified": {
+			err: errors.New("connection refused"),
+		},
+		"no error": {},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if class := ClassOf(test.err); class != test.wantClass {
+				t.Fatalf("expected class %q, got %q", test.wantClass, class)
+			}
+			if retryAfter := RetryAfterOf(test.err); retryAfter != test.wantRetryAfter {
+				t.Fatalf("expected retry after %s, got %s", test.wantRetryAfter, retryAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+package common
+
+const (
+	// Fake is the name of the in-memory provider
+	Fake           = "fake"
+	FakeRootUser   = "root"
+	FakeProviderID = "fake://"
+)
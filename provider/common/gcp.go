@@ -0,0 +1,8 @@
+package common
+
+const (
+	// GCP is the name of gcp provider
+	GCP            = "gcp"
+	GCPDefaultUser = "ubuntu"
+	GCPProviderID  = "gce://"
+)
@@ -0,0 +1,8 @@
+package common
+
+const (
+	// Hetzner is the name of hetzner provider
+	Hetzner           = "hetzner"
+	HetznerRootUser   = "root"
+	HetznerProviderID = "hcloud://"
+)
@@ -1,17 +1,25 @@
 package common
 
+// OwnerTag is added to every vm created by spotcluster. It is used to find
+// the vms which are not referred by any instance.
+const OwnerTag = "spotcluster"
+
 // InstanceConfig ...
 type InstanceConfig struct {
-	ID             string
-	Name           string
-	Region         string
-	Zone           string
-	Image          string
-	Size           string
-	InternalIP     string
-	ExteralIP      string
+	ID         string
+	Name       string
+	Region     string
+	Zone       string
+	Image      string
+	Size       string
+	InternalIP string
+	ExteralIP  string
+	// SSHAddress is the host:port of the ssh server of the vm if it is not
+	// the port 22 of the external ip
+	SSHAddress     string
 	SSHFingerprint string
 	Tags           []string
 	Labels         map[string]string
+	Status         string
 	IsRunning      bool
 }
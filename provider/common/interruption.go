@@ -0,0 +1,13 @@
+package common
+
+import (
+	"time"
+)
+
+// InterruptionNotice is a notice given by a provider before a spot vm is
+// reclaimed
+type InterruptionNotice struct {
+	// Time is the time when the vm is going to be stopped
+	Time   time.Time
+	Reason string
+}
@@ -0,0 +1,97 @@
+package common
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	kubeLock   sync.RWMutex
+	kubeClient kubernetes.Interface
+)
+
+// SetKubeClient sets the client used to read the credentials and the
+// hosts of pools which are kept in secrets and config maps
+func SetKubeClient(client kubernetes.Interface) {
+	kubeLock.Lock()
+	defer kubeLock.Unlock()
+
+	kubeClient = client
+}
+
+// getKubeClient returns the client set by SetKubeClient
+func getKubeClient() (kubernetes.Interface, error) {
+	kubeLock.RLock()
+	defer kubeLock.RUnlock()
+
+	if kubeClient == nil {
+		return nil, errors.New("kube client is not set")
+	}
+	return kubeClient, nil
+}
+
+// ReadSecret returns the value of a key of a secret. Missing secret or key
+// is an invalid config of the pool.
+func ReadSecret(ctx context.Context, ref *spotcluster.SecretKeyRef) ([]byte, error) {
+	value, _, err := ReadSecretVersion(ctx, ref)
+	return value, err
+}
+
+// ReadSecretVersion returns the value of a key of a secret and the resource
+// version of the secret, clients made from the value are reused until the
+// version changes
+func ReadSecretVersion(ctx context.Context,
+	ref *spotcluster.SecretKeyRef) ([]byte, string, error) {
+	if ref == nil {
+		return nil, "", errors.New("got nil secret reference")
+	}
+
+	client, err := getKubeClient()
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := client.CoreV1().Secrets(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, "", NewError(ErrorInvalidConfig, 0,
+			errors.Wrapf(err, "failed to get secret %s/%s", ref.Namespace, ref.Name))
+	}
+
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return nil, "", NewError(ErrorInvalidConfig, 0,
+			errors.Errorf("secret %s/%s has no key %s", ref.Namespace, ref.Name, ref.Key))
+	}
+	return value, secret.GetResourceVersion(), nil
+}
+
+// ReadConfigMap returns the value of a key of a config map. Missing config
+// map or key is an invalid config of the pool.
+func ReadConfigMap(ctx context.Context, ref *spotcluster.ConfigMapKeyRef) (string, error) {
+	if ref == nil {
+		return "", errors.New("got nil config map reference")
+	}
+
+	client, err := getKubeClient()
+	if err != nil {
+		return "", err
+	}
+
+	configMap, err := client.CoreV1().ConfigMaps(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", NewError(ErrorInvalidConfig, 0,
+			errors.Wrapf(err, "failed to get config map %s/%s", ref.Namespace, ref.Name))
+	}
+
+	value, ok := configMap.Data[ref.Key]
+	if !ok {
+		return "", NewError(ErrorInvalidConfig, 0,
+			errors.Errorf("config map %s/%s has no key %s", ref.Namespace, ref.Name, ref.Key))
+	}
+	return value, nil
+}
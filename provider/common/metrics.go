@@ -0,0 +1,63 @@
+package common
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "spotcluster"
+
+var (
+	// requestsTotal counts the api requests made through rate limiters
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "provider",
+		Name:      "requests_total",
+		Help:      "Number of provider api requests made through a rate limiter.",
+	}, []string{"provider"})
+
+	// rateLimitWaitSeconds is the time requests wait for a rate limiter
+	rateLimitWaitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "provider",
+		Name:      "rate_limit_wait_seconds",
+		Help:      "Time provider api requests wait for the rate limiter.",
+		Buckets:   []float64{0.01, 0.1, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"provider"})
+
+	// rateLimitSaturatedTotal counts the requests refused by a rate
+	// limiter as they would wait too long
+	rateLimitSaturatedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "provider",
+		Name:      "rate_limit_saturated_total",
+		Help:      "Number of provider api requests refused as the rate limiter is saturated.",
+	}, []string{"provider"})
+
+	// throttledTotal counts the requests throttled by a provider api
+	throttledTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "provider",
+		Name:      "throttled_total",
+		Help:      "Number of provider api requests throttled by the provider.",
+	}, []string{"provider"})
+
+	// rateLimitRemaining is the number of requests left as reported by a
+	// provider api. Accounts are told apart by a hash of their
+	// credentials.
+	rateLimitRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "provider",
+		Name:      "rate_limit_remaining",
+		Help:      "Number of provider api requests left in the current rate limit window.",
+	}, []string{"provider", "account"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		requestsTotal,
+		rateLimitWaitSeconds,
+		rateLimitSaturatedTotal,
+		throttledTotal,
+		rateLimitRemaining,
+	)
+}
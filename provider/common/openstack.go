@@ -0,0 +1,8 @@
+package common
+
+const (
+	// OpenStack is the name of openstack provider
+	OpenStack            = "openstack"
+	OpenStackDefaultUser = "ubuntu"
+	OpenStackProviderID  = "openstack:///"
+)
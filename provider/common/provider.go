@@ -0,0 +1,222 @@
+package common
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+)
+
+// InstanceProvider manages the vms of a pool at a cloud provider. Every vm
+// created for an instance is tagged with the uid of the instance and with
+// the owner tag, vms are looked up by these tags.
+type InstanceProvider interface {
+	// Create creates a vm for the given config
+	Create(ctx context.Context, config InstanceConfig) (*InstanceConfig, error)
+	// Get returns the vm with the given tag, false if it is not found
+	Get(ctx context.Context, tag string) (*InstanceConfig, bool, error)
+	// Delete deletes the vm with the given tag if it is found
+	Delete(ctx context.Context, tag string) error
+	// List returns all the vms with the given tag
+	List(ctx context.Context, tag string) ([]InstanceConfig, error)
+	// Reboot power cycles the vm with the given id
+	Reboot(ctx context.Context, id string) error
+}
+
+// Rebuilder is implemented by providers which can reimage a vm
+type Rebuilder interface {
+	Rebuild(ctx context.Context, id, image string) error
+}
+
+// Adopter is implemented by providers which can adopt existing vms. Refs
+// are provider specific vm ids or tags.
+type Adopter interface {
+	Find(ctx context.Context, refs []string) ([]InstanceConfig, error)
+	Tag(ctx context.Context, id string, tags ...string) error
+}
+
+// IDDeleter is implemented by providers which can delete a vm by its id.
+// It is required to delete the vms which are not referred by any instance.
+type IDDeleter interface {
+	DeleteByID(ctx context.Context, id string) error
+}
+
+// InterruptionNotifier is implemented by providers which notify before a
+// spot vm is reclaimed
+type InterruptionNotifier interface {
+	// Interruption returns a notice if the vm with the given id is going
+	// to be reclaimed, nil otherwise.
+	Interruption(ctx context.Context, id string) (*InterruptionNotice, error)
+}
+
+// Capabilities are the optional interfaces a provider supports
+type Capabilities struct {
+	Rebuild      bool
+	Adopt        bool
+	DeleteByID   bool
+	Interruption bool
+}
+
+// CapabilityReporter is implemented by providers whose capabilities are
+// known only at run time, such as plugins. Optional interfaces of such a
+// provider are used only if it reports the capability.
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// capabilities returns the capabilities reported by a provider, all of
+// them if it does not report
+func capabilities(p InstanceProvider) Capabilities {
+	if reporter, ok := p.(CapabilityReporter); ok {
+		return reporter.Capabilities()
+	}
+	return Capabilities{
+		Rebuild:      true,
+		Adopt:        true,
+		DeleteByID:   true,
+		Interruption: true,
+	}
+}
+
+// AsRebuilder returns the provider as a Rebuilder if it can reimage vms
+func AsRebuilder(p InstanceProvider) (Rebuilder, bool) {
+	rebuilder, ok := p.(Rebuilder)
+	return rebuilder, ok && capabilities(p).Rebuild
+}
+
+// AsAdopter returns the provider as an Adopter if it can adopt vms
+func AsAdopter(p InstanceProvider) (Adopter, bool) {
+	adopter, ok := p.(Adopter)
+	return adopter, ok && capabilities(p).Adopt
+}
+
+// AsIDDeleter returns the provider as an IDDeleter if it can delete vms
+// by id
+func AsIDDeleter(p InstanceProvider) (IDDeleter, bool) {
+	deleter, ok := p.(IDDeleter)
+	return deleter, ok && capabilities(p).DeleteByID
+}
+
+// AsInterruptionNotifier returns the provider as an InterruptionNotifier
+// if it notifies before a vm is reclaimed
+func AsInterruptionNotifier(p InstanceProvider) (InterruptionNotifier, bool) {
+	notifier, ok := p.(InterruptionNotifier)
+	return notifier, ok && capabilities(p).Interruption
+}
+
+// Registration describes a provider
+type Registration struct {
+	// New returns a provider for the credentials of a pool
+	New func(pool *spotcluster.Pool) (InstanceProvider, error)
+	// Template returns the vm config asked for by a pool
+	Template func(pool *spotcluster.Pool) InstanceConfig
+	// Account identifies the credentials of a pool. Pools with the same
+	// account see the same vms. It is logged and sent to plugins, tokens
+	// are hashed with HashCredential.
+	Account func(pool *spotcluster.Pool) string
+	// SSHUser returns the user used to provision the worker of a pool
+	SSHUser func(pool *spotcluster.Pool) string
+	// ProviderIDPrefix is prefixed to the vm id to get the provider id of
+	// the node
+	ProviderIDPrefix string
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = make(map[string]*Registration)
+	override     string
+)
+
+// Register registers a provider. It is called from the init function of
+// the provider package.
+func Register(name string, registration *Registration) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	registry[name] = registration
+}
+
+// GetRegistration returns the registration of a provider
+func GetRegistration(name string) (*Registration, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	registration, ok := registry[name]
+	return registration, ok
+}
+
+// ForPool returns the provider of a pool along with its registration
+func ForPool(pool *spotcluster.Pool) (InstanceProvider, *Registration, error) {
+	if pool == nil {
+		return nil, nil, errors.New("got nil pool object")
+	}
+
+	name := ProviderName(pool)
+	if name == "" {
+		return nil, nil, NewError(ErrorInvalidConfig, 0,
+			errors.Errorf("pool %s has no provider spec", pool.GetName()))
+	}
+
+	registration, ok := GetRegistration(name)
+	if !ok {
+		return nil, nil, NewError(ErrorInvalidConfig, 0,
+			errors.Errorf("provider %s is not registered", name))
+	}
+
+	p, err := registration.New(pool)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, registration, nil
+}
+
+// SetOverride makes every pool use the given provider irrespective of its
+// provider spec. It is used for dry runs with the fake provider.
+func SetOverride(name string) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	override = name
+}
+
+// ProviderName returns the name of the provider of a pool
+func ProviderName(pool *spotcluster.Pool) string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	switch {
+	case override != "":
+		return override
+	case pool.ProviderSpec.DigitalOcean != nil:
+		return DigitalOcean
+	case pool.ProviderSpec.Fake != nil:
+		return Fake
+	case pool.ProviderSpec.AWS != nil:
+		return AWS
+	case pool.ProviderSpec.GCP != nil:
+		return GCP
+	case pool.ProviderSpec.Azure != nil:
+		return Azure
+	case pool.ProviderSpec.Hetzner != nil:
+		return Hetzner
+	case pool.ProviderSpec.OpenStack != nil:
+		return OpenStack
+	case pool.ProviderSpec.Static != nil:
+		return Static
+	case pool.ProviderSpec.Container != nil:
+		return Container
+	case pool.ProviderSpec.Plugin != nil:
+		return pool.ProviderSpec.Plugin.Name
+	}
+	return ""
+}
+
+// HashCredential returns a short hash of a credential which identifies the
+// account of the credential without revealing it
+func HashCredential(credential string) string {
+	sum := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(sum[:4])
+}
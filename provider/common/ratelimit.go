@@ -0,0 +1,128 @@
+package common
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter is a token bucket shared by all the clients of a provider
+// account. Its rate follows the rate limit reported by the provider api.
+// Requests which would wait longer than the max wait are refused with a
+// throttled error so that they are retried later instead of holding a
+// worker.
+type RateLimiter struct {
+	provider string
+	account  string
+	limit    rate.Limit
+	burst    int
+	maxWait  time.Duration
+	limiter  *rate.Limiter
+
+	lock         sync.Mutex
+	blockedUntil time.Time
+}
+
+// NewRateLimiter returns a rate limiter of a provider account which allows
+// the given requests per second along with a burst
+func NewRateLimiter(provider, account string, perSecond float64, burst int,
+	maxWait time.Duration) *RateLimiter {
+	return &RateLimiter{
+		provider: provider,
+		account:  HashCredential(account),
+		limit:    rate.Limit(perSecond),
+		burst:    burst,
+		maxWait:  maxWait,
+		limiter:  rate.NewLimiter(rate.Limit(perSecond), burst),
+	}
+}
+
+// Wait blocks until a request can be made. A throttled error is returned
+// if the request would wait longer than the max wait or the deadline of the
+// context.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	reservation := l.limiter.Reserve()
+	delay := reservation.Delay()
+
+	l.lock.Lock()
+	if blocked := time.Until(l.blockedUntil); blocked > delay {
+		delay = blocked
+	}
+	l.lock.Unlock()
+
+	deadline, ok := ctx.Deadline()
+	if delay > l.maxWait || (ok && time.Until(deadline) < delay) {
+		reservation.Cancel()
+		rateLimitSaturatedTotal.WithLabelValues(l.provider).Inc()
+		return NewError(ErrorThrottled, delay,
+			errors.Errorf("%s api rate limit is saturated, next request in %s",
+				l.provider, delay.Round(time.Second)))
+	}
+
+	rateLimitWaitSeconds.WithLabelValues(l.provider).Observe(delay.Seconds())
+	requestsTotal.WithLabelValues(l.provider).Inc()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		reservation.Cancel()
+		return ctx.Err()
+	}
+}
+
+// Update adjusts the rate and the burst to the requests left until the
+// reset time of the rate limit window, never above the ones the limiter is
+// created with
+func (l *RateLimiter) Update(remaining int, reset time.Time) {
+	rateLimitRemaining.WithLabelValues(l.provider, l.account).Set(float64(remaining))
+
+	window := time.Until(reset)
+	if window <= 0 {
+		l.limiter.SetLimit(l.limit)
+		l.limiter.SetBurst(l.burst)
+		return
+	}
+
+	if remaining <= 0 {
+		l.Block(reset)
+		return
+	}
+
+	limit := rate.Limit(float64(remaining) / window.Seconds())
+	if limit > l.limit {
+		limit = l.limit
+	}
+	l.limiter.SetLimit(limit)
+
+	burst := l.burst
+	if remaining < burst {
+		burst = remaining
+	}
+	l.limiter.SetBurst(burst)
+}
+
+// Throttled records a request throttled by the provider api, no request
+// is made until the given time
+func (l *RateLimiter) Throttled(until time.Time) {
+	throttledTotal.WithLabelValues(l.provider).Inc()
+	l.Block(until)
+}
+
+// Block holds every request until the given time
+func (l *RateLimiter) Block(until time.Time) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+}
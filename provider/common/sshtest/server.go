@@ -0,0 +1,135 @@
+// Package sshtest is a ssh server which stands in for a host so that the
+// commands run by spotcluster over ssh can be exercised without a vm. Every
+// client is accepted without authentication.
+package sshtest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Handler runs a command of a ssh user and returns its output and exit
+// status
+type Handler func(user, command string) (stdout, stderr string, status int)
+
+// Server is a ssh server which runs commands with a handler
+type Server struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+	handler  Handler
+}
+
+// NewServer starts a ssh server on a local port. It panics if the server
+// can not be started, the server must be closed once done.
+func NewServer(handler Handler) *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(fmt.Sprintf("sshtest: error generating host key: %s", err))
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		panic(fmt.Sprintf("sshtest: error creating host key signer: %s", err))
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("sshtest: error listening: %s", err))
+	}
+
+	s := &Server{
+		listener: listener,
+		config:   &ssh.ServerConfig{NoClientAuth: true},
+		handler:  handler,
+	}
+	s.config.AddHostKey(signer)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// Addr returns the host:port of the server
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server, connections which are open are not closed
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+// Shell returns a handler which runs commands with sh. Home directory of
+// every user is the given directory.
+func Shell(home string) Handler {
+	return func(user, command string) (string, string, int) {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Dir = home
+		cmd.Env = []string{"HOME=" + home, "USER=" + user, "PATH=" + os.Getenv("PATH")}
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		status := 0
+		if err := cmd.Run(); err != nil {
+			status = 1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				status = exitErr.ExitCode()
+			}
+			if stderr.Len() == 0 {
+				stderr.WriteString(err.Error())
+			}
+		}
+		return stdout.String(), stderr.String(), status
+	}
+}
+
+// serve runs the exec requests of a connection
+func (s *Server) serve(conn net.Conn) {
+	serverConn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer serverConn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "exec" || len(req.Payload) < 4 {
+					req.Reply(false, nil)
+					continue
+				}
+				req.Reply(true, nil)
+
+				stdout, stderr, status := s.handler(serverConn.User(), string(req.Payload[4:]))
+				channel.Write([]byte(stdout))
+				channel.Stderr().Write([]byte(stderr))
+
+				payload := make([]byte, 4)
+				binary.BigEndian.PutUint32(payload, uint32(status))
+				channel.SendRequest("exit-status", false, payload)
+				return
+			}
+		}()
+	}
+}
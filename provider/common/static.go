@@ -0,0 +1,8 @@
+package common
+
+const (
+	// Static is the name of static host provider
+	Static           = "static"
+	StaticRootUser   = "root"
+	StaticProviderID = "static://"
+)
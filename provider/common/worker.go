@@ -0,0 +1,133 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	"github.com/shovanmaity/spotcluster/remotedial"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	k3sInstallLink      = "https://get.k3s.io"
+	nodePasswordCommand = "cat /etc/rancher/node/password"
+	rootUser            = "root"
+)
+
+// ProvisionWorker does a ssh into the vm and executes some commands to
+// provision a kubernetes worker. Transcript of the bootstrap is returned
+// even if the bootstrap fails.
+func ProvisionWorker(ctx context.Context, user string, pool *spotcluster.Pool,
+	instance *spotcluster.Instance) (*spotcluster.Instance, *BootstrapResult, error) {
+	if pool == nil {
+		return nil, nil, errors.New("got nil pool object")
+	}
+
+	if instance == nil {
+		return nil, nil, errors.New("got nil instance object")
+	}
+
+	c, err := remotedial.NewSSHClientWithContext(ctx, user, instance.Spec.RemoteAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer c.Close()
+
+	// Provision worker node
+	result, err := func() (*BootstrapResult, error) {
+		session, err := c.NewSession()
+		if err != nil {
+			return nil, err
+		}
+
+		defer session.Close()
+
+		var output bytes.Buffer
+		session.Stdout = &output
+		session.Stderr = &output
+		result := &BootstrapResult{
+			StartTime: time.Now(),
+		}
+
+		err = session.Run("curl -sfL " + k3sInstallLink + " | K3S_URL=" +
+			pool.Spec.MasterURL + " K3S_TOKEN=" + pool.Spec.NodeToken +
+			" INSTALL_K3S_EXEC='--kubelet-arg=provider-id=" + instance.Spec.ProviderID +
+			"' sh -")
+		result.Duration = time.Since(result.StartTime)
+		result.Output = output.Bytes()
+		if err != nil {
+			result.ExitCode = -1
+			if exitErr, ok := err.(*ssh.ExitError); ok {
+				result.ExitCode = exitErr.ExitStatus()
+			}
+			return result, errors.Wrap(err, "failed to install k3s agent")
+		}
+		return result, nil
+	}()
+
+	if err != nil {
+		return nil, result, err
+	}
+
+	instance, err = readNodePassword(c, user, instance)
+	return instance, result, err
+}
+
+// AdoptWorker does a ssh into an adopted vm which already runs a
+// kubernetes worker and reads its node password.
+func AdoptWorker(ctx context.Context, user string, pool *spotcluster.Pool,
+	instance *spotcluster.Instance) (*spotcluster.Instance, *BootstrapResult, error) {
+	if pool == nil {
+		return nil, nil, errors.New("got nil pool object")
+	}
+
+	if instance == nil {
+		return nil, nil, errors.New("got nil instance object")
+	}
+
+	c, err := remotedial.NewSSHClientWithContext(ctx, user, instance.Spec.RemoteAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer c.Close()
+
+	instance, err = readNodePassword(c, user, instance)
+	return instance, nil, err
+}
+
+// readNodePassword reads k3s node password of a worker. Password file is
+// only readable by root, it is read through sudo by other users.
+func readNodePassword(c *ssh.Client, user string,
+	instance *spotcluster.Instance) (*spotcluster.Instance, error) {
+	session, err := c.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	defer session.Close()
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	err = session.Run(sudo(user) + nodePasswordCommand)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read node password: %s",
+			strings.TrimSpace(stderr.String()))
+	}
+	instance.Spec.NodePassword = strings.TrimSpace(stdout.String())
+	return instance, nil
+}
+
+// sudo returns the prefix which runs a command of the given user as root
+func sudo(user string) string {
+	if user == rootUser {
+		return ""
+	}
+	return "sudo -n "
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"testing"
+
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	"github.com/shovanmaity/spotcluster/provider/common/sshtest"
+	"golang.org/x/crypto/ssh"
+)
+
+const testNodePassword = "4c4b4f8e2d0c4b0b9e2a"
+
+// startHost starts a ssh server which stands in for a worker host. Like a
+// host where k3s is installed the node password is readable only by root,
+// other users read it through sudo. Server must be closed once done.
+func startHost() *sshtest.Server {
+	return sshtest.NewServer(func(user, command string) (string, string, int) {
+		if user == rootUser && command == nodePasswordCommand ||
+			command == "sudo -n "+nodePasswordCommand {
+			return testNodePassword + "\n", "", 0
+		}
+		return "", "cat: /etc/rancher/node/password: Permission denied\n", 1
+	})
+}
+
+func TestReadNodePassword(t *testing.T) {
+	host := startHost()
+	defer host.Close()
+
+	tests := map[string]struct {
+		user string
+	}{
+		"root user": {
+			user: "root",
+		},
+		"non root user": {
+			user: "ubuntu",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := ssh.Dial("tcp", host.Addr(), &ssh.ClientConfig{
+				User:            test.user,
+				HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			})
+			if err != nil {
+				t.Fatalf("error connecting to host: %s", err)
+			}
+			defer client.Close()
+
+			instance, err := readNodePassword(client, test.user, &spotcluster.Instance{})
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			if instance.Spec.NodePassword != testNodePassword {
+				t.Fatalf("expected node password %s, got %s",
+					testNodePassword, instance.Spec.NodePassword)
+			}
+		})
+	}
+}
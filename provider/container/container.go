@@ -0,0 +1,210 @@
+package container
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+const (
+	defaultNetwork = "bridge"
+	localRegion    = "local"
+	stateRunning   = "running"
+	sshPort        = "22"
+	// shortIDLength is the length of the short id of a container which the
+	// engine accepts in place of the full id
+	shortIDLength = 12
+	// publicKeyEnv is read by the entrypoint of the node image
+	publicKeyEnv = "SSH_PUBLIC_KEY"
+)
+
+// Client runs the vms of a pool as privileged containers
+type Client struct {
+	engine *engine
+	spec   spotcluster.Container
+}
+
+// Create creates and starts a container. Image is pulled if it is not
+// present.
+func (c *Client) Create(ctx context.Context,
+	config provider.InstanceConfig) (*provider.InstanceConfig, error) {
+	labels := make(map[string]string)
+	for _, tag := range config.Tags {
+		labels[tag] = ""
+	}
+
+	body := containerCreate{
+		Image:    config.Image,
+		Hostname: config.Name,
+		Labels:   labels,
+		HostConfig: containerHostConfig{
+			// k3s needs a privileged container with its own cgroups and
+			// the kernel modules of the host.
+			Privileged:   true,
+			NetworkMode:  network(c.spec),
+			CgroupnsMode: "host",
+			Binds: []string{
+				"/lib/modules:/lib/modules:ro",
+				"/sys/fs/cgroup:/sys/fs/cgroup:rw",
+			},
+			Tmpfs: map[string]string{
+				"/run":      "",
+				"/run/lock": "",
+				"/tmp":      "",
+			},
+		},
+	}
+	if c.spec.SSHPublicKey != "" {
+		body.Env = []string{publicKeyEnv + "=" + strings.TrimSpace(c.spec.SSHPublicKey)}
+	}
+
+	id, err := c.engine.create(ctx, config.Name, body)
+	if isStatus(err, http.StatusNotFound) {
+		if err := c.engine.pull(ctx, config.Image); err != nil {
+			return nil, classify(err)
+		}
+		id, err = c.engine.create(ctx, config.Name, body)
+	}
+	if err != nil {
+		if isStatus(err, http.StatusNotFound) {
+			return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+				errors.Wrapf(err, "image %s is not found", config.Image))
+		}
+		return nil, classify(err)
+	}
+
+	if err := c.engine.start(ctx, id); err != nil {
+		return nil, classify(err)
+	}
+
+	container, found, err := c.getByID(ctx, id, config.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.Errorf("container %s is not found after create", id)
+	}
+	return container, nil
+}
+
+// Get returns the container with the given tag
+func (c *Client) Get(ctx context.Context, tag string) (*provider.InstanceConfig, bool, error) {
+	list, err := c.List(ctx, tag)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(list) == 0 {
+		return nil, false, nil
+	}
+
+	if len(list) != 1 {
+		return nil, false,
+			errors.Errorf("Got %d containers for the given tag %s", len(list), tag)
+	}
+	return &list[0], true, nil
+}
+
+// Delete removes the container with the given tag if found
+func (c *Client) Delete(ctx context.Context, tag string) error {
+	container, found, err := c.Get(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return nil
+	}
+	return c.DeleteByID(ctx, container.ID)
+}
+
+// List returns all the containers with the given tag
+func (c *Client) List(ctx context.Context, tag string) ([]provider.InstanceConfig, error) {
+	list, err := c.engine.list(ctx, tag)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	configs := []provider.InstanceConfig{}
+	for _, container := range list {
+		configs = append(configs, *c.toInstanceConfig(container))
+	}
+	return configs, nil
+}
+
+// Reboot restarts the container with the given id
+func (c *Client) Reboot(ctx context.Context, id string) error {
+	if err := c.engine.restart(ctx, id); err != nil {
+		return classify(err)
+	}
+	return nil
+}
+
+// DeleteByID removes the container with the given id
+func (c *Client) DeleteByID(ctx context.Context, id string) error {
+	err := c.engine.remove(ctx, id)
+	if err != nil && !isStatus(err, http.StatusNotFound) {
+		return classify(err)
+	}
+	return nil
+}
+
+// getByID returns a container which has one of the given tags by its id
+func (c *Client) getByID(ctx context.Context, id string,
+	tags []string) (*provider.InstanceConfig, bool, error) {
+	for _, tag := range tags {
+		list, err := c.engine.list(ctx, tag)
+		if err != nil {
+			return nil, false, classify(err)
+		}
+
+		for _, container := range list {
+			if shortID(container.ID) == shortID(id) {
+				return c.toInstanceConfig(container), true, nil
+			}
+		}
+	}
+	return nil, false, nil
+}
+
+// toInstanceConfig converts a container into instance config. Container is
+// reached at its address in the network of the pool.
+func (c *Client) toInstanceConfig(container containerSummary) *provider.InstanceConfig {
+	config := &provider.InstanceConfig{
+		ID:        shortID(container.ID),
+		Region:    localRegion,
+		Image:     container.Image,
+		Status:    container.Status,
+		IsRunning: container.State == stateRunning,
+		Labels:    container.Labels,
+	}
+
+	if len(container.Names) != 0 {
+		config.Name = strings.TrimPrefix(container.Names[0], "/")
+	}
+
+	for tag := range container.Labels {
+		config.Tags = append(config.Tags, tag)
+	}
+
+	if settings, ok := container.NetworkSettings.Networks[network(c.spec)]; ok &&
+		settings.IPAddress != "" {
+		config.InternalIP = settings.IPAddress
+		config.ExteralIP = settings.IPAddress
+		config.SSHAddress = net.JoinHostPort(settings.IPAddress, sshPort)
+	}
+	return config
+}
+
+// network returns the docker network of a pool
+func network(spec spotcluster.Container) string {
+	if spec.Network != "" {
+		return spec.Network
+	}
+	return defaultNetwork
+}
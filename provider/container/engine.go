@@ -0,0 +1,208 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// apiVersion is the docker engine api version, it is supported by
+	// docker 19.03 and later
+	apiVersion     = "v1.40"
+	defaultHost    = "unix:///var/run/docker.sock"
+	unixHostPrefix = "unix://"
+)
+
+// engineError is an error response of the docker engine
+type engineError struct {
+	StatusCode int
+	Message    string `json:"message"`
+}
+
+func (e *engineError) Error() string {
+	return fmt.Sprintf("docker engine returned %d: %s", e.StatusCode, e.Message)
+}
+
+// engine is a minimal client of the docker engine api
+type engine struct {
+	client  *http.Client
+	baseURL string
+}
+
+// containerSummary is a container returned by the list api
+type containerSummary struct {
+	ID              string            `json:"Id"`
+	Names           []string          `json:"Names"`
+	Image           string            `json:"Image"`
+	State           string            `json:"State"`
+	Status          string            `json:"Status"`
+	Labels          map[string]string `json:"Labels"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// containerCreate is the body of the create api
+type containerCreate struct {
+	Image      string              `json:"Image"`
+	Hostname   string              `json:"Hostname"`
+	Env        []string            `json:"Env,omitempty"`
+	Labels     map[string]string   `json:"Labels,omitempty"`
+	HostConfig containerHostConfig `json:"HostConfig"`
+}
+
+type containerHostConfig struct {
+	Privileged   bool              `json:"Privileged"`
+	NetworkMode  string            `json:"NetworkMode,omitempty"`
+	Binds        []string          `json:"Binds,omitempty"`
+	Tmpfs        map[string]string `json:"Tmpfs,omitempty"`
+	CgroupnsMode string            `json:"CgroupnsMode,omitempty"`
+}
+
+// newEngine returns a client for a docker engine url. Unix socket and tcp
+// urls are supported.
+func newEngine(host string) (*engine, error) {
+	if host == "" {
+		host = defaultHost
+	}
+
+	if strings.HasPrefix(host, unixHostPrefix) {
+		socket := strings.TrimPrefix(host, unixHostPrefix)
+		return &engine{
+			client: &http.Client{
+				Transport: &http.Transport{
+					DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+						var dialer net.Dialer
+						return dialer.DialContext(ctx, "unix", socket)
+					},
+				},
+			},
+			baseURL: "http://docker/" + apiVersion,
+		}, nil
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid docker host %s", host)
+	}
+	if u.Scheme == "tcp" {
+		u.Scheme = "http"
+	}
+	return &engine{
+		client:  &http.Client{},
+		baseURL: strings.TrimSuffix(u.String(), "/") + "/" + apiVersion,
+	}, nil
+}
+
+// list returns all the containers with the given label
+func (e *engine) list(ctx context.Context, label string) ([]containerSummary, error) {
+	filters, err := json.Marshal(map[string][]string{"label": {label}})
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("all", "true")
+	query.Set("filters", string(filters))
+
+	list := []containerSummary{}
+	err = e.do(ctx, http.MethodGet, "/containers/json?"+query.Encode(), nil, &list)
+	return list, err
+}
+
+// create creates a container with the given name and returns its id
+func (e *engine) create(ctx context.Context, name string, body containerCreate) (string, error) {
+	created := struct {
+		ID string `json:"Id"`
+	}{}
+	err := e.do(ctx, http.MethodPost, "/containers/create?name="+url.QueryEscape(name),
+		body, &created)
+	return created.ID, err
+}
+
+// pull pulls an image
+func (e *engine) pull(ctx context.Context, image string) error {
+	return e.do(ctx, http.MethodPost, "/images/create?fromImage="+url.QueryEscape(image),
+		nil, nil)
+}
+
+// start starts a container
+func (e *engine) start(ctx context.Context, id string) error {
+	err := e.do(ctx, http.MethodPost, "/containers/"+id+"/start", nil, nil)
+	if isStatus(err, http.StatusNotModified) {
+		return nil
+	}
+	return err
+}
+
+// restart restarts a container
+func (e *engine) restart(ctx context.Context, id string) error {
+	return e.do(ctx, http.MethodPost, "/containers/"+id+"/restart", nil, nil)
+}
+
+// remove force removes a container along with its volumes
+func (e *engine) remove(ctx context.Context, id string) error {
+	return e.do(ctx, http.MethodDelete, "/containers/"+id+"?force=true&v=true", nil, nil)
+}
+
+// do sends a request to the engine and decodes the response into out
+func (e *engine) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, e.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		engineErr := &engineError{StatusCode: resp.StatusCode}
+		data, _ := ioutil.ReadAll(resp.Body)
+		if json.Unmarshal(data, engineErr) != nil {
+			engineErr.Message = strings.TrimSpace(string(data))
+		}
+		return engineErr
+	}
+
+	if out == nil {
+		// Pull streams its progress, it is done once the body is read.
+		_, err = io.Copy(ioutil.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// isStatus returns true if the engine returned the given status code
+func isStatus(err error, statusCode int) bool {
+	engineErr, ok := err.(*engineError)
+	return ok && engineErr.StatusCode == statusCode
+}
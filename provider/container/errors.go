@@ -0,0 +1,27 @@
+package container
+
+import (
+	"net/http"
+
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+// classify classifies an error returned by the docker engine
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	engineErr, ok := err.(*engineError)
+	if !ok {
+		return provider.NewError(provider.ErrorTransient, 0, err)
+	}
+
+	switch engineErr.StatusCode {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden:
+		return provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	case http.StatusNotFound:
+		return provider.NewError(provider.ErrorTerminal, 0, err)
+	}
+	return provider.NewError(provider.ErrorTransient, 0, err)
+}
@@ -0,0 +1,79 @@
+package container
+
+import (
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+var (
+	_ provider.InstanceProvider = &Client{}
+	_ provider.IDDeleter        = &Client{}
+)
+
+func init() {
+	provider.Register(provider.Container, &provider.Registration{
+		New:              newProvider,
+		Template:         template,
+		Account:          account,
+		SSHUser:          sshUser,
+		ProviderIDPrefix: provider.ContainerProviderID,
+	})
+}
+
+// newProvider returns a container client for the docker engine of a pool
+func newProvider(pool *spotcluster.Pool) (provider.InstanceProvider, error) {
+	return newClient(pool)
+}
+
+// template returns the container config asked for by a pool
+func template(pool *spotcluster.Pool) provider.InstanceConfig {
+	return provider.InstanceConfig{
+		Image: pool.ProviderSpec.Container.Image,
+	}
+}
+
+// sshUser returns the ssh user of containers
+func sshUser(pool *spotcluster.Pool) string {
+	return provider.ContainerRootUser
+}
+
+// account returns the docker engine of a pool
+func account(pool *spotcluster.Pool) string {
+	if pool.ProviderSpec.Container.Host != "" {
+		return pool.ProviderSpec.Container.Host
+	}
+	return defaultHost
+}
+
+// newClient returns a container client for the docker engine of a pool
+func newClient(pool *spotcluster.Pool) (*Client, error) {
+	spec := pool.ProviderSpec.Container
+	if spec == nil {
+		return nil, errors.Errorf("pool %s has no container provider spec", pool.GetName())
+	}
+
+	if spec.Image == "" {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Errorf("pool %s has no container image", pool.GetName()))
+	}
+
+	engine, err := newEngine(spec.Host)
+	if err != nil {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	}
+
+	return &Client{
+		engine: engine,
+		spec:   *spec,
+	}, nil
+}
+
+// shortID returns the short id of a container. Full id is one character
+// longer than a label value.
+func shortID(id string) string {
+	if len(id) > shortIDLength {
+		return id[:shortIDLength]
+	}
+	return id
+}
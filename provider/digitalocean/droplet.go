@@ -3,6 +3,7 @@ package digitalocean
 import (
 	"context"
 	"fmt"
+	"strconv"
 
 	"github.com/digitalocean/godo"
 	"github.com/pkg/errors"
@@ -15,7 +16,8 @@ type Client struct {
 }
 
 // Create creates new droplet
-func (c *Client) Create(config provider.InstanceConfig) (*provider.InstanceConfig, error) {
+func (c *Client) Create(ctx context.Context,
+	config provider.InstanceConfig) (*provider.InstanceConfig, error) {
 	request := &godo.DropletCreateRequest{
 		Name:   config.Name,
 		Region: config.Region,
@@ -31,54 +33,187 @@ func (c *Client) Create(config provider.InstanceConfig) (*provider.InstanceConfi
 		Tags: config.Tags,
 	}
 
-	droplet, _, err := c.Provider.Droplets.Create(context.TODO(), request)
+	droplet, _, err := c.Provider.Droplets.Create(ctx, request)
 	if err != nil {
-		return nil, err
+		return nil, classify(err)
 	}
 
-	return &provider.InstanceConfig{
-		ID:     fmt.Sprintf("%d", droplet.ID),
-		Name:   droplet.Name,
-		Region: droplet.Region.Slug,
-		Image:  droplet.Image.Slug,
-		Tags:   droplet.Tags,
+	return toInstanceConfig(*droplet), nil
+}
 
-		IsRunning: func() bool {
-			if droplet.Status == provider.DropletActive {
-				return true
-			}
-			return false
-		}(),
+// Get returns droplet details if droplet found for a given tag
+func (c *Client) Get(ctx context.Context, tag string) (*provider.InstanceConfig, bool, error) {
+	list, err := c.list(ctx, tag)
+	if err != nil {
+		return nil, false, err
+	}
 
-		InternalIP: func() string {
-			for _, v4 := range droplet.Networks.V4 {
-				if v4.Type == provider.PrivateIPType {
-					return v4.IPAddress
-				}
-			}
-			return ""
-		}(),
+	if len(list) == 0 {
+		return nil, false, nil
+	}
 
-		ExteralIP: func() string {
-			for _, v4 := range droplet.Networks.V4 {
-				if v4.Type == provider.PublicIPType {
-					return v4.IPAddress
-				}
+	if len(list) != 1 {
+		return nil, false,
+			errors.Errorf("Got %d droplets for the given tag %s", len(list), tag)
+	}
+
+	return toInstanceConfig(list[0]), true, nil
+}
+
+// Find returns the droplets for given droplet ids or tags
+func (c *Client) Find(ctx context.Context, refs []string) ([]provider.InstanceConfig, error) {
+	list := []provider.InstanceConfig{}
+	for _, ref := range refs {
+		if _, err := strconv.Atoi(ref); err == nil {
+			droplet, err := c.GetByID(ctx, ref)
+			if err != nil {
+				return nil, err
 			}
-			return ""
-		}(),
-	}, nil
+			list = append(list, *droplet)
+			continue
+		}
+
+		droplets, err := c.List(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, droplets...)
+	}
+	return list, nil
 }
 
-// Get returns droplet details if droplet found for a given tag
-func (c *Client) Get(tag string) (*provider.InstanceConfig, bool, error) {
+// Delete deletes a droplet if found
+func (c *Client) Delete(ctx context.Context, tag string) error {
+	list, err := c.list(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	if len(list) != 1 {
+		return errors.Errorf("Got %d droplets for the given tag %s", len(list), tag)
+	}
+
+	_, err = c.Provider.Droplets.Delete(ctx, list[0].ID)
+	if err != nil && !isNotFound(err) {
+		return classify(err)
+	}
+
+	return nil
+}
+
+// List returns details of all the droplets for a given tag
+func (c *Client) List(ctx context.Context, tag string) ([]provider.InstanceConfig, error) {
+	list, err := c.list(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := []provider.InstanceConfig{}
+	for _, d := range list {
+		configs = append(configs, *toInstanceConfig(d))
+	}
+	return configs, nil
+}
+
+// GetByID returns droplet details for a given id
+func (c *Client) GetByID(ctx context.Context, id string) (*provider.InstanceConfig, error) {
+	dropletID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Wrapf(err, "invalid droplet id %s", id))
+	}
+
+	droplet, _, err := c.Provider.Droplets.Get(ctx, dropletID)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	return toInstanceConfig(*droplet), nil
+}
+
+// Tag adds the given tags to a droplet
+func (c *Client) Tag(ctx context.Context, id string, tags ...string) error {
+	for _, tag := range tags {
+		_, _, err := c.Provider.Tags.Create(ctx, &godo.TagCreateRequest{
+			Name: tag,
+		})
+		if err != nil {
+			return classify(err)
+		}
+
+		_, err = c.Provider.Tags.TagResources(ctx, tag, &godo.TagResourcesRequest{
+			Resources: []godo.Resource{
+				{
+					ID:   id,
+					Type: godo.DropletResourceType,
+				},
+			},
+		})
+		if err != nil {
+			return classify(err)
+		}
+	}
+	return nil
+}
+
+// DeleteByID deletes a droplet for a given id
+func (c *Client) DeleteByID(ctx context.Context, id string) error {
+	dropletID, err := strconv.Atoi(id)
+	if err != nil {
+		return provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Wrapf(err, "invalid droplet id %s", id))
+	}
+
+	_, err = c.Provider.Droplets.Delete(ctx, dropletID)
+	if err != nil && !isNotFound(err) {
+		return classify(err)
+	}
+	return nil
+}
+
+// Reboot power cycles a droplet for a given id
+func (c *Client) Reboot(ctx context.Context, id string) error {
+	dropletID, err := strconv.Atoi(id)
+	if err != nil {
+		return provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Wrapf(err, "invalid droplet id %s", id))
+	}
+
+	_, _, err = c.Provider.DropletActions.PowerCycle(ctx, dropletID)
+	if err != nil {
+		return classify(err)
+	}
+	return nil
+}
+
+// Rebuild reimages a droplet for a given id with the given image
+func (c *Client) Rebuild(ctx context.Context, id, image string) error {
+	dropletID, err := strconv.Atoi(id)
+	if err != nil {
+		return provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Wrapf(err, "invalid droplet id %s", id))
+	}
+
+	_, _, err = c.Provider.DropletActions.RebuildByImageSlug(ctx, dropletID, image)
+	if err != nil {
+		return classify(err)
+	}
+	return nil
+}
+
+// list returns all the droplets for a given tag
+func (c *Client) list(ctx context.Context, tag string) ([]godo.Droplet, error) {
 	list := []godo.Droplet{}
 	opt := &godo.ListOptions{}
 
 	for {
-		droplets, resp, err := c.Provider.Droplets.ListByTag(context.TODO(), tag, opt)
+		droplets, resp, err := c.Provider.Droplets.ListByTag(ctx, tag, opt)
 		if err != nil {
-			return nil, false, err
+			return nil, classify(err)
 		}
 
 		for _, d := range droplets {
@@ -89,37 +224,40 @@ func (c *Client) Get(tag string) (*provider.InstanceConfig, bool, error) {
 			break
 		}
 
+		// Links which can not be parsed are most likely a bad response,
+		// the list is tried again.
 		page, err := resp.Links.CurrentPage()
 		if err != nil {
-			return nil, false, err
+			return nil, provider.NewError(provider.ErrorTransient, 0,
+				errors.Wrapf(err, "invalid pagination links listing droplets with tag %s", tag))
 		}
 
 		opt.Page = page + 1
 	}
 
-	if len(list) == 0 {
-		return nil, false, nil
-	}
-
-	if len(list) != 1 {
-		return nil, false,
-			errors.Errorf("Got %d droplets for the given tag %s", len(list), tag)
-	}
+	return list, nil
+}
 
+// toInstanceConfig converts a droplet into instance config
+func toInstanceConfig(droplet godo.Droplet) *provider.InstanceConfig {
 	return &provider.InstanceConfig{
-		Name:   list[0].Name,
-		Region: list[0].Region.Slug,
-		Image:  list[0].Image.Slug,
-		Tags:   list[0].Tags,
+		ID:     fmt.Sprintf("%d", droplet.ID),
+		Name:   droplet.Name,
+		Region: droplet.Region.Slug,
+		Image:  droplet.Image.Slug,
+		Size:   droplet.SizeSlug,
+		Tags:   droplet.Tags,
+		Status: droplet.Status,
+
 		IsRunning: func() bool {
-			if list[0].Status == provider.DropletActive {
+			if droplet.Status == provider.DropletActive {
 				return true
 			}
 			return false
 		}(),
 
 		InternalIP: func() string {
-			for _, v4 := range list[0].Networks.V4 {
+			for _, v4 := range droplet.Networks.V4 {
 				if v4.Type == provider.PrivateIPType {
 					return v4.IPAddress
 				}
@@ -128,55 +266,12 @@ func (c *Client) Get(tag string) (*provider.InstanceConfig, bool, error) {
 		}(),
 
 		ExteralIP: func() string {
-			for _, v4 := range list[0].Networks.V4 {
+			for _, v4 := range droplet.Networks.V4 {
 				if v4.Type == provider.PublicIPType {
 					return v4.IPAddress
 				}
 			}
 			return ""
 		}(),
-	}, true, nil
-}
-
-// Delete deletes a droplet if found
-func (c *Client) Delete(tag string) error {
-	list := []godo.Droplet{}
-	opt := &godo.ListOptions{}
-
-	for {
-		droplets, resp, err := c.Provider.Droplets.ListByTag(context.TODO(), tag, opt)
-		if err != nil {
-			return err
-		}
-
-		for _, d := range droplets {
-			list = append(list, d)
-		}
-
-		if resp.Links == nil || resp.Links.IsLastPage() {
-			break
-		}
-
-		page, err := resp.Links.CurrentPage()
-		if err != nil {
-			return err
-		}
-
-		opt.Page = page + 1
-	}
-
-	if len(list) == 0 {
-		return nil
 	}
-
-	if len(list) != 1 {
-		return errors.Errorf("Got %d droplets for the given tag %s", len(list), tag)
-	}
-
-	_, err := c.Provider.Droplets.Delete(context.TODO(), list[0].ID)
-	if err != nil {
-		return err
-	}
-
-	return nil
 }
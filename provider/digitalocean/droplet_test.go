@@ -0,0 +1,252 @@
+package digitalocean
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/shovanmaity/spotcluster/provider/digitalocean/testserver"
+)
+
+func newTestClient(t *testing.T, server *testserver.Server) *Client {
+	client, err := sharedClient("token", server.URL())
+	if err != nil {
+		t.Fatalf("error creating client: %s", err)
+	}
+	return &Client{Provider: client}
+}
+
+func addDroplets(server *testserver.Server, count int, tags ...string) {
+	for i := 0; i < count; i++ {
+		server.AddDroplet(fmt.Sprintf("droplet-%d", i), "blr1", "s-1vcpu-1gb",
+			"ubuntu-20-04-x64", tags...)
+	}
+}
+
+func TestList(t *testing.T) {
+	tests := map[string]struct {
+		tagged   int
+		untagged int
+	}{
+		"no droplet": {
+			tagged: 0,
+		},
+		"single page": {
+			tagged:   5,
+			untagged: 3,
+		},
+		"multiple pages": {
+			tagged:   45,
+			untagged: 10,
+		},
+		"last page is full": {
+			tagged: 40,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := testserver.NewServer()
+			defer server.Close()
+			addDroplets(server, test.tagged, "pool")
+			addDroplets(server, test.untagged, "other")
+
+			list, err := newTestClient(t, server).List(context.TODO(), "pool")
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			if len(list) != test.tagged {
+				t.Fatalf("expected %d droplets, got %d", test.tagged, len(list))
+			}
+
+			seen := map[string]bool{}
+			for _, droplet := range list {
+				if seen[droplet.ID] {
+					t.Fatalf("droplet %s is listed twice", droplet.ID)
+				}
+				seen[droplet.ID] = true
+			}
+		})
+	}
+}
+
+func TestListInvalidPagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"droplets": [{"id": 1, "name": "droplet-1"}],
+				"links": {"pages": {"prev": "http://%s/v2/droplets?page=first",
+				"next": "http://%s/v2/droplets?page=3"}}}`, r.Host, r.Host)
+		}))
+	defer server.Close()
+
+	client, err := sharedClient("token", server.URL)
+	if err != nil {
+		t.Fatalf("error creating client: %s", err)
+	}
+
+	_, err = (&Client{Provider: client}).List(context.TODO(), "pool")
+	if err == nil {
+		t.Fatal("expected an error, got none")
+	}
+	if class := provider.ClassOf(err); class != provider.ErrorTransient {
+		t.Fatalf("expected %s error, got %s: %s", provider.ErrorTransient, class, err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	tests := map[string]struct {
+		tagged    int
+		wantFound bool
+		wantErr   bool
+	}{
+		"no droplet": {
+			tagged: 0,
+		},
+		"one droplet": {
+			tagged:    1,
+			wantFound: true,
+		},
+		"more than one droplet": {
+			tagged:  2,
+			wantErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := testserver.NewServer()
+			defer server.Close()
+			addDroplets(server, test.tagged, "instance-uid")
+			addDroplets(server, 2, "other")
+
+			droplet, found, err := newTestClient(t, server).Get(context.TODO(), "instance-uid")
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			if found != test.wantFound {
+				t.Fatalf("expected found %t, got %t", test.wantFound, found)
+			}
+			if found && (droplet.Name != "droplet-0" || !droplet.IsRunning) {
+				t.Fatalf("expected running droplet-0, got %+v", droplet)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := map[string]struct {
+		tagged    int
+		deleteErr int
+		wantLeft  int
+		wantErr   bool
+		wantClass provider.ErrorClass
+	}{
+		"missing droplet": {
+			tagged:   0,
+			wantLeft: 1,
+		},
+		"one droplet": {
+			tagged:   1,
+			wantLeft: 1,
+		},
+		"droplet deleted meanwhile": {
+			tagged:    1,
+			deleteErr: http.StatusNotFound,
+			wantLeft:  2,
+		},
+		"throttled": {
+			tagged:    1,
+			deleteErr: http.StatusTooManyRequests,
+			wantLeft:  2,
+			wantErr:   true,
+			wantClass: provider.ErrorThrottled,
+		},
+		"more than one droplet": {
+			tagged:   2,
+			wantLeft: 3,
+			wantErr:  true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := testserver.NewServer()
+			defer server.Close()
+			addDroplets(server, test.tagged, "instance-uid")
+			addDroplets(server, 1, "other")
+			if test.deleteErr != 0 {
+				server.Fail(http.MethodDelete, "/v2/droplets/", test.deleteErr)
+			}
+
+			err := newTestClient(t, server).Delete(context.TODO(), "instance-uid")
+			if test.wantErr && err == nil {
+				t.Fatal("expected an error, got none")
+			}
+			if !test.wantErr && err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			if test.wantClass != "" && provider.ClassOf(err) != test.wantClass {
+				t.Fatalf("expected %s error, got %s: %s",
+					test.wantClass, provider.ClassOf(err), err)
+			}
+			if left := len(server.Droplets()); left != test.wantLeft {
+				t.Fatalf("expected %d droplets left, got %d", test.wantLeft, left)
+			}
+		})
+	}
+}
+
+func TestDeleteByID(t *testing.T) {
+	server := testserver.NewServer()
+	defer server.Close()
+	droplet := server.AddDroplet("droplet", "blr1", "s-1vcpu-1gb", "ubuntu-20-04-x64")
+	client := newTestClient(t, server)
+
+	tests := map[string]struct {
+		id        string
+		wantErr   bool
+		wantClass provider.ErrorClass
+	}{
+		"existing droplet": {
+			id: fmt.Sprint(droplet.ID),
+		},
+		"missing droplet": {
+			id: "1000",
+		},
+		"invalid id": {
+			id:        "droplet",
+			wantErr:   true,
+			wantClass: provider.ErrorInvalidConfig,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := client.DeleteByID(context.TODO(), test.id)
+			if test.wantErr && err == nil {
+				t.Fatal("expected an error, got none")
+			}
+			if !test.wantErr && err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			if test.wantClass != "" && provider.ClassOf(err) != test.wantClass {
+				t.Fatalf("expected %s error, got %s: %s",
+					test.wantClass, provider.ClassOf(err), err)
+			}
+		})
+	}
+
+	if left := len(server.Droplets()); left != 0 {
+		t.Fatalf("expected no droplet left, got %d", left)
+	}
+}
@@ -0,0 +1,77 @@
+package digitalocean
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/digitalocean/godo"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+const (
+	headerRetryAfter = "Retry-After"
+	headerRateReset  = "RateLimit-Reset"
+)
+
+// classify classifies an error returned by the digitalocean api
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	// Requests refused by the rate limiter are already classified.
+	if class := provider.ClassOf(err); class != "" {
+		return provider.NewError(class, provider.RetryAfterOf(err), err)
+	}
+
+	errResp, ok := err.(*godo.ErrorResponse)
+	if !ok || errResp.Response == nil {
+		return provider.NewError(provider.ErrorTransient, 0, err)
+	}
+
+	switch code := errResp.Response.StatusCode; {
+	case code == http.StatusTooManyRequests:
+		return provider.NewError(provider.ErrorThrottled, retryAfter(errResp.Response), err)
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	case code == http.StatusUnprocessableEntity:
+		// Droplet limit of the account is reported as unprocessable entity.
+		if strings.Contains(strings.ToLower(errResp.Message), "limit") {
+			return provider.NewError(provider.ErrorQuota, 0, err)
+		}
+		return provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	case code == http.StatusNotFound:
+		return provider.NewError(provider.ErrorTerminal, 0, err)
+	default:
+		return provider.NewError(provider.ErrorTransient, 0, err)
+	}
+}
+
+// isNotFound returns true if the resource is not found at digitalocean
+func isNotFound(err error) bool {
+	errResp, ok := err.(*godo.ErrorResponse)
+	return ok && errResp.Response != nil &&
+		errResp.Response.StatusCode == http.StatusNotFound
+}
+
+// retryAfter returns the time to wait before the next request as asked by
+// the Retry-After header or the rate limit reset header of the response.
+func retryAfter(resp *http.Response) time.Duration {
+	if value := resp.Header.Get(headerRetryAfter); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if t, err := http.ParseTime(value); err == nil {
+			return time.Until(t)
+		}
+	}
+
+	if value := resp.Header.Get(headerRateReset); value != "" {
+		if reset, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Until(time.Unix(reset, 0))
+		}
+	}
+	return 0
+}
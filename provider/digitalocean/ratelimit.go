@@ -0,0 +1,109 @@
+package digitalocean
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"github.com/pkg/errors"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"golang.org/x/oauth2"
+)
+
+const (
+	headerRateRemaining = "RateLimit-Remaining"
+
+	// requestsPerHour and requestsPerMinute are the rate limits of an api
+	// key. Requests are spread over the hour, a minute worth of them can be
+	// made at once.
+	requestsPerHour   = 5000
+	requestsPerMinute = 250
+
+	// maxRateLimitWait is the longest a request waits for the rate limiter,
+	// longer waits are returned as throttled errors
+	maxRateLimitWait = 10 * time.Second
+)
+
+var (
+	clientsLock sync.Mutex
+	// clients are the godo clients of api keys. A client is shared by
+	// every pool with the api key and by both controllers so that all the
+	// requests made with a key go through one rate limiter.
+	clients = make(map[string]*godo.Client)
+)
+
+// rateLimitedTransport waits for the rate limiter of an api key before
+// every request and adjusts it to the rate limit headers of the responses
+type rateLimitedTransport struct {
+	base    http.RoundTripper
+	limiter *provider.RateLimiter
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		t.limiter.Throttled(time.Now().Add(retryAfter(resp)))
+	}
+
+	remaining, err := strconv.Atoi(resp.Header.Get(headerRateRemaining))
+	if err != nil {
+		return resp, nil
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get(headerRateReset), 10, 64)
+	if err != nil {
+		return resp, nil
+	}
+	t.limiter.Update(remaining, time.Unix(reset, 0))
+	return resp, nil
+}
+
+// sharedClient returns the godo client of an api key and api url, it is
+// created on first use
+func sharedClient(apiKey, apiURL string) (*godo.Client, error) {
+	clientsLock.Lock()
+	defer clientsLock.Unlock()
+
+	key := apiKey + "@" + apiURL
+	if client, ok := clients[key]; ok {
+		return client, nil
+	}
+
+	transport := &rateLimitedTransport{
+		base: http.DefaultTransport,
+		limiter: provider.NewRateLimiter(provider.DigitalOcean, apiKey,
+			float64(requestsPerHour)/time.Hour.Seconds(), requestsPerMinute,
+			maxRateLimitWait),
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient,
+		&http.Client{Transport: transport})
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: apiKey})
+
+	options := []godo.ClientOpt{}
+	if apiURL != "" {
+		if !strings.HasSuffix(apiURL, "/") {
+			apiURL += "/"
+		}
+		options = append(options, godo.SetBaseURL(apiURL))
+	}
+
+	client, err := godo.New(oauth2.NewClient(ctx, tokenSource), options...)
+	if err != nil {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Wrapf(err, "invalid api url %s", apiURL))
+	}
+
+	clients[key] = client
+	return client, nil
+}
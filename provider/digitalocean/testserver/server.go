@@ -0,0 +1,702 @@
+// Package testserver is an in-memory stand-in for the DigitalOcean v2 api.
+// It serves the droplets, droplet actions, tags, ssh keys and sizes
+// endpoints used by spotcluster so that the digitalocean provider can be
+// exercised without a DigitalOcean account.
+package testserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/digitalocean/godo"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 200
+)
+
+// Server is a DigitalOcean api stand-in which keeps its state in memory
+type Server struct {
+	sync.Mutex
+	server *httptest.Server
+
+	// Token is the api key accepted by the server, any key is accepted if
+	// it is empty.
+	Token string
+	// BootDelay is the time a new droplet takes to become active
+	BootDelay time.Duration
+	// DropletLimit is the maximum number of droplets, zero means no limit
+	DropletLimit int
+
+	droplets map[int]*droplet
+	tags     map[string]bool
+	keys     []godo.Key
+	sizes    []godo.Size
+	actions  map[int]*godo.Action
+	failures []failure
+
+	lastDropletID int
+	lastKeyID     int
+	lastActionID  int
+}
+
+// createRequest is a droplet create request. Image and ssh keys are given
+// either by slug or fingerprint or by id.
+type createRequest struct {
+	Name    string       `json:"name"`
+	Region  string       `json:"region"`
+	Size    string       `json:"size"`
+	Image   stringOrID   `json:"image"`
+	SSHKeys []stringOrID `json:"ssh_keys"`
+	Tags    []string     `json:"tags"`
+}
+
+// stringOrID is a json string or number
+type stringOrID string
+
+func (v *stringOrID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = stringOrID(s)
+		return nil
+	}
+
+	var id int
+	if err := json.Unmarshal(data, &id); err != nil {
+		return err
+	}
+	*v = stringOrID(strconv.Itoa(id))
+	return nil
+}
+
+func (v stringOrID) String() string {
+	return string(v)
+}
+
+type droplet struct {
+	godo.Droplet
+	createdAt time.Time
+}
+
+// failure is an error returned for the next request which matches it
+type failure struct {
+	method string
+	prefix string
+	status int
+}
+
+// NewServer starts a new api stand-in. It must be closed once done.
+func NewServer() *Server {
+	s := &Server{
+		droplets: make(map[int]*droplet),
+		tags:     make(map[string]bool),
+		actions:  make(map[int]*godo.Action),
+		sizes: []godo.Size{
+			{Slug: "s-1vcpu-1gb", Memory: 1024, Vcpus: 1, Disk: 25, Available: true},
+			{Slug: "s-1vcpu-2gb", Memory: 2048, Vcpus: 1, Disk: 50, Available: true},
+			{Slug: "s-2vcpu-2gb", Memory: 2048, Vcpus: 2, Disk: 60, Available: true},
+			{Slug: "s-2vcpu-4gb", Memory: 4096, Vcpus: 2, Disk: 80, Available: true},
+		},
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// URL returns the base url of the api which is given to godo
+func (s *Server) URL() string {
+	return s.server.URL + "/"
+}
+
+// Close shuts down the server
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// AddKey registers a ssh key, droplets can only be created with registered
+// keys.
+func (s *Server) AddKey(name, fingerprint string) godo.Key {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.addKey(name, fingerprint, "")
+}
+
+// AddDroplet adds an existing droplet, e.g. one which is adopted later.
+// It is active right away.
+func (s *Server) AddDroplet(name, region, size, image string, tags ...string) godo.Droplet {
+	s.Lock()
+	defer s.Unlock()
+
+	d := s.newDroplet(name, region, size, image, tags)
+	d.createdAt = time.Time{}
+	return s.view(d)
+}
+
+// Droplets returns all the droplets
+func (s *Server) Droplets() []godo.Droplet {
+	s.Lock()
+	defer s.Unlock()
+
+	list := []godo.Droplet{}
+	for _, id := range s.dropletIDs() {
+		list = append(list, s.view(s.droplets[id]))
+	}
+	return list
+}
+
+// PowerOff stops a droplet as if it was reclaimed
+func (s *Server) PowerOff(id int) {
+	s.Lock()
+	defer s.Unlock()
+
+	if d, ok := s.droplets[id]; ok {
+		d.Status = "off"
+	}
+}
+
+// Fail makes the next request with the given method whose path starts with
+// the given prefix fail with the given status, e.g. Fail("POST",
+// "/v2/droplets", 429).
+func (s *Server) Fail(method, prefix string, status int) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.failures = append(s.failures, failure{
+		method: method,
+		prefix: prefix,
+		status: status,
+	})
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "Unable to authenticate you.")
+		return
+	}
+
+	for i, f := range s.failures {
+		if f.method == r.Method && strings.HasPrefix(r.URL.Path, f.prefix) {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			if f.status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "1")
+			}
+			writeError(w, f.status, errorID(f.status), "injected failure")
+			return
+		}
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v2"), "/"), "/")
+	switch {
+	case parts[0] == "droplets" && len(parts) == 1:
+		s.serveDroplets(w, r)
+	case parts[0] == "droplets" && len(parts) == 2:
+		s.serveDroplet(w, r, parts[1])
+	case parts[0] == "droplets" && len(parts) == 3 && parts[2] == "actions":
+		s.serveDropletAction(w, r, parts[1])
+	case parts[0] == "actions" && len(parts) == 2:
+		s.serveAction(w, r, parts[1])
+	case parts[0] == "tags":
+		s.serveTags(w, r, parts[1:])
+	case parts[0] == "account" && len(parts) >= 2 && parts[1] == "keys":
+		s.serveKeys(w, r, parts[2:])
+	case parts[0] == "sizes" && len(parts) == 1 && r.Method == http.MethodGet:
+		sizes := []interface{}{}
+		for _, size := range s.sizes {
+			sizes = append(sizes, size)
+		}
+		s.writePage(w, r, "sizes", sizes)
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "The resource you were accessing could not be found.")
+	}
+}
+
+func (s *Server) serveDroplets(w http.ResponseWriter, r *http.Request) {
+	tag := r.URL.Query().Get("tag_name")
+	switch r.Method {
+	case http.MethodGet:
+		droplets := []interface{}{}
+		for _, id := range s.dropletIDs() {
+			d := s.droplets[id]
+			if tag == "" || hasTag(d.Tags, tag) {
+				droplets = append(droplets, s.view(d))
+			}
+		}
+		s.writePage(w, r, "droplets", droplets)
+	case http.MethodPost:
+		request := &createRequest{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+
+		if message := s.validate(request); message != "" {
+			writeError(w, http.StatusUnprocessableEntity, "unprocessable_entity", message)
+			return
+		}
+
+		for _, t := range request.Tags {
+			s.tags[t] = true
+		}
+		d := s.newDroplet(request.Name, request.Region, request.Size,
+			request.Image.String(), request.Tags)
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{
+			"droplet": s.view(d),
+		})
+	case http.MethodDelete:
+		if tag == "" {
+			writeError(w, http.StatusUnprocessableEntity, "unprocessable_entity", "tag_name is required")
+			return
+		}
+		for id, d := range s.droplets {
+			if hasTag(d.Tags, tag) {
+				delete(s.droplets, id)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", r.Method+" is not allowed")
+	}
+}
+
+func (s *Server) serveDroplet(w http.ResponseWriter, r *http.Request, id string) {
+	d, ok := s.getDroplet(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "The resource you were accessing could not be found.")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"droplet": s.view(d),
+		})
+	case http.MethodDelete:
+		delete(s.droplets, d.ID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", r.Method+" is not allowed")
+	}
+}
+
+func (s *Server) serveDropletAction(w http.ResponseWriter, r *http.Request, id string) {
+	d, ok := s.getDroplet(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "The resource you were accessing could not be found.")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", r.Method+" is not allowed")
+		return
+	}
+
+	request := map[string]interface{}{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	actionType, _ := request["type"].(string)
+	switch actionType {
+	case "power_off", "shutdown":
+		d.Status = "off"
+	case "power_on", "power_cycle", "reboot":
+		d.Status = "active"
+	case "rebuild":
+		image, _ := request["image"].(string)
+		if image == "" {
+			writeError(w, http.StatusUnprocessableEntity, "unprocessable_entity", "image is required")
+			return
+		}
+		d.Image = &godo.Image{Slug: image}
+		d.createdAt = time.Now()
+		d.Status = "new"
+	default:
+		writeError(w, http.StatusUnprocessableEntity, "unprocessable_entity",
+			"unknown action type "+actionType)
+		return
+	}
+
+	s.lastActionID++
+	action := &godo.Action{
+		ID:           s.lastActionID,
+		Status:       "completed",
+		Type:         actionType,
+		ResourceID:   d.ID,
+		ResourceType: "droplet",
+		StartedAt:    &godo.Timestamp{Time: time.Now()},
+		CompletedAt:  &godo.Timestamp{Time: time.Now()},
+	}
+	s.actions[action.ID] = action
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"action": action,
+	})
+}
+
+func (s *Server) serveAction(w http.ResponseWriter, r *http.Request, id string) {
+	actionID, _ := strconv.Atoi(id)
+	action, ok := s.actions[actionID]
+	if !ok || r.Method != http.MethodGet {
+		writeError(w, http.StatusNotFound, "not_found", "The resource you were accessing could not be found.")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"action": action,
+	})
+}
+
+func (s *Server) serveTags(w http.ResponseWriter, r *http.Request, parts []string) {
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodPost:
+		tag := &godo.TagCreateRequest{}
+		if err := json.NewDecoder(r.Body).Decode(tag); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+		if tag.Name == "" {
+			writeError(w, http.StatusUnprocessableEntity, "unprocessable_entity", "name is required")
+			return
+		}
+		s.tags[tag.Name] = true
+		writeJSON(w, http.StatusCreated, map[string]interface{}{
+			"tag": s.tag(tag.Name),
+		})
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		if !s.tags[parts[0]] {
+			writeError(w, http.StatusNotFound, "not_found", "The resource you were accessing could not be found.")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"tag": s.tag(parts[0]),
+		})
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		delete(s.tags, parts[0])
+		for _, d := range s.droplets {
+			d.Tags = removeTag(d.Tags, parts[0])
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 2 && parts[1] == "resources":
+		if !s.tags[parts[0]] {
+			writeError(w, http.StatusNotFound, "not_found", "The resource you were accessing could not be found.")
+			return
+		}
+
+		request := &godo.TagResourcesRequest{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+
+		for _, resource := range request.Resources {
+			d, ok := s.getDroplet(resource.ID)
+			if !ok || resource.Type != godo.DropletResourceType {
+				writeError(w, http.StatusUnprocessableEntity, "unprocessable_entity",
+					"resource "+resource.ID+" not found")
+				return
+			}
+			switch r.Method {
+			case http.MethodPost:
+				if !hasTag(d.Tags, parts[0]) {
+					d.Tags = append(d.Tags, parts[0])
+				}
+			case http.MethodDelete:
+				d.Tags = removeTag(d.Tags, parts[0])
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "The resource you were accessing could not be found.")
+	}
+}
+
+func (s *Server) serveKeys(w http.ResponseWriter, r *http.Request, parts []string) {
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodGet:
+		keys := []interface{}{}
+		for _, key := range s.keys {
+			keys = append(keys, key)
+		}
+		s.writePage(w, r, "ssh_keys", keys)
+	case len(parts) == 0 && r.Method == http.MethodPost:
+		request := &godo.KeyCreateRequest{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+		if request.Name == "" || request.PublicKey == "" {
+			writeError(w, http.StatusUnprocessableEntity, "unprocessable_entity",
+				"name and public_key are required")
+			return
+		}
+		fingerprint := fmt.Sprintf("00:00:00:00:00:00:00:00:00:00:00:00:00:00:%02x:%02x",
+			(s.lastKeyID+1)/256, (s.lastKeyID+1)%256)
+		key := s.addKey(request.Name, fingerprint, request.PublicKey)
+		writeJSON(w, http.StatusCreated, map[string]interface{}{
+			"ssh_key": key,
+		})
+	case len(parts) == 1:
+		for i, key := range s.keys {
+			if strconv.Itoa(key.ID) != parts[0] && key.Fingerprint != parts[0] {
+				continue
+			}
+			switch r.Method {
+			case http.MethodGet:
+				writeJSON(w, http.StatusOK, map[string]interface{}{
+					"ssh_key": key,
+				})
+			case http.MethodDelete:
+				s.keys = append(s.keys[:i], s.keys[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", r.Method+" is not allowed")
+			}
+			return
+		}
+		writeError(w, http.StatusNotFound, "not_found", "The resource you were accessing could not be found.")
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "The resource you were accessing could not be found.")
+	}
+}
+
+// validate returns the reason a droplet create request is rejected
+func (s *Server) validate(request *createRequest) string {
+	if request.Name == "" || request.Region == "" || request.Size == "" ||
+		request.Image.String() == "" {
+		return "name, region, size and image are required"
+	}
+
+	if s.DropletLimit > 0 && len(s.droplets) >= s.DropletLimit {
+		return "creating this/these droplet(s) will exceed your droplet limit"
+	}
+
+	found := false
+	for _, size := range s.sizes {
+		if size.Slug == request.Size {
+			found = true
+		}
+	}
+	if !found {
+		return "invalid size " + request.Size
+	}
+
+	for _, requested := range request.SSHKeys {
+		found := false
+		for _, key := range s.keys {
+			if key.Fingerprint == requested.String() || strconv.Itoa(key.ID) == requested.String() {
+				found = true
+			}
+		}
+		if !found {
+			return "ssh key " + requested.String() + " not found"
+		}
+	}
+	return ""
+}
+
+func (s *Server) newDroplet(name, region, size, image string, tags []string) *droplet {
+	s.lastDropletID++
+	id := s.lastDropletID
+	d := &droplet{
+		Droplet: godo.Droplet{
+			ID:       id,
+			Name:     name,
+			Region:   &godo.Region{Slug: region},
+			Image:    &godo.Image{Slug: image},
+			Size:     &godo.Size{Slug: size},
+			SizeSlug: size,
+			Status:   "new",
+			Networks: &godo.Networks{
+				V4: []godo.NetworkV4{
+					{IPAddress: fmt.Sprintf("203.0.113.%d", id%256), Type: "public"},
+					{IPAddress: fmt.Sprintf("10.10.%d.%d", id/256%256, id%256), Type: "private"},
+				},
+			},
+			Created:   time.Now().UTC().Format(time.RFC3339),
+			Tags:      append([]string{}, tags...),
+			VolumeIDs: []string{},
+		},
+		createdAt: time.Now(),
+	}
+	s.droplets[id] = d
+	return d
+}
+
+// view returns a droplet as it is seen through the api. New droplets
+// become active after the boot delay.
+func (s *Server) view(d *droplet) godo.Droplet {
+	if d.Status == "new" && time.Since(d.createdAt) >= s.BootDelay {
+		d.Status = "active"
+	}
+
+	view := d.Droplet
+	view.Tags = append([]string{}, d.Tags...)
+	return view
+}
+
+func (s *Server) getDroplet(id string) (*droplet, bool) {
+	dropletID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, false
+	}
+	d, ok := s.droplets[dropletID]
+	return d, ok
+}
+
+// dropletIDs returns the ids of all droplets in the order of creation
+func (s *Server) dropletIDs() []int {
+	ids := []int{}
+	for id := 1; id <= s.lastDropletID; id++ {
+		if _, ok := s.droplets[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (s *Server) addKey(name, fingerprint, publicKey string) godo.Key {
+	s.lastKeyID++
+	key := godo.Key{
+		ID:          s.lastKeyID,
+		Name:        name,
+		Fingerprint: fingerprint,
+		PublicKey:   publicKey,
+	}
+	s.keys = append(s.keys, key)
+	return key
+}
+
+func (s *Server) tag(name string) *godo.Tag {
+	count := 0
+	for _, d := range s.droplets {
+		if hasTag(d.Tags, name) {
+			count++
+		}
+	}
+	return &godo.Tag{
+		Name: name,
+		Resources: &godo.TaggedResources{
+			Count: count,
+			Droplets: &godo.TaggedDropletsResources{
+				Count: count,
+			},
+		},
+	}
+}
+
+// writePage writes a page of items along with the pagination links, the
+// same way the api does.
+func (s *Server) writePage(w http.ResponseWriter, r *http.Request,
+	key string, items []interface{}) {
+	query := r.URL.Query()
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	lastPage := (len(items) + perPage - 1) / perPage
+	if lastPage == 0 {
+		lastPage = 1
+	}
+
+	start := (page - 1) * perPage
+	if start > len(items) {
+		start = len(items)
+	}
+	end := start + perPage
+	if end > len(items) {
+		end = len(items)
+	}
+
+	link := func(p int) string {
+		q := r.URL.Query()
+		q.Set("page", strconv.Itoa(p))
+		q.Set("per_page", strconv.Itoa(perPage))
+		return s.server.URL + r.URL.Path + "?" + q.Encode()
+	}
+
+	pages := &godo.Pages{}
+	if page > 1 {
+		pages.First = link(1)
+		pages.Prev = link(page - 1)
+	}
+	if page < lastPage {
+		pages.Next = link(page + 1)
+		pages.Last = link(lastPage)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		key: items[start:end],
+		"links": &godo.Links{
+			Pages: pages,
+		},
+		"meta": &godo.Meta{
+			Total: len(items),
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, id, message string) {
+	writeJSON(w, status, map[string]string{
+		"id":      id,
+		"message": message,
+	})
+}
+
+func errorID(status int) string {
+	switch status {
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusUnprocessableEntity:
+		return "unprocessable_entity"
+	case http.StatusTooManyRequests:
+		return "too_many_requests"
+	}
+	return "server_error"
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func removeTag(tags []string, tag string) []string {
+	list := []string{}
+	for _, t := range tags {
+		if t != tag {
+			list = append(list, t)
+		}
+	}
+	return list
+}
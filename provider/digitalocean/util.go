@@ -1,168 +1,66 @@
 package digitalocean
 
 import (
-	"bytes"
-	"strings"
-
 	"github.com/pkg/errors"
-
-	"github.com/digitalocean/godo"
-	controller "github.com/shovanmaity/spotcluster/controller/common"
 	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
 	provider "github.com/shovanmaity/spotcluster/provider/common"
-	"github.com/shovanmaity/spotcluster/remotedial"
 )
 
-const (
-	k3sInstallLink      = "https://get.k3s.io"
-	nodePasswordCommand = "cat /etc/rancher/node/password"
+var (
+	_ provider.InstanceProvider = &Client{}
+	_ provider.Rebuilder        = &Client{}
+	_ provider.Adopter          = &Client{}
+	_ provider.IDDeleter        = &Client{}
 )
 
-// ProvisionInstance creates a new droplet if not present
-// If droplet is present then it gets the details of that droplet
-func ProvisionInstance(pool *spotcluster.Pool,
-	instance *spotcluster.Instance) (*spotcluster.Instance, error) {
-
-	if pool == nil {
-		return nil, errors.New("got nil pool object")
-	}
-
-	if instance == nil {
-		return nil, errors.New("got nil instance object")
-	}
-
-	client := godo.NewFromToken(pool.ProviderSpec.DigitalOcean.APIKey)
-	if client == nil {
-		return nil, errors.New("got nil godo client")
-	}
-
-	doc := Client{
-		Provider: client,
-	}
+func init() {
+	provider.Register(provider.DigitalOcean, &provider.Registration{
+		New:              newProvider,
+		Template:         template,
+		Account:          account,
+		SSHUser:          sshUser,
+		ProviderIDPrefix: provider.DoProviderID,
+	})
+}
 
-	// If droplet is present then populate it's details.
-	// Do the same until droplet becomes ready.
-	droplet, found, err := doc.Get(string(instance.GetUID()))
-	if err != nil {
-		return nil, err
-	}
+// newProvider returns a droplet client for the api key of a pool
+func newProvider(pool *spotcluster.Pool) (provider.InstanceProvider, error) {
+	return newClient(pool)
+}
 
-	if found {
-		populateInstance(instance, *droplet)
-	} else {
-		config := provider.InstanceConfig{
-			Name:           instance.GetName(),
-			Region:         pool.ProviderSpec.DigitalOcean.Region,
-			Size:           pool.ProviderSpec.DigitalOcean.InstanceSize,
-			Image:          pool.ProviderSpec.DigitalOcean.Image,
-			Tags:           []string{string(instance.GetUID())},
-			SSHFingerprint: pool.Spec.SSHFingerprint,
-		}
-		droplet, err := doc.Create(config)
-		if err != nil {
-			return nil, err
-		}
-		populateInstance(instance, *droplet)
+// template returns the droplet config asked for by a pool
+func template(pool *spotcluster.Pool) provider.InstanceConfig {
+	return provider.InstanceConfig{
+		Region: pool.ProviderSpec.DigitalOcean.Region,
+		Size:   pool.ProviderSpec.DigitalOcean.InstanceSize,
+		Image:  pool.ProviderSpec.DigitalOcean.Image,
 	}
-	return instance, nil
 }
 
-// ProvisionWorker does a ssh into the droplet and executes some
-// commands to provision a kubernetes worker.
-func ProvisionWorker(pool *spotcluster.Pool,
-	instance *spotcluster.Instance) (*spotcluster.Instance, error) {
-	if pool == nil {
-		return nil, errors.New("got nil pool object")
-	}
+// sshUser returns the ssh user of droplets
+func sshUser(pool *spotcluster.Pool) string {
+	return provider.DoRootUser
+}
 
-	if instance == nil {
-		return nil, errors.New("got nil instance object")
-	}
+// account returns the hash of the api key of a pool
+func account(pool *spotcluster.Pool) string {
+	return provider.HashCredential(pool.ProviderSpec.DigitalOcean.APIKey)
+}
 
-	c, err := remotedial.NewSSHClient(provider.DoRootUser, instance.Spec.RemoteAddress)
-	if err != nil {
-		return nil, err
+// newClient returns a droplet client for the api key of a pool. The godo
+// client is shared by every pool with the api key.
+func newClient(pool *spotcluster.Pool) (*Client, error) {
+	spec := pool.ProviderSpec.DigitalOcean
+	if spec == nil {
+		return nil, errors.Errorf("pool %s has no digitalocean provider spec", pool.GetName())
 	}
 
-	defer c.Close()
-
-	// Provision worker node
-	err = func() error {
-		session, err := c.NewSession()
-		if err != nil {
-			return err
-		}
-
-		defer session.Close()
-
-		session.Run("curl -sfL " + k3sInstallLink + " | K3S_URL=" +
-			pool.Spec.MasterURL + " K3S_TOKEN=" + pool.Spec.NodeToken + " sh -")
-		return nil
-	}()
-
+	client, err := sharedClient(spec.APIKey, spec.APIURL)
 	if err != nil {
 		return nil, err
 	}
 
-	// Read node password
-	return func() (*spotcluster.Instance, error) {
-		session, err := c.NewSession()
-		if err != nil {
-			return nil, err
-		}
-
-		defer session.Close()
-		var stdout bytes.Buffer
-		var stderr bytes.Buffer
-		session.Stdout = &stdout
-		session.Stderr = &stderr
-		session.Run(nodePasswordCommand)
-		instance.Spec.NodePassword = strings.TrimSpace(stdout.String())
-		return instance, nil
-	}()
-}
-
-// DeleteInstance delete for a given tag
-func DeleteInstance(instance *spotcluster.Instance,
-	pool *spotcluster.Pool) error {
-
-	if pool == nil {
-		return errors.New("got nil pool object")
-	}
-
-	if instance == nil {
-		return errors.New("got nil instance object")
-	}
-
-	client := godo.NewFromToken(pool.ProviderSpec.DigitalOcean.APIKey)
-	if client == nil {
-		return errors.New("got nil godo client")
-	}
-
-	doc := Client{
+	return &Client{
 		Provider: client,
-	}
-
-	return doc.Delete(string(instance.GetUID()))
-}
-
-// populateInstance populates instance details for a given droplet
-func populateInstance(instance *spotcluster.Instance,
-	droplet provider.InstanceConfig) {
-	instance.Spec.InstanceName = droplet.Name
-	instance.Spec.RemoteAddress = func() string {
-		if droplet.ExteralIP != "" {
-			return droplet.ExteralIP + ":22"
-		}
-		return ""
-	}()
-	instance.Spec.ExternalIP = droplet.ExteralIP
-	instance.Spec.InternalIP = droplet.InternalIP
-	instance.Spec.InstanceAvailable = true
-	instance.Spec.InstanceReady = droplet.IsRunning
-	instance.Spec.NodeAvailable = false
-	instance.Finalizers = func() []string {
-		return []string{controller.InstanceProtectionFinalizer}
-	}()
-	instance.Labels[controller.LabelInstanceID] = droplet.ID
+	}, nil
 }
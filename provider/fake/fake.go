@@ -0,0 +1,270 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+const (
+	statusNew    = "new"
+	statusActive = "active"
+	statusOff    = "off"
+)
+
+// vm is a vm kept in memory
+type vm struct {
+	config   provider.InstanceConfig
+	bootedAt time.Time
+	stopped  bool
+}
+
+// store keeps the vms of all the pools which use the fake provider
+type store struct {
+	sync.Mutex
+	vms    map[string]*vm
+	lastID int
+	next   int
+}
+
+var vms = &store{
+	vms: make(map[string]*vm),
+}
+
+// Provider is an in-memory provider. VMs boot after a delay, can be
+// reclaimed after a while and point at local ssh test servers.
+type Provider struct {
+	spec  spotcluster.Fake
+	store *store
+}
+
+// Create creates a new vm unless a failure is injected or the capacity is
+// exhausted
+func (p *Provider) Create(ctx context.Context,
+	config provider.InstanceConfig) (*provider.InstanceConfig, error) {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	if p.spec.FailureRate > 0 && rand.Intn(100) < p.spec.FailureRate {
+		return nil, injectedError(p.spec.FailureClass)
+	}
+
+	if p.spec.Capacity > 0 && len(p.store.vms) >= p.spec.Capacity {
+		return nil, provider.NewError(provider.ErrorQuota, 0,
+			errors.Errorf("capacity of %d vms is exhausted", p.spec.Capacity))
+	}
+
+	p.store.lastID++
+	id := strconv.Itoa(p.store.lastID)
+	config.ID = id
+	config.InternalIP = fmt.Sprintf("10.0.%d.%d", p.store.lastID/256, p.store.lastID%256)
+	config.ExteralIP = "127.0.0.1"
+	if len(p.spec.SSHAddresses) != 0 {
+		config.SSHAddress = p.spec.SSHAddresses[p.store.next%len(p.spec.SSHAddresses)]
+		p.store.next++
+		if host, _, err := net.SplitHostPort(config.SSHAddress); err == nil {
+			config.ExteralIP = host
+		}
+	}
+
+	p.store.vms[id] = &vm{
+		config:   config,
+		bootedAt: time.Now(),
+	}
+	return p.status(p.store.vms[id]), nil
+}
+
+// Get returns the vm with the given tag
+func (p *Provider) Get(ctx context.Context, tag string) (*provider.InstanceConfig, bool, error) {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	list := p.list(tag)
+	if len(list) == 0 {
+		return nil, false, nil
+	}
+
+	if len(list) != 1 {
+		return nil, false, errors.Errorf("Got %d vms for the given tag %s", len(list), tag)
+	}
+	return &list[0], true, nil
+}
+
+// Delete deletes the vm with the given tag if found
+func (p *Provider) Delete(ctx context.Context, tag string) error {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	for id, v := range p.store.vms {
+		if hasTag(v.config.Tags, tag) {
+			delete(p.store.vms, id)
+		}
+	}
+	return nil
+}
+
+// List returns all the vms with the given tag
+func (p *Provider) List(ctx context.Context, tag string) ([]provider.InstanceConfig, error) {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	return p.list(tag), nil
+}
+
+// Reboot restarts the vm with the given id, a reclaimed vm is started again
+func (p *Provider) Reboot(ctx context.Context, id string) error {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	v, ok := p.store.vms[id]
+	if !ok {
+		return provider.NewError(provider.ErrorTerminal, 0, errors.Errorf("vm %s not found", id))
+	}
+
+	v.bootedAt = time.Now()
+	v.stopped = false
+	return nil
+}
+
+// Rebuild reimages the vm with the given id
+func (p *Provider) Rebuild(ctx context.Context, id, image string) error {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	v, ok := p.store.vms[id]
+	if !ok {
+		return provider.NewError(provider.ErrorTerminal, 0, errors.Errorf("vm %s not found", id))
+	}
+
+	v.config.Image = image
+	v.bootedAt = time.Now()
+	v.stopped = false
+	return nil
+}
+
+// Find returns the vms for given ids or tags
+func (p *Provider) Find(ctx context.Context, refs []string) ([]provider.InstanceConfig, error) {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	list := []provider.InstanceConfig{}
+	for _, ref := range refs {
+		if v, ok := p.store.vms[ref]; ok {
+			list = append(list, *p.status(v))
+			continue
+		}
+		list = append(list, p.list(ref)...)
+	}
+	return list, nil
+}
+
+// Tag adds the given tags to a vm
+func (p *Provider) Tag(ctx context.Context, id string, tags ...string) error {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	v, ok := p.store.vms[id]
+	if !ok {
+		return provider.NewError(provider.ErrorTerminal, 0, errors.Errorf("vm %s not found", id))
+	}
+
+	for _, tag := range tags {
+		if !hasTag(v.config.Tags, tag) {
+			v.config.Tags = append(v.config.Tags, tag)
+		}
+	}
+	return nil
+}
+
+// DeleteByID deletes the vm with the given id
+func (p *Provider) DeleteByID(ctx context.Context, id string) error {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	delete(p.store.vms, id)
+	return nil
+}
+
+// Interruption returns a notice once a vm is about to be reclaimed
+func (p *Provider) Interruption(ctx context.Context,
+	id string) (*provider.InterruptionNotice, error) {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	v, ok := p.store.vms[id]
+	if !ok || v.stopped || p.spec.ReclaimAfter == nil {
+		return nil, nil
+	}
+
+	reclaimAt := v.bootedAt.Add(duration(p.spec.BootDelay, 0) + p.spec.ReclaimAfter.Duration)
+	if time.Until(reclaimAt) > duration(p.spec.ReclaimNotice, defaultReclaimNotice) {
+		return nil, nil
+	}
+
+	return &provider.InterruptionNotice{
+		Time:   reclaimAt,
+		Reason: "simulated spot reclaim",
+	}, nil
+}
+
+// list returns the vms with the given tag, store must be locked
+func (p *Provider) list(tag string) []provider.InstanceConfig {
+	list := []provider.InstanceConfig{}
+	for _, v := range p.store.vms {
+		if hasTag(v.config.Tags, tag) {
+			list = append(list, *p.status(v))
+		}
+	}
+	return list
+}
+
+// status returns the config of a vm along with its current status
+func (p *Provider) status(v *vm) *provider.InstanceConfig {
+	config := v.config
+	config.Tags = append([]string{}, v.config.Tags...)
+
+	bootDelay := duration(p.spec.BootDelay, 0)
+	switch {
+	case v.stopped:
+		config.Status = statusOff
+	case time.Since(v.bootedAt) < bootDelay:
+		config.Status = statusNew
+	case p.spec.ReclaimAfter != nil &&
+		time.Since(v.bootedAt) >= bootDelay+p.spec.ReclaimAfter.Duration:
+		v.stopped = true
+		config.Status = statusOff
+	default:
+		config.Status = statusActive
+	}
+	config.IsRunning = config.Status == statusActive
+	return &config
+}
+
+// injectedError returns an error of the given class
+func injectedError(class string) error {
+	err := errors.New("injected failure")
+	switch provider.ErrorClass(class) {
+	case provider.ErrorThrottled:
+		return provider.NewError(provider.ErrorThrottled, 30*time.Second, err)
+	case provider.ErrorQuota, provider.ErrorInvalidConfig, provider.ErrorTerminal:
+		return provider.NewError(provider.ErrorClass(class), 0, err)
+	}
+	return provider.NewError(provider.ErrorTransient, 0, err)
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
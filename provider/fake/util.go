@@ -0,0 +1,90 @@
+package fake
+
+import (
+	"sync"
+	"time"
+
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultReclaimNotice = 2 * time.Minute
+)
+
+var (
+	_ provider.InstanceProvider     = &Provider{}
+	_ provider.Rebuilder            = &Provider{}
+	_ provider.Adopter              = &Provider{}
+	_ provider.IDDeleter            = &Provider{}
+	_ provider.InterruptionNotifier = &Provider{}
+)
+
+var (
+	defaultsLock sync.RWMutex
+	defaults     spotcluster.Fake
+)
+
+func init() {
+	provider.Register(provider.Fake, &provider.Registration{
+		New:              newProvider,
+		Template:         template,
+		Account:          account,
+		SSHUser:          sshUser,
+		ProviderIDPrefix: provider.FakeProviderID,
+	})
+}
+
+// SetDefaults sets the spec used by pools which have no fake provider spec.
+// It is used when every pool is switched to the fake provider.
+func SetDefaults(spec spotcluster.Fake) {
+	defaultsLock.Lock()
+	defer defaultsLock.Unlock()
+
+	defaults = spec
+}
+
+// newProvider returns a fake provider for the spec of a pool
+func newProvider(pool *spotcluster.Pool) (provider.InstanceProvider, error) {
+	defaultsLock.RLock()
+	defer defaultsLock.RUnlock()
+
+	spec := defaults
+	if pool.ProviderSpec.Fake != nil {
+		spec = *pool.ProviderSpec.Fake
+	}
+
+	return &Provider{
+		spec:  spec,
+		store: vms,
+	}, nil
+}
+
+// template returns the vm config of the fake provider
+func template(pool *spotcluster.Pool) provider.InstanceConfig {
+	return provider.InstanceConfig{
+		Region: "fake-region",
+		Size:   "fake-size",
+		Image:  "fake-image",
+	}
+}
+
+// sshUser returns the ssh user of the ssh test servers
+func sshUser(pool *spotcluster.Pool) string {
+	return provider.FakeRootUser
+}
+
+// account returns the account of the fake provider, all the pools share
+// the same vms.
+func account(pool *spotcluster.Pool) string {
+	return provider.Fake
+}
+
+// duration returns the value of a duration or the given default
+func duration(d *metav1.Duration, defaultDuration time.Duration) time.Duration {
+	if d == nil || d.Duration <= 0 {
+		return defaultDuration
+	}
+	return d.Duration
+}
@@ -0,0 +1,382 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	compute "google.golang.org/api/compute/v1"
+)
+
+const (
+	// labelPrefix is prefixed to a tag to get the label key, label keys
+	// have to start with a lowercase letter.
+	labelPrefix = "sc-"
+	// labelValue is the value of every tag label
+	labelValue = "true"
+	// operationTimeout is the time an insert is waited for
+	operationTimeout = 2 * time.Minute
+	// provisioningModelSpot is the provisioning model of spot vms
+	provisioningModelSpot = "SPOT"
+	// terminationActionStop stops a spot vm when it is preempted so that
+	// the preemption is found from the status of the vm, it is deleted by
+	// the controller afterwards.
+	terminationActionStop = "STOP"
+)
+
+// Client launches spot compute engine vms for a pool. Vm id is
+// project/zone/name, it is the provider id of the node without the prefix.
+type Client struct {
+	Provider *compute.Service
+	spec     spotcluster.GCP
+}
+
+// Create launches a spot vm. Zones of the pool are tried in order
+// until one of them has capacity.
+func (c *Client) Create(ctx context.Context,
+	config provider.InstanceConfig) (*provider.InstanceConfig, error) {
+	if len(c.spec.Zones) == 0 {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.New("no zone is given"))
+	}
+
+	var lastErr error
+	for _, zone := range c.spec.Zones {
+		err := c.insert(ctx, config, zone)
+		if err == nil {
+			vm, found, err := c.getVM(ctx, c.spec.Project, zone, config.Name)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				return nil, errors.Errorf("vm %s is not found after insert", config.Name)
+			}
+			return vm, nil
+		}
+
+		// Try the next zone only if there is no capacity, other errors are
+		// the same for all of them.
+		lastErr = err
+		if !isCapacityError(err) {
+			return nil, classify(err)
+		}
+	}
+	return nil, classify(lastErr)
+}
+
+// insert creates a vm in a zone and waits for the insert to finish as
+// capacity errors are only reported by the operation.
+func (c *Client) insert(ctx context.Context, config provider.InstanceConfig, zone string) error {
+	labels := make(map[string]string)
+	for _, tag := range config.Tags {
+		labels[labelKey(tag)] = labelValue
+	}
+
+	networkInterface := &compute.NetworkInterface{
+		AccessConfigs: []*compute.AccessConfig{
+			{
+				Name: "External NAT",
+				Type: "ONE_TO_ONE_NAT",
+			},
+		},
+	}
+	if c.spec.Network != "" {
+		networkInterface.Network = c.spec.Network
+	}
+	if c.spec.Subnetwork != "" {
+		networkInterface.Subnetwork = c.spec.Subnetwork
+	}
+
+	instance := &compute.Instance{
+		Name:        config.Name,
+		MachineType: fmt.Sprintf("zones/%s/machineTypes/%s", zone, config.Size),
+		Labels:      labels,
+		Disks: []*compute.AttachedDisk{
+			{
+				Boot:       true,
+				AutoDelete: true,
+				InitializeParams: &compute.AttachedDiskInitializeParams{
+					SourceImage: config.Image,
+					DiskSizeGb:  c.spec.DiskSizeGB,
+				},
+			},
+		},
+		NetworkInterfaces: []*compute.NetworkInterface{networkInterface},
+		Scheduling: &compute.Scheduling{
+			ProvisioningModel:         provisioningModelSpot,
+			InstanceTerminationAction: terminationActionStop,
+			AutomaticRestart:          new(bool),
+			OnHostMaintenance:         "TERMINATE",
+		},
+	}
+	if c.spec.SSHPublicKey != "" {
+		sshKeys := sshUser(c.spec) + ":" + strings.TrimSpace(c.spec.SSHPublicKey)
+		instance.Metadata = &compute.Metadata{
+			Items: []*compute.MetadataItems{
+				{
+					Key:   "ssh-keys",
+					Value: &sshKeys,
+				},
+			},
+		}
+	}
+
+	op, err := c.Provider.Instances.Insert(c.spec.Project, zone, instance).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	return c.wait(ctx, zone, op)
+}
+
+// wait waits for a zone operation to finish and returns its error
+func (c *Client) wait(ctx context.Context, zone string, op *compute.Operation) error {
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+
+	for op.Status != "DONE" {
+		var err error
+		op, err = c.Provider.ZoneOperations.Wait(c.spec.Project, zone, op.Name).Context(ctx).Do()
+		if err != nil {
+			return err
+		}
+	}
+
+	if op.Error != nil && len(op.Error.Errors) != 0 {
+		return &operationError{errors: op.Error.Errors}
+	}
+	return nil
+}
+
+// Get returns the vm with the given tag
+func (c *Client) Get(ctx context.Context, tag string) (*provider.InstanceConfig, bool, error) {
+	list, err := c.List(ctx, tag)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(list) == 0 {
+		return nil, false, nil
+	}
+
+	if len(list) != 1 {
+		return nil, false,
+			errors.Errorf("Got %d vms for the given tag %s", len(list), tag)
+	}
+	return &list[0], true, nil
+}
+
+// Delete deletes the vm with the given tag if found
+func (c *Client) Delete(ctx context.Context, tag string) error {
+	vm, found, err := c.Get(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return nil
+	}
+	return c.DeleteByID(ctx, vm.ID)
+}
+
+// List returns all the vms of the project with the given tag
+func (c *Client) List(ctx context.Context, tag string) ([]provider.InstanceConfig, error) {
+	list := []provider.InstanceConfig{}
+	filter := fmt.Sprintf("labels.%s = %s", labelKey(tag), labelValue)
+	err := c.Provider.Instances.AggregatedList(c.spec.Project).
+		Filter(filter).
+		Context(ctx).
+		Pages(ctx, func(page *compute.InstanceAggregatedList) error {
+			for _, scoped := range page.Items {
+				for _, instance := range scoped.Instances {
+					list = append(list, *toInstanceConfig(c.spec.Project, instance))
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, classify(err)
+	}
+	return list, nil
+}
+
+// Reboot resets the vm with the given id
+func (c *Client) Reboot(ctx context.Context, id string) error {
+	project, zone, name, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Provider.Instances.Reset(project, zone, name).Context(ctx).Do()
+	if err != nil {
+		return classify(err)
+	}
+	return nil
+}
+
+// Find returns the vms for given vm ids or tags
+func (c *Client) Find(ctx context.Context, refs []string) ([]provider.InstanceConfig, error) {
+	list := []provider.InstanceConfig{}
+	for _, ref := range refs {
+		if project, zone, name, err := parseID(ref); err == nil {
+			vm, found, err := c.getVM(ctx, project, zone, name)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				list = append(list, *vm)
+			}
+			continue
+		}
+
+		vms, err := c.List(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, vms...)
+	}
+	return list, nil
+}
+
+// Tag adds the given tags to the labels of a vm
+func (c *Client) Tag(ctx context.Context, id string, tags ...string) error {
+	project, zone, name, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	instance, err := c.Provider.Instances.Get(project, zone, name).Context(ctx).Do()
+	if err != nil {
+		return classify(err)
+	}
+
+	labels := make(map[string]string)
+	for key, value := range instance.Labels {
+		labels[key] = value
+	}
+	for _, tag := range tags {
+		labels[labelKey(tag)] = labelValue
+	}
+
+	_, err = c.Provider.Instances.SetLabels(project, zone, name, &compute.InstancesSetLabelsRequest{
+		Labels:           labels,
+		LabelFingerprint: instance.LabelFingerprint,
+	}).Context(ctx).Do()
+	if err != nil {
+		return classify(err)
+	}
+	return nil
+}
+
+// DeleteByID deletes the vm with the given id
+func (c *Client) DeleteByID(ctx context.Context, id string) error {
+	project, zone, name, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Provider.Instances.Delete(project, zone, name).Context(ctx).Do()
+	if err != nil && !isNotFound(err) {
+		return classify(err)
+	}
+	return nil
+}
+
+// Interruption returns a notice once a spot vm is preempted. Compute engine
+// stops a preempted vm, it is found from the status of the vm. Preemptible
+// vms launched before spot vms are checked the same way.
+func (c *Client) Interruption(ctx context.Context,
+	id string) (*provider.InterruptionNotice, error) {
+	project, zone, name, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	instance, err := c.Provider.Instances.Get(project, zone, name).Context(ctx).Do()
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, classify(err)
+	}
+
+	if instance.Scheduling == nil || (instance.Scheduling.ProvisioningModel != provisioningModelSpot &&
+		!instance.Scheduling.Preemptible) {
+		return nil, nil
+	}
+
+	switch instance.Status {
+	case "STOPPING", "TERMINATED":
+		return &provider.InterruptionNotice{
+			Time:   time.Now(),
+			Reason: "vm is preempted, status is " + instance.Status,
+		}, nil
+	}
+	return nil, nil
+}
+
+// getVM returns the vm with the given name in a zone
+func (c *Client) getVM(ctx context.Context,
+	project, zone, name string) (*provider.InstanceConfig, bool, error) {
+	instance, err := c.Provider.Instances.Get(project, zone, name).Context(ctx).Do()
+	if err != nil {
+		if isNotFound(err) {
+			return nil, false, nil
+		}
+		return nil, false, classify(err)
+	}
+	return toInstanceConfig(project, instance), true, nil
+}
+
+func toInstanceConfig(project string, instance *compute.Instance) *provider.InstanceConfig {
+	zone := path.Base(instance.Zone)
+	config := &provider.InstanceConfig{
+		ID:     project + "/" + zone + "/" + instance.Name,
+		Name:   instance.Name,
+		Zone:   zone,
+		Size:   path.Base(instance.MachineType),
+		Status: instance.Status,
+		Labels: instance.Labels,
+	}
+	config.IsRunning = config.Status == "RUNNING"
+
+	if index := strings.LastIndex(zone, "-"); index > 0 {
+		config.Region = zone[:index]
+	}
+
+	// Image is not set as the vm only refers to its boot disk, not to the
+	// image the disk is created from.
+	for _, networkInterface := range instance.NetworkInterfaces {
+		config.InternalIP = networkInterface.NetworkIP
+		for _, accessConfig := range networkInterface.AccessConfigs {
+			if accessConfig.NatIP != "" {
+				config.ExteralIP = accessConfig.NatIP
+			}
+		}
+	}
+
+	for key := range instance.Labels {
+		if strings.HasPrefix(key, labelPrefix) {
+			config.Tags = append(config.Tags, strings.TrimPrefix(key, labelPrefix))
+		}
+	}
+	return config
+}
+
+// labelKey returns the label key of a tag
+func labelKey(tag string) string {
+	return labelPrefix + strings.ToLower(tag)
+}
+
+// parseID splits a vm id into project, zone and name
+func parseID(id string) (string, string, string, error) {
+	parts := strings.Split(id, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", errors.Errorf("invalid vm id %s", id)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
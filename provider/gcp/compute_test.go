@@ -0,0 +1,36 @@
+package gcp
+
+import (
+	"testing"
+
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestToInstanceConfig(t *testing.T) {
+	instance := &compute.Instance{
+		Name:        "pool-abcde",
+		Zone:        "https://www.googleapis.com/compute/v1/projects/project/zones/us-central1-a",
+		MachineType: "zones/us-central1-a/machineTypes/e2-medium",
+		Status:      "RUNNING",
+		Labels: map[string]string{
+			labelKey("instance-uid"): "true",
+		},
+	}
+
+	vm := toInstanceConfig("project", instance)
+	if vm.ID != "project/us-central1-a/pool-abcde" {
+		t.Fatalf("expected id project/us-central1-a/pool-abcde, got %s", vm.ID)
+	}
+	if vm.Region != "us-central1" || vm.Size != "e2-medium" || !vm.IsRunning {
+		t.Fatalf("unexpected vm %+v", vm)
+	}
+
+	// Full id is kept by the instance, vm is found from it again.
+	project, zone, name, err := parseID(vm.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if project != "project" || zone != "us-central1-a" || name != "pool-abcde" {
+		t.Fatalf("expected project/us-central1-a/pool-abcde, got %s/%s/%s", project, zone, name)
+	}
+}
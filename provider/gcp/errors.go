@@ -0,0 +1,102 @@
+package gcp
+
+import (
+	"net/http"
+	"strings"
+
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// operationError is the error of a failed zone operation
+type operationError struct {
+	errors []*compute.OperationErrorErrors
+}
+
+func (e *operationError) Error() string {
+	messages := []string{}
+	for _, err := range e.errors {
+		messages = append(messages, err.Code+": "+err.Message)
+	}
+	return strings.Join(messages, ", ")
+}
+
+// codes returns the error codes of a failed operation
+func (e *operationError) codes() []string {
+	codes := []string{}
+	for _, err := range e.errors {
+		codes = append(codes, err.Code)
+	}
+	return codes
+}
+
+// classify wraps a compute api error with its error class
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if isCapacityError(err) {
+		return provider.NewError(provider.ErrorQuota, 0, err)
+	}
+
+	if opErr, ok := err.(*operationError); ok {
+		for _, code := range opErr.codes() {
+			switch code {
+			case "QUOTA_EXCEEDED":
+				return provider.NewError(provider.ErrorQuota, 0, err)
+			case "RESOURCE_NOT_FOUND", "INVALID_FIELD_VALUE", "PERMISSIONS_ERROR":
+				return provider.NewError(provider.ErrorInvalidConfig, 0, err)
+			}
+		}
+		return provider.NewError(provider.ErrorTransient, 0, err)
+	}
+
+	apiErr, ok := err.(*googleapi.Error)
+	if !ok {
+		return provider.NewError(provider.ErrorTransient, 0, err)
+	}
+
+	for _, item := range apiErr.Errors {
+		switch item.Reason {
+		case "rateLimitExceeded", "userRateLimitExceeded":
+			return provider.NewError(provider.ErrorThrottled, 0, err)
+		case "quotaExceeded":
+			return provider.NewError(provider.ErrorQuota, 0, err)
+		}
+	}
+
+	switch apiErr.Code {
+	case http.StatusTooManyRequests:
+		return provider.NewError(provider.ErrorThrottled, 0, err)
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden:
+		return provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	case http.StatusNotFound:
+		return provider.NewError(provider.ErrorTerminal, 0, err)
+	}
+	return provider.NewError(provider.ErrorTransient, 0, err)
+}
+
+// isCapacityError returns true if a zone has no capacity for spot vms
+func isCapacityError(err error) bool {
+	opErr, ok := err.(*operationError)
+	if !ok {
+		return false
+	}
+
+	for _, code := range opErr.codes() {
+		switch code {
+		case "ZONE_RESOURCE_POOL_EXHAUSTED", "ZONE_RESOURCE_POOL_EXHAUSTED_WITH_DETAILS",
+			"RESOURCE_POOL_EXHAUSTED":
+			return true
+		}
+	}
+	return false
+}
+
+// isNotFound returns true if the vm is not found
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*googleapi.Error)
+	return ok && apiErr.Code == http.StatusNotFound
+}
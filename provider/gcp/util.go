@@ -0,0 +1,147 @@
+package gcp
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+var (
+	_ provider.InstanceProvider     = &Client{}
+	_ provider.Adopter              = &Client{}
+	_ provider.IDDeleter            = &Client{}
+	_ provider.InterruptionNotifier = &Client{}
+)
+
+var (
+	servicesLock sync.Mutex
+	// services are the compute services of accounts. A service is shared
+	// by every pool of a project and credentials secret, it is created
+	// again once the secret changes.
+	services = make(map[string]*sharedService)
+)
+
+// sharedService is a compute service and the resource version of the
+// credentials secret it is created with
+type sharedService struct {
+	service *compute.Service
+	version string
+}
+
+func init() {
+	provider.Register(provider.GCP, &provider.Registration{
+		New:      newProvider,
+		Template: template,
+		Account:  account,
+		SSHUser: func(pool *spotcluster.Pool) string {
+			return sshUser(*pool.ProviderSpec.GCP)
+		},
+		ProviderIDPrefix: provider.GCPProviderID,
+	})
+}
+
+// newProvider returns a compute client for the credentials of a pool
+func newProvider(pool *spotcluster.Pool) (provider.InstanceProvider, error) {
+	return newClient(pool)
+}
+
+// template returns the vm config asked for by a pool
+func template(pool *spotcluster.Pool) provider.InstanceConfig {
+	return provider.InstanceConfig{
+		Image: pool.ProviderSpec.GCP.Image,
+		Size:  pool.ProviderSpec.GCP.MachineType,
+	}
+}
+
+// account returns the project and the credentials secret of a pool
+func account(pool *spotcluster.Pool) string {
+	spec := pool.ProviderSpec.GCP
+	if spec.CredentialsSecret == nil {
+		return spec.Project
+	}
+	return spec.Project + "/" + spec.CredentialsSecret.Namespace +
+		"/" + spec.CredentialsSecret.Name
+}
+
+// sshUser returns the user whose key is added to the vms of a pool
+func sshUser(spec spotcluster.GCP) string {
+	if spec.SSHUser != "" {
+		return spec.SSHUser
+	}
+	return provider.GCPDefaultUser
+}
+
+// newClient returns a compute client for the credentials of a pool. Api
+// stand-in is used without authentication if the pool has no credentials.
+func newClient(pool *spotcluster.Pool) (*Client, error) {
+	spec := pool.ProviderSpec.GCP
+	if spec == nil {
+		return nil, errors.Errorf("pool %s has no gcp provider spec", pool.GetName())
+	}
+
+	if spec.Project == "" {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Errorf("pool %s has no gcp project", pool.GetName()))
+	}
+
+	service, err := sharedComputeService(context.TODO(), spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		Provider: service,
+		spec:     *spec,
+	}, nil
+}
+
+// sharedComputeService returns the compute service of the project and the
+// credentials secret of a pool. Service is created on first use and again
+// whenever the resource version of the secret changes.
+func sharedComputeService(ctx context.Context, spec *spotcluster.GCP) (*compute.Service, error) {
+	key := spec.Project + "@" + spec.Endpoint
+	options := []option.ClientOption{}
+	version := ""
+	switch {
+	case spec.CredentialsSecret != nil:
+		credentials, secretVersion, err := provider.ReadSecretVersion(ctx, spec.CredentialsSecret)
+		if err != nil {
+			return nil, err
+		}
+		version = secretVersion
+		key = spec.Project + "/" + spec.CredentialsSecret.Namespace + "/" +
+			spec.CredentialsSecret.Name + "/" + spec.CredentialsSecret.Key + "@" + spec.Endpoint
+		options = append(options, option.WithCredentialsJSON(credentials))
+	case spec.Endpoint != "":
+		options = append(options, option.WithoutAuthentication())
+	default:
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Errorf("project %s has no gcp credentials secret", spec.Project))
+	}
+	if spec.Endpoint != "" {
+		options = append(options, option.WithEndpoint(spec.Endpoint))
+	}
+
+	servicesLock.Lock()
+	defer servicesLock.Unlock()
+
+	if shared, ok := services[key]; ok && shared.version == version {
+		return shared.service, nil
+	}
+
+	service, err := compute.NewService(context.Background(), options...)
+	if err != nil {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	}
+
+	services[key] = &sharedService{
+		service: service,
+		version: version,
+	}
+	return service, nil
+}
@@ -0,0 +1,94 @@
+package gcp
+
+import (
+	"context"
+	"testing"
+
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	compute "google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testCredentials = `{"type": "authorized_user", "client_id": "client",
+	"client_secret": "secret", "refresh_token": "token"}`
+
+func newTestPool(project, secret string) *spotcluster.Pool {
+	return &spotcluster.Pool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pool",
+		},
+		ProviderSpec: spotcluster.ProviderSpec{
+			GCP: &spotcluster.GCP{
+				Project: project,
+				CredentialsSecret: &spotcluster.SecretKeyRef{
+					Namespace: "spotcluster",
+					Name:      secret,
+					Key:       "credentials",
+				},
+			},
+		},
+	}
+}
+
+func TestSharedComputeService(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	provider.SetKubeClient(kubeClient)
+	for _, name := range []string{"gcp", "other"} {
+		_, err := kubeClient.CoreV1().Secrets("spotcluster").Create(context.TODO(),
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:       "spotcluster",
+					Name:            name,
+					ResourceVersion: "1",
+				},
+				Data: map[string][]byte{
+					"credentials": []byte(testCredentials),
+				},
+			}, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatalf("error creating secret: %s", err)
+		}
+	}
+
+	newService := func(project, secret string) *compute.Service {
+		client, err := newClient(newTestPool(project, secret))
+		if err != nil {
+			t.Fatalf("error creating client: %s", err)
+		}
+		return client.Provider
+	}
+
+	first := newService("project", "gcp")
+	if newService("project", "gcp") != first {
+		t.Fatal("expected the service to be shared by pools of an account")
+	}
+	if newService("project", "other") == first {
+		t.Fatal("expected another service for another credentials secret")
+	}
+	if newService("other", "gcp") == first {
+		t.Fatal("expected another service for another project")
+	}
+
+	secret, err := kubeClient.CoreV1().Secrets("spotcluster").Get(context.TODO(),
+		"gcp", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting secret: %s", err)
+	}
+	secret.ResourceVersion = "2"
+	_, err = kubeClient.CoreV1().Secrets("spotcluster").Update(context.TODO(),
+		secret, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("error updating secret: %s", err)
+	}
+
+	second := newService("project", "gcp")
+	if second == first {
+		t.Fatal("expected the service to be created again once the secret changes")
+	}
+	if newService("project", "gcp") != second {
+		t.Fatal("expected the new service to be shared")
+	}
+}
@@ -0,0 +1,56 @@
+package hetzner
+
+import (
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+// classify classifies an error returned by the hetzner cloud api. Rate
+// limit reset of the response is used as the retry delay when throttled.
+func classify(err error, resp *hcloud.Response) error {
+	if err == nil {
+		return nil
+	}
+
+	hErr, ok := err.(hcloud.Error)
+	if !ok {
+		return provider.NewError(provider.ErrorTransient, 0, err)
+	}
+
+	switch hErr.Code {
+	case hcloud.ErrorCodeRateLimitExceeded:
+		return provider.NewError(provider.ErrorThrottled, retryAfter(resp), err)
+	case hcloud.ErrorCodeResourceLimitExceeded, hcloud.ErrorCodeResourceUnavailable,
+		hcloud.ErrorCode("placement_error"):
+		return provider.NewError(provider.ErrorQuota, 0, err)
+	case hcloud.ErrorCode("unauthorized"), hcloud.ErrorCodeForbidden,
+		hcloud.ErrorCodeInvalidInput, hcloud.ErrorCodeUniquenessError:
+		return provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	case hcloud.ErrorCodeNotFound:
+		return provider.NewError(provider.ErrorTerminal, 0, err)
+	default:
+		return provider.NewError(provider.ErrorTransient, 0, err)
+	}
+}
+
+// isNotFound returns true if the resource is not found at hetzner cloud
+func isNotFound(err error) bool {
+	return hcloud.IsError(err, hcloud.ErrorCodeNotFound)
+}
+
+// isUnavailable returns true if a server type is not available in a
+// location
+func isUnavailable(err error) bool {
+	return hcloud.IsError(err, hcloud.ErrorCodeResourceUnavailable) ||
+		hcloud.IsError(err, hcloud.ErrorCode("placement_error"))
+}
+
+// retryAfter returns the time until the rate limit of the response resets
+func retryAfter(resp *hcloud.Response) time.Duration {
+	if resp == nil || resp.Meta.Ratelimit.Reset.IsZero() {
+		return 0
+	}
+	return time.Until(resp.Meta.Ratelimit.Reset)
+}
@@ -0,0 +1,375 @@
+package hetzner
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+// Client is a wrapper over hcloud client
+type Client struct {
+	Provider *hcloud.Client
+	spec     spotcluster.Hetzner
+}
+
+// Create creates new server. Server types and locations of the pool are
+// tried in order until one of them is available.
+func (c *Client) Create(ctx context.Context,
+	config provider.InstanceConfig) (*provider.InstanceConfig, error) {
+	if len(c.spec.ServerTypes) == 0 {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.New("no server type is given"))
+	}
+
+	sshKeys, err := c.sshKeys(ctx, config.SSHFingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	networks, err := c.networks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make(map[string]string)
+	for _, tag := range config.Tags {
+		labels[tag] = ""
+	}
+
+	locations := c.spec.Locations
+	if len(locations) == 0 {
+		locations = []string{""}
+	}
+
+	var lastErr error
+	for _, serverType := range c.spec.ServerTypes {
+		for _, location := range locations {
+			opts := hcloud.ServerCreateOpts{
+				Name:       config.Name,
+				ServerType: &hcloud.ServerType{Name: serverType},
+				Image:      &hcloud.Image{Name: config.Image},
+				SSHKeys:    sshKeys,
+				Networks:   networks,
+				Labels:     labels,
+			}
+			if location != "" {
+				opts.Location = &hcloud.Location{Name: location}
+			}
+
+			result, resp, err := c.Provider.Server.Create(ctx, opts)
+			if err == nil {
+				return toInstanceConfig(result.Server), nil
+			}
+
+			// Try the next server type or location only if it is not
+			// available, other errors are the same for all of them.
+			lastErr = classify(err, resp)
+			if !isUnavailable(err) {
+				return nil, lastErr
+			}
+		}
+	}
+	return nil, lastErr
+}
+
+// Get returns server details if server found for a given tag
+func (c *Client) Get(ctx context.Context, tag string) (*provider.InstanceConfig, bool, error) {
+	list, err := c.list(ctx, tag)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(list) == 0 {
+		return nil, false, nil
+	}
+
+	if len(list) != 1 {
+		return nil, false,
+			errors.Errorf("Got %d servers for the given tag %s", len(list), tag)
+	}
+
+	return toInstanceConfig(list[0]), true, nil
+}
+
+// Find returns the servers for given server ids or tags
+func (c *Client) Find(ctx context.Context, refs []string) ([]provider.InstanceConfig, error) {
+	list := []provider.InstanceConfig{}
+	for _, ref := range refs {
+		if _, err := strconv.Atoi(ref); err == nil {
+			server, found, err := c.GetByID(ctx, ref)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				list = append(list, *server)
+			}
+			continue
+		}
+
+		servers, err := c.List(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, servers...)
+	}
+	return list, nil
+}
+
+// Delete deletes a server if found
+func (c *Client) Delete(ctx context.Context, tag string) error {
+	list, err := c.list(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	if len(list) != 1 {
+		return errors.Errorf("Got %d servers for the given tag %s", len(list), tag)
+	}
+
+	resp, err := c.Provider.Server.Delete(ctx, list[0])
+	if err != nil && !isNotFound(err) {
+		return classify(err, resp)
+	}
+
+	return nil
+}
+
+// List returns details of all the servers for a given tag
+func (c *Client) List(ctx context.Context, tag string) ([]provider.InstanceConfig, error) {
+	list, err := c.list(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := []provider.InstanceConfig{}
+	for _, s := range list {
+		configs = append(configs, *toInstanceConfig(s))
+	}
+	return configs, nil
+}
+
+// GetByID returns server details for a given id, false if it is not found
+func (c *Client) GetByID(ctx context.Context, id string) (*provider.InstanceConfig, bool, error) {
+	serverID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, false, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Wrapf(err, "invalid server id %s", id))
+	}
+
+	server, resp, err := c.Provider.Server.GetByID(ctx, serverID)
+	if err != nil {
+		return nil, false, classify(err, resp)
+	}
+
+	if server == nil {
+		return nil, false, nil
+	}
+	return toInstanceConfig(server), true, nil
+}
+
+// Tag adds the given tags to the labels of a server
+func (c *Client) Tag(ctx context.Context, id string, tags ...string) error {
+	serverID, err := strconv.Atoi(id)
+	if err != nil {
+		return provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Wrapf(err, "invalid server id %s", id))
+	}
+
+	server, resp, err := c.Provider.Server.GetByID(ctx, serverID)
+	if err != nil {
+		return classify(err, resp)
+	}
+
+	if server == nil {
+		return provider.NewError(provider.ErrorTerminal, 0,
+			errors.Errorf("server %s is not found", id))
+	}
+
+	labels := make(map[string]string)
+	for key, value := range server.Labels {
+		labels[key] = value
+	}
+	for _, tag := range tags {
+		labels[tag] = ""
+	}
+
+	_, resp, err = c.Provider.Server.Update(ctx, server, hcloud.ServerUpdateOpts{
+		Labels: labels,
+	})
+	if err != nil {
+		return classify(err, resp)
+	}
+	return nil
+}
+
+// DeleteByID deletes a server for a given id
+func (c *Client) DeleteByID(ctx context.Context, id string) error {
+	serverID, err := strconv.Atoi(id)
+	if err != nil {
+		return provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Wrapf(err, "invalid server id %s", id))
+	}
+
+	resp, err := c.Provider.Server.Delete(ctx, &hcloud.Server{ID: serverID})
+	if err != nil && !isNotFound(err) {
+		return classify(err, resp)
+	}
+	return nil
+}
+
+// Reboot resets a server for a given id
+func (c *Client) Reboot(ctx context.Context, id string) error {
+	serverID, err := strconv.Atoi(id)
+	if err != nil {
+		return provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Wrapf(err, "invalid server id %s", id))
+	}
+
+	_, resp, err := c.Provider.Server.Reset(ctx, &hcloud.Server{ID: serverID})
+	if err != nil {
+		return classify(err, resp)
+	}
+	return nil
+}
+
+// Rebuild reimages a server for a given id with the given image
+func (c *Client) Rebuild(ctx context.Context, id, image string) error {
+	serverID, err := strconv.Atoi(id)
+	if err != nil {
+		return provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Wrapf(err, "invalid server id %s", id))
+	}
+
+	_, resp, err := c.Provider.Server.Rebuild(ctx, &hcloud.Server{ID: serverID},
+		hcloud.ServerRebuildOpts{
+			Image: &hcloud.Image{Name: image},
+		})
+	if err != nil {
+		return classify(err, resp)
+	}
+	return nil
+}
+
+// list returns all the servers for a given tag
+func (c *Client) list(ctx context.Context, tag string) ([]*hcloud.Server, error) {
+	list := []*hcloud.Server{}
+	opts := hcloud.ServerListOpts{
+		ListOpts: hcloud.ListOpts{
+			Page:          1,
+			LabelSelector: tag,
+		},
+	}
+
+	for {
+		servers, resp, err := c.Provider.Server.List(ctx, opts)
+		if err != nil {
+			return nil, classify(err, resp)
+		}
+
+		list = append(list, servers...)
+
+		if resp == nil || resp.Meta.Pagination == nil || resp.Meta.Pagination.NextPage == 0 {
+			break
+		}
+
+		opts.Page = resp.Meta.Pagination.NextPage
+	}
+
+	return list, nil
+}
+
+// sshKeys returns the ssh keys of the pool along with the key of the
+// given fingerprint
+func (c *Client) sshKeys(ctx context.Context, fingerprint string) ([]*hcloud.SSHKey, error) {
+	keys := []*hcloud.SSHKey{}
+	if fingerprint != "" {
+		key, resp, err := c.Provider.SSHKey.GetByFingerprint(ctx, fingerprint)
+		if err != nil {
+			return nil, classify(err, resp)
+		}
+		if key == nil {
+			return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+				errors.Errorf("ssh key %s is not found", fingerprint))
+		}
+		keys = append(keys, key)
+	}
+
+	for _, ref := range c.spec.SSHKeys {
+		key, resp, err := c.Provider.SSHKey.Get(ctx, ref)
+		if err != nil {
+			return nil, classify(err, resp)
+		}
+		if key == nil {
+			return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+				errors.Errorf("ssh key %s is not found", ref))
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// networks returns the private networks of the pool
+func (c *Client) networks(ctx context.Context) ([]*hcloud.Network, error) {
+	networks := []*hcloud.Network{}
+	for _, ref := range c.spec.Networks {
+		network, resp, err := c.Provider.Network.Get(ctx, ref)
+		if err != nil {
+			return nil, classify(err, resp)
+		}
+		if network == nil {
+			return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+				errors.Errorf("network %s is not found", ref))
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// toInstanceConfig converts a server into instance config
+func toInstanceConfig(server *hcloud.Server) *provider.InstanceConfig {
+	config := &provider.InstanceConfig{
+		ID:        strconv.Itoa(server.ID),
+		Name:      server.Name,
+		Status:    string(server.Status),
+		IsRunning: server.Status == hcloud.ServerStatusRunning,
+		Labels:    server.Labels,
+	}
+
+	if server.Datacenter != nil && server.Datacenter.Location != nil {
+		config.Region = server.Datacenter.Location.Name
+		config.Zone = server.Datacenter.Name
+	}
+
+	if server.Image != nil {
+		config.Image = server.Image.Name
+	}
+
+	if server.ServerType != nil {
+		config.Size = server.ServerType.Name
+	}
+
+	for key := range server.Labels {
+		config.Tags = append(config.Tags, key)
+	}
+
+	if server.PublicNet.IPv4.IP != nil {
+		config.ExteralIP = server.PublicNet.IPv4.IP.String()
+	}
+
+	for _, privateNet := range server.PrivateNet {
+		if privateNet.IP != nil {
+			config.InternalIP = privateNet.IP.String()
+			break
+		}
+	}
+
+	return config
+}
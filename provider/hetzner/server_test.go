@@ -0,0 +1,206 @@
+package hetzner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/hcloud/schema"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+// newServerAPI starts a stand-in of the server create api. Server type and
+// location pairs given as type@location fail with the given error code,
+// created servers are placed in the location asked for. Every create is
+// recorded as type@location.
+func newServerAPI(failures map[string]string) (*httptest.Server, *[]string) {
+	creates := []string{}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.URL.Path != "/servers" {
+				http.NotFound(w, r)
+				return
+			}
+
+			req := schema.ServerCreateRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			serverType, _ := req.ServerType.(string)
+			key := serverType + "@" + req.Location
+			creates = append(creates, key)
+
+			w.Header().Set("Content-Type", "application/json")
+			if code, ok := failures[key]; ok {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				json.NewEncoder(w).Encode(schema.ErrorResponse{
+					Error: schema.Error{
+						Code:    code,
+						Message: key + " failed with " + code,
+					},
+				})
+				return
+			}
+
+			location := req.Location
+			if location == "" {
+				location = "fsn1"
+			}
+			resp := schema.ServerCreateResponse{
+				Server: schema.Server{
+					ID:     len(creates),
+					Name:   req.Name,
+					Status: "initializing",
+					PublicNet: schema.ServerPublicNet{
+						IPv4: schema.ServerPublicNetIPv4{IP: "203.0.113.10"},
+					},
+					ServerType: schema.ServerType{Name: serverType},
+					Datacenter: schema.Datacenter{
+						Name:     location + "-dc14",
+						Location: schema.Location{Name: location},
+					},
+					Labels: *req.Labels,
+				},
+			}
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(resp)
+		}))
+	return server, &creates
+}
+
+func TestCreate(t *testing.T) {
+	tests := map[string]struct {
+		locations    []string
+		failures     map[string]string
+		wantCreates  []string
+		wantType     string
+		wantLocation string
+		wantClass    provider.ErrorClass
+	}{
+		"first server type and location": {
+			locations:    []string{"fsn1", "nbg1"},
+			wantCreates:  []string{"cx21@fsn1"},
+			wantType:     "cx21",
+			wantLocation: "fsn1",
+		},
+		"next location": {
+			locations: []string{"fsn1", "nbg1"},
+			failures: map[string]string{
+				"cx21@fsn1": "resource_unavailable",
+			},
+			wantCreates:  []string{"cx21@fsn1", "cx21@nbg1"},
+			wantType:     "cx21",
+			wantLocation: "nbg1",
+		},
+		"next server type": {
+			locations: []string{"fsn1", "nbg1"},
+			failures: map[string]string{
+				"cx21@fsn1": "resource_unavailable",
+				"cx21@nbg1": "placement_error",
+			},
+			wantCreates:  []string{"cx21@fsn1", "cx21@nbg1", "cpx21@fsn1"},
+			wantType:     "cpx21",
+			wantLocation: "fsn1",
+		},
+		"any location": {
+			failures: map[string]string{
+				"cx21@": "resource_unavailable",
+			},
+			wantCreates:  []string{"cx21@", "cpx21@"},
+			wantType:     "cpx21",
+			wantLocation: "fsn1",
+		},
+		"nothing is available": {
+			locations: []string{"fsn1"},
+			failures: map[string]string{
+				"cx21@fsn1":  "resource_unavailable",
+				"cpx21@fsn1": "resource_unavailable",
+			},
+			wantCreates: []string{"cx21@fsn1", "cpx21@fsn1"},
+			wantClass:   provider.ErrorQuota,
+		},
+		"invalid input is not retried": {
+			locations: []string{"fsn1", "nbg1"},
+			failures: map[string]string{
+				"cx21@fsn1": "invalid_input",
+			},
+			wantCreates: []string{"cx21@fsn1"},
+			wantClass:   provider.ErrorInvalidConfig,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server, creates := newServerAPI(test.failures)
+			defer server.Close()
+
+			client, err := newClient(&spotcluster.Pool{
+				ProviderSpec: spotcluster.ProviderSpec{
+					Hetzner: &spotcluster.Hetzner{
+						APIToken:    "token",
+						ServerTypes: []string{"cx21", "cpx21"},
+						Locations:   test.locations,
+						Endpoint:    server.URL,
+					},
+				},
+			})
+			if err != nil {
+				t.Fatalf("error creating client: %s", err)
+			}
+
+			vm, err := client.Create(context.TODO(), provider.InstanceConfig{
+				Name:  "instance",
+				Image: "ubuntu-20.04",
+				Tags:  []string{"instance-uid", provider.OwnerTag},
+			})
+			if got := strings.Join(*creates, ","); got != strings.Join(test.wantCreates, ",") {
+				t.Fatalf("expected creates %v, got %v", test.wantCreates, *creates)
+			}
+			if test.wantClass != "" {
+				if provider.ClassOf(err) != test.wantClass {
+					t.Fatalf("expected %s error, got %s: %v", test.wantClass, provider.ClassOf(err), err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			if vm.Size != test.wantType || vm.Region != test.wantLocation {
+				t.Fatalf("expected %s in %s, got %s in %s",
+					test.wantType, test.wantLocation, vm.Size, vm.Region)
+			}
+			if _, ok := vm.Labels["instance-uid"]; !ok {
+				t.Fatalf("expected server to be labelled with its uid, got %v", vm.Labels)
+			}
+		})
+	}
+}
+
+func TestAccount(t *testing.T) {
+	pool := &spotcluster.Pool{
+		ProviderSpec: spotcluster.ProviderSpec{
+			Hetzner: &spotcluster.Hetzner{
+				APIToken: "jEheVytlAoFl7F8MqUQ7jAo2hOXASztX",
+			},
+		},
+	}
+
+	got := account(pool)
+	if strings.Contains(got, pool.ProviderSpec.Hetzner.APIToken) {
+		t.Fatalf("expected account not to have the api token, got %s", got)
+	}
+	if got != provider.HashCredential(pool.ProviderSpec.Hetzner.APIToken) {
+		t.Fatalf("expected account to be the hash of the api token, got %s", got)
+	}
+
+	pool.ProviderSpec.Hetzner.APIToken = "other"
+	if account(pool) == got {
+		t.Fatal("expected another account for another api token")
+	}
+}
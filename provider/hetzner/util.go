@@ -0,0 +1,77 @@
+package hetzner
+
+import (
+	"github.com/hetznercloud/hcloud-go/hcloud"
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+var (
+	_ provider.InstanceProvider = &Client{}
+	_ provider.Rebuilder        = &Client{}
+	_ provider.Adopter          = &Client{}
+	_ provider.IDDeleter        = &Client{}
+)
+
+func init() {
+	provider.Register(provider.Hetzner, &provider.Registration{
+		New:              newProvider,
+		Template:         template,
+		Account:          account,
+		SSHUser:          sshUser,
+		ProviderIDPrefix: provider.HetznerProviderID,
+	})
+}
+
+// newProvider returns a server client for the api token of a pool
+func newProvider(pool *spotcluster.Pool) (provider.InstanceProvider, error) {
+	return newClient(pool)
+}
+
+// template returns the server config asked for by a pool. Size and region
+// are given only if the pool has a single server type and location.
+func template(pool *spotcluster.Pool) provider.InstanceConfig {
+	spec := pool.ProviderSpec.Hetzner
+	config := provider.InstanceConfig{
+		Image: spec.Image,
+	}
+	if len(spec.ServerTypes) == 1 {
+		config.Size = spec.ServerTypes[0]
+	}
+	if len(spec.Locations) == 1 {
+		config.Region = spec.Locations[0]
+	}
+	return config
+}
+
+// sshUser returns the ssh user of servers
+func sshUser(pool *spotcluster.Pool) string {
+	return provider.HetznerRootUser
+}
+
+// account returns the hash of the api token of a pool
+func account(pool *spotcluster.Pool) string {
+	return provider.HashCredential(pool.ProviderSpec.Hetzner.APIToken)
+}
+
+// newClient returns a server client for the api token of a pool
+func newClient(pool *spotcluster.Pool) (*Client, error) {
+	spec := pool.ProviderSpec.Hetzner
+	if spec == nil {
+		return nil, errors.Errorf("pool %s has no hetzner provider spec", pool.GetName())
+	}
+
+	options := []hcloud.ClientOption{
+		hcloud.WithToken(spec.APIToken),
+		hcloud.WithApplication("spotcluster", ""),
+	}
+	if spec.Endpoint != "" {
+		options = append(options, hcloud.WithEndpoint(spec.Endpoint))
+	}
+
+	return &Client{
+		Provider: hcloud.NewClient(options...),
+		spec:     *spec,
+	}, nil
+}
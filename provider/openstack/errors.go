@@ -0,0 +1,101 @@
+package openstack
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+const headerRetryAfter = "Retry-After"
+
+// classify classifies an error returned by an openstack api
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch err.(type) {
+	case *gophercloud.ErrUnableToReauthenticate, *gophercloud.ErrErrorAfterReauthentication,
+		*gophercloud.ErrEndpointNotFound:
+		return provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	}
+
+	resp, ok := unexpectedResponse(err)
+	if !ok {
+		return provider.NewError(provider.ErrorTransient, 0, err)
+	}
+
+	switch code := resp.Actual; {
+	case code == http.StatusTooManyRequests || code == http.StatusRequestEntityTooLarge:
+		// Rate limits of older nova releases are reported as request
+		// entity too large.
+		return provider.NewError(provider.ErrorThrottled, retryAfter(resp.ResponseHeader), err)
+	case code == http.StatusForbidden:
+		// Quota of the project is reported as forbidden.
+		if strings.Contains(strings.ToLower(string(resp.Body)), "quota exceeded") {
+			return provider.NewError(provider.ErrorQuota, 0, err)
+		}
+		return provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	case code == http.StatusBadRequest || code == http.StatusUnauthorized:
+		return provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	case code == http.StatusNotFound:
+		return provider.NewError(provider.ErrorTerminal, 0, err)
+	default:
+		return provider.NewError(provider.ErrorTransient, 0, err)
+	}
+}
+
+// isNotFound returns true if the resource is not found at openstack
+func isNotFound(err error) bool {
+	resp, ok := unexpectedResponse(err)
+	return ok && resp.Actual == http.StatusNotFound
+}
+
+// unexpectedResponse returns the response of an api error
+func unexpectedResponse(err error) (gophercloud.ErrUnexpectedResponseCode, bool) {
+	switch e := err.(type) {
+	case gophercloud.ErrUnexpectedResponseCode:
+		return e, true
+	case gophercloud.ErrDefault400:
+		return e.ErrUnexpectedResponseCode, true
+	case gophercloud.ErrDefault401:
+		return e.ErrUnexpectedResponseCode, true
+	case gophercloud.ErrDefault403:
+		return e.ErrUnexpectedResponseCode, true
+	case gophercloud.ErrDefault404:
+		return e.ErrUnexpectedResponseCode, true
+	case gophercloud.ErrDefault405:
+		return e.ErrUnexpectedResponseCode, true
+	case gophercloud.ErrDefault408:
+		return e.ErrUnexpectedResponseCode, true
+	case gophercloud.ErrDefault409:
+		return e.ErrUnexpectedResponseCode, true
+	case gophercloud.ErrDefault429:
+		return e.ErrUnexpectedResponseCode, true
+	case gophercloud.ErrDefault500:
+		return e.ErrUnexpectedResponseCode, true
+	case gophercloud.ErrDefault503:
+		return e.ErrUnexpectedResponseCode, true
+	}
+	return gophercloud.ErrUnexpectedResponseCode{}, false
+}
+
+// retryAfter returns the time to wait before the next request as asked by
+// the Retry-After header of the response
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get(headerRetryAfter)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,490 @@
+package openstack
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/availabilityzones"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/keypairs"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/tags"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/imageservice/v2/images"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+)
+
+const (
+	statusActive = "ACTIVE"
+
+	addressTypeFixed    = "fixed"
+	addressTypeFloating = "floating"
+)
+
+// uuidPattern matches the ids of openstack resources
+var uuidPattern = regexp.MustCompile(
+	`^[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}$`)
+
+// Client is a wrapper over nova client
+type Client struct {
+	Provider *gophercloud.ServiceClient
+	endpoint gophercloud.EndpointOpts
+	spec     spotcluster.OpenStack
+}
+
+// server is a nova server along with its availability zone
+type server struct {
+	servers.Server
+	availabilityzones.ServerAvailabilityZoneExt
+}
+
+// address is an address of a server as listed by nova
+type address struct {
+	Address string `json:"addr"`
+	Version int    `json:"version"`
+	Type    string `json:"OS-EXT-IPS:type"`
+}
+
+// Create creates new server tagged with the tags of the config
+func (c *Client) Create(ctx context.Context,
+	config provider.InstanceConfig) (*provider.InstanceConfig, error) {
+	compute := withContext(ctx, c.Provider)
+
+	flavorID, err := c.flavorID(compute, config.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	imageID, err := c.imageID(ctx, config.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	networkIDs, err := c.networkIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keyName, err := c.keyName(compute, config.SSHFingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := servers.CreateOpts{
+		Name:             config.Name,
+		ImageRef:         imageID,
+		FlavorRef:        flavorID,
+		SecurityGroups:   c.spec.SecurityGroups,
+		AvailabilityZone: c.spec.AvailabilityZone,
+		Tags:             config.Tags,
+	}
+	if len(networkIDs) != 0 {
+		list := []servers.Network{}
+		for _, id := range networkIDs {
+			list = append(list, servers.Network{UUID: id})
+		}
+		opts.Networks = list
+	}
+
+	created, err := servers.Create(compute, keypairs.CreateOptsExt{
+		CreateOptsBuilder: opts,
+		KeyName:           keyName,
+	}).Extract()
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	vm, found, err := c.GetByID(ctx, created.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.Errorf("server %s is not found after create", created.ID)
+	}
+	return vm, nil
+}
+
+// Get returns server details if server found for a given tag
+func (c *Client) Get(ctx context.Context, tag string) (*provider.InstanceConfig, bool, error) {
+	list, err := c.list(ctx, tag)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(list) == 0 {
+		return nil, false, nil
+	}
+
+	if len(list) != 1 {
+		return nil, false,
+			errors.Errorf("Got %d servers for the given tag %s", len(list), tag)
+	}
+
+	return c.toInstanceConfig(list[0]), true, nil
+}
+
+// Find returns the servers for given server ids or tags
+func (c *Client) Find(ctx context.Context, refs []string) ([]provider.InstanceConfig, error) {
+	list := []provider.InstanceConfig{}
+	for _, ref := range refs {
+		if uuidPattern.MatchString(ref) {
+			vm, found, err := c.GetByID(ctx, ref)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				list = append(list, *vm)
+			}
+			continue
+		}
+
+		vms, err := c.List(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, vms...)
+	}
+	return list, nil
+}
+
+// Delete deletes a server if found
+func (c *Client) Delete(ctx context.Context, tag string) error {
+	list, err := c.list(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	if len(list) != 1 {
+		return errors.Errorf("Got %d servers for the given tag %s", len(list), tag)
+	}
+
+	return c.DeleteByID(ctx, list[0].ID)
+}
+
+// List returns details of all the servers for a given tag
+func (c *Client) List(ctx context.Context, tag string) ([]provider.InstanceConfig, error) {
+	list, err := c.list(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := []provider.InstanceConfig{}
+	for _, s := range list {
+		configs = append(configs, *c.toInstanceConfig(s))
+	}
+	return configs, nil
+}
+
+// GetByID returns server details for a given id, false if it is not found
+func (c *Client) GetByID(ctx context.Context, id string) (*provider.InstanceConfig, bool, error) {
+	var s server
+	err := servers.Get(withContext(ctx, c.Provider), id).ExtractInto(&s)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, false, nil
+		}
+		return nil, false, classify(err)
+	}
+	return c.toInstanceConfig(s), true, nil
+}
+
+// Tag adds the given tags to a server
+func (c *Client) Tag(ctx context.Context, id string, tagList ...string) error {
+	compute := withContext(ctx, c.Provider)
+	for _, tag := range tagList {
+		if err := tags.Add(compute, id, tag).ExtractErr(); err != nil {
+			return classify(err)
+		}
+	}
+	return nil
+}
+
+// DeleteByID deletes a server for a given id
+func (c *Client) DeleteByID(ctx context.Context, id string) error {
+	err := servers.Delete(withContext(ctx, c.Provider), id).ExtractErr()
+	if err != nil && !isNotFound(err) {
+		return classify(err)
+	}
+	return nil
+}
+
+// Reboot hard reboots a server for a given id
+func (c *Client) Reboot(ctx context.Context, id string) error {
+	err := servers.Reboot(withContext(ctx, c.Provider), id, servers.RebootOpts{
+		Type: servers.HardReboot,
+	}).ExtractErr()
+	if err != nil {
+		return classify(err)
+	}
+	return nil
+}
+
+// Rebuild reimages a server for a given id with the given image
+func (c *Client) Rebuild(ctx context.Context, id, image string) error {
+	imageID, err := c.imageID(ctx, image)
+	if err != nil {
+		return err
+	}
+
+	_, err = servers.Rebuild(withContext(ctx, c.Provider), id, servers.RebuildOpts{
+		ImageRef: imageID,
+	}).Extract()
+	if err != nil {
+		return classify(err)
+	}
+	return nil
+}
+
+// list returns all the servers for a given tag
+func (c *Client) list(ctx context.Context, tag string) ([]server, error) {
+	pages, err := servers.List(withContext(ctx, c.Provider), servers.ListOpts{
+		Tags: tag,
+	}).AllPages()
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	list := []server{}
+	if err := servers.ExtractServersInto(pages, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// flavorID returns the id of a flavor for its name or id
+func (c *Client) flavorID(compute *gophercloud.ServiceClient, flavor string) (string, error) {
+	if flavor == "" {
+		return "", provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.New("no flavor is given"))
+	}
+
+	pages, err := flavors.ListDetail(compute, flavors.ListOpts{}).AllPages()
+	if err != nil {
+		return "", classify(err)
+	}
+
+	list, err := flavors.ExtractFlavors(pages)
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range list {
+		if f.Name == flavor || f.ID == flavor {
+			return f.ID, nil
+		}
+	}
+	return "", provider.NewError(provider.ErrorInvalidConfig, 0,
+		errors.Errorf("flavor %s is not found", flavor))
+}
+
+// imageID returns the id of an image for its name or id. Names are looked
+// up at glance.
+func (c *Client) imageID(ctx context.Context, image string) (string, error) {
+	if image == "" {
+		return "", provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.New("no image is given"))
+	}
+
+	if uuidPattern.MatchString(image) {
+		return image, nil
+	}
+
+	glance, err := openstack.NewImageServiceV2(c.Provider.ProviderClient, c.endpoint)
+	if err != nil {
+		return "", classify(err)
+	}
+
+	pages, err := images.List(withContext(ctx, glance), images.ListOpts{
+		Name: image,
+	}).AllPages()
+	if err != nil {
+		return "", classify(err)
+	}
+
+	list, err := images.ExtractImages(pages)
+	if err != nil {
+		return "", err
+	}
+
+	switch len(list) {
+	case 0:
+		return "", provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Errorf("image %s is not found", image))
+	case 1:
+		return list[0].ID, nil
+	default:
+		return "", provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Errorf("got %d images named %s", len(list), image))
+	}
+}
+
+// networkIDs returns the ids of the networks of the pool. Names are looked
+// up at neutron.
+func (c *Client) networkIDs(ctx context.Context) ([]string, error) {
+	ids := []string{}
+	var neutron *gophercloud.ServiceClient
+	for _, network := range c.spec.Networks {
+		if uuidPattern.MatchString(network) {
+			ids = append(ids, network)
+			continue
+		}
+
+		if neutron == nil {
+			client, err := openstack.NewNetworkV2(c.Provider.ProviderClient, c.endpoint)
+			if err != nil {
+				return nil, classify(err)
+			}
+			neutron = withContext(ctx, client)
+		}
+
+		pages, err := networks.List(neutron, networks.ListOpts{
+			Name: network,
+		}).AllPages()
+		if err != nil {
+			return nil, classify(err)
+		}
+
+		list, err := networks.ExtractNetworks(pages)
+		if err != nil {
+			return nil, err
+		}
+
+		switch len(list) {
+		case 0:
+			return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+				errors.Errorf("network %s is not found", network))
+		case 1:
+			ids = append(ids, list[0].ID)
+		default:
+			return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+				errors.Errorf("got %d networks named %s", len(list), network))
+		}
+	}
+	return ids, nil
+}
+
+// keyName returns the key pair of the pool, or the key pair of the given
+// fingerprint if the pool has none
+func (c *Client) keyName(compute *gophercloud.ServiceClient, fingerprint string) (string, error) {
+	if c.spec.KeyPair != "" || fingerprint == "" {
+		return c.spec.KeyPair, nil
+	}
+
+	pages, err := keypairs.List(compute).AllPages()
+	if err != nil {
+		return "", classify(err)
+	}
+
+	list, err := keypairs.ExtractKeyPairs(pages)
+	if err != nil {
+		return "", err
+	}
+
+	for _, key := range list {
+		if key.Fingerprint == fingerprint {
+			return key.Name, nil
+		}
+	}
+	return "", provider.NewError(provider.ErrorInvalidConfig, 0,
+		errors.Errorf("key pair of fingerprint %s is not found", fingerprint))
+}
+
+// toInstanceConfig converts a server into instance config. Image is not
+// set as nova only refers to the image by id while the pool may name it.
+func (c *Client) toInstanceConfig(s server) *provider.InstanceConfig {
+	config := &provider.InstanceConfig{
+		ID:        s.ID,
+		Name:      s.Name,
+		Region:    c.endpoint.Region,
+		Zone:      s.AvailabilityZone,
+		Status:    s.Status,
+		IsRunning: s.Status == statusActive,
+		Labels:    s.Metadata,
+	}
+
+	if name, ok := s.Flavor["original_name"].(string); ok {
+		config.Size = name
+	} else if id, ok := s.Flavor["id"].(string); ok {
+		config.Size = id
+	}
+
+	if s.Tags != nil {
+		config.Tags = append(config.Tags, *s.Tags...)
+	}
+
+	fixed, floating := addresses(s)
+	if len(fixed) != 0 {
+		config.InternalIP = fixed[0]
+	}
+
+	// Servers of private clouds are often reachable at their fixed ip
+	// only, it is used as the external ip if the server has no floating
+	// ip.
+	switch {
+	case len(floating) != 0:
+		config.ExteralIP = floating[0]
+	case s.AccessIPv4 != "":
+		config.ExteralIP = s.AccessIPv4
+	default:
+		config.ExteralIP = config.InternalIP
+	}
+
+	return config
+}
+
+// addresses returns the fixed and floating ipv4 addresses of a server.
+// Networks are taken in the order of their names.
+func addresses(s server) ([]string, []string) {
+	names := []string{}
+	for name := range s.Addresses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fixed := []string{}
+	floating := []string{}
+	for _, name := range names {
+		data, err := json.Marshal(s.Addresses[name])
+		if err != nil {
+			continue
+		}
+		list := []address{}
+		if err := json.Unmarshal(data, &list); err != nil {
+			continue
+		}
+
+		for _, a := range list {
+			if a.Version != 4 {
+				continue
+			}
+			switch a.Type {
+			case addressTypeFloating:
+				floating = append(floating, a.Address)
+			case addressTypeFixed, "":
+				fixed = append(fixed, a.Address)
+			}
+		}
+	}
+	return fixed, floating
+}
+
+// withContext returns a copy of a service client whose requests are made
+// with the given context
+func withContext(ctx context.Context, service *gophercloud.ServiceClient) *gophercloud.ServiceClient {
+	client := *service.ProviderClient
+	client.Context = ctx
+
+	s := *service
+	s.ProviderClient = &client
+	return &s
+}
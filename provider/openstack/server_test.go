@@ -0,0 +1,286 @@
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/shovanmaity/spotcluster/provider/openstack/testserver"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newTestPool returns a pool whose clouds.yaml is kept in a secret
+func newTestPool(cloudsYAML, cloud string) *spotcluster.Pool {
+	provider.SetKubeClient(fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "spotcluster",
+			Name:      "openstack",
+		},
+		Data: map[string][]byte{
+			"clouds.yaml": []byte(cloudsYAML),
+		},
+	}))
+
+	return &spotcluster.Pool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pool",
+		},
+		ProviderSpec: spotcluster.ProviderSpec{
+			OpenStack: &spotcluster.OpenStack{
+				CloudsSecret: &spotcluster.SecretKeyRef{
+					Namespace: "spotcluster",
+					Name:      "openstack",
+					Key:       "clouds.yaml",
+				},
+				Cloud:  cloud,
+				Flavor: "m1.small",
+				Image:  "ubuntu-20.04",
+			},
+		},
+	}
+}
+
+func newTestClient(t *testing.T, server *testserver.Server) *Client {
+	server.Username = "demo"
+	server.Password = "secret"
+	client, err := newClient(newTestPool(server.CloudsYAML("openstack"), ""))
+	if err != nil {
+		t.Fatalf("error creating client: %s", err)
+	}
+	return client
+}
+
+func TestNewClient(t *testing.T) {
+	server := testserver.NewServer()
+	defer server.Close()
+	server.Username = "demo"
+	server.Password = "secret"
+
+	tests := map[string]struct {
+		password  string
+		cloud     string
+		wantClass provider.ErrorClass
+	}{
+		"valid credentials": {
+			password: "secret",
+		},
+		"wrong password": {
+			password:  "wrong",
+			wantClass: provider.ErrorInvalidConfig,
+		},
+		"missing cloud": {
+			password:  "secret",
+			cloud:     "other",
+			wantClass: provider.ErrorInvalidConfig,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server.Password = test.password
+			cloudsYAML := server.CloudsYAML("openstack")
+			server.Password = "secret"
+
+			_, err := newClient(newTestPool(cloudsYAML, test.cloud))
+			if test.wantClass == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %s", err)
+				}
+				return
+			}
+			if provider.ClassOf(err) != test.wantClass {
+				t.Fatalf("expected %s error, got %s: %v", test.wantClass, provider.ClassOf(err), err)
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	tests := map[string]struct {
+		flavor        string
+		image         string
+		instanceLimit int
+		wantClass     provider.ErrorClass
+	}{
+		"server is created": {},
+		"missing flavor": {
+			flavor:    "m1.huge",
+			wantClass: provider.ErrorInvalidConfig,
+		},
+		"missing image": {
+			image:     "centos-8",
+			wantClass: provider.ErrorInvalidConfig,
+		},
+		"quota exceeded": {
+			instanceLimit: 1,
+			wantClass:     provider.ErrorQuota,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := testserver.NewServer()
+			defer server.Close()
+			server.InstanceLimit = test.instanceLimit
+			if test.instanceLimit != 0 {
+				server.AddServer("existing")
+			}
+
+			config := template(newTestPool("", ""))
+			if test.flavor != "" {
+				config.Size = test.flavor
+			}
+			if test.image != "" {
+				config.Image = test.image
+			}
+			config.Name = "instance"
+			config.Tags = []string{"instance-uid", provider.OwnerTag}
+
+			vm, err := newTestClient(t, server).Create(context.TODO(), config)
+			if test.wantClass != "" {
+				if provider.ClassOf(err) != test.wantClass {
+					t.Fatalf("expected %s error, got %s: %v", test.wantClass, provider.ClassOf(err), err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			if vm.Name != "instance" || vm.Size != "m1.small" || vm.Region != "RegionOne" {
+				t.Fatalf("unexpected server %+v", vm)
+			}
+			if !hasTag(vm.Tags, "instance-uid") || !hasTag(vm.Tags, provider.OwnerTag) {
+				t.Fatalf("expected server to be tagged, got %v", vm.Tags)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	tests := map[string]struct {
+		tagged    int
+		wantFound bool
+		wantErr   bool
+	}{
+		"no server": {
+			tagged: 0,
+		},
+		"one server": {
+			tagged:    1,
+			wantFound: true,
+		},
+		"more than one server": {
+			tagged:  2,
+			wantErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := testserver.NewServer()
+			defer server.Close()
+			for i := 0; i < test.tagged; i++ {
+				server.AddServer(fmt.Sprintf("server-%d", i), "instance-uid", provider.OwnerTag)
+			}
+			server.AddServer("other", "other-uid", provider.OwnerTag)
+
+			vm, found, err := newTestClient(t, server).Get(context.TODO(), "instance-uid")
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			if found != test.wantFound {
+				t.Fatalf("expected found %t, got %t", test.wantFound, found)
+			}
+			if found && (vm.Name != "server-0" || !vm.IsRunning) {
+				t.Fatalf("expected active server-0, got %+v", vm)
+			}
+		})
+	}
+}
+
+func TestAddresses(t *testing.T) {
+	tests := map[string]struct {
+		floatingIPs  bool
+		networks     int
+		wantInternal string
+		wantExternal string
+	}{
+		"fixed ip only": {
+			wantInternal: "10.0.0.1",
+			wantExternal: "10.0.0.1",
+		},
+		"floating ip": {
+			floatingIPs:  true,
+			wantInternal: "10.0.0.1",
+			wantExternal: "172.24.0.1",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := testserver.NewServer()
+			defer server.Close()
+			server.FloatingIPs = test.floatingIPs
+
+			config := template(newTestPool("", ""))
+			config.Name = "instance"
+			config.Tags = []string{"instance-uid"}
+			client := newTestClient(t, server)
+			if _, err := client.Create(context.TODO(), config); err != nil {
+				t.Fatalf("error creating server: %s", err)
+			}
+
+			vm, found, err := client.Get(context.TODO(), "instance-uid")
+			if err != nil || !found {
+				t.Fatalf("expected server to be found, got found %t: %v", found, err)
+			}
+			if vm.InternalIP != test.wantInternal || vm.ExteralIP != test.wantExternal {
+				t.Fatalf("expected internal ip %s and external ip %s, got %s and %s",
+					test.wantInternal, test.wantExternal, vm.InternalIP, vm.ExteralIP)
+			}
+		})
+	}
+}
+
+func TestDeleteByID(t *testing.T) {
+	server := testserver.NewServer()
+	defer server.Close()
+	view := server.AddServer("server", "instance-uid")
+	client := newTestClient(t, server)
+
+	for _, id := range []string{view.ID, view.ID} {
+		if err := client.DeleteByID(context.TODO(), id); err != nil {
+			t.Fatalf("expected no error deleting %s, got %s", id, err)
+		}
+	}
+	if left := len(server.Servers()); left != 0 {
+		t.Fatalf("expected no server left, got %d", left)
+	}
+
+	server.Fail(http.MethodDelete, "/compute/v2.1/servers", http.StatusTooManyRequests)
+	err := client.DeleteByID(context.TODO(), view.ID)
+	if provider.ClassOf(err) != provider.ErrorThrottled {
+		t.Fatalf("expected %s error, got %s: %v", provider.ErrorThrottled, provider.ClassOf(err), err)
+	}
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
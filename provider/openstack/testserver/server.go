@@ -0,0 +1,698 @@
+// Package testserver is an in-memory stand-in for the keystone, nova,
+// glance and neutron apis of an openstack cloud. It serves the endpoints
+// used by spotcluster so that the openstack provider can be exercised
+// without an openstack cloud.
+package testserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRegion = "RegionOne"
+	defaultZone   = "nova"
+
+	headerAuthToken    = "X-Auth-Token"
+	headerSubjectToken = "X-Subject-Token"
+)
+
+// Server is an openstack api stand-in which keeps its state in memory
+type Server struct {
+	sync.Mutex
+	server *httptest.Server
+
+	// Username and Password are the credentials accepted by keystone, any
+	// credentials are accepted if Username is empty.
+	Username string
+	Password string
+	// Region is the region of the service catalog, RegionOne if empty
+	Region string
+	// BootDelay is the time a new server takes to become active
+	BootDelay time.Duration
+	// InstanceLimit is the instance quota of the project, zero means no
+	// limit
+	InstanceLimit int
+	// FloatingIPs gives every new server a floating ip
+	FloatingIPs bool
+
+	tokens   map[string]bool
+	servers  map[string]*server
+	flavors  []flavor
+	images   []image
+	networks []network
+	keyPairs []keyPair
+	failures []failure
+
+	lastToken    int
+	lastServerID int
+	lastFloating int
+}
+
+// Address is an ip of a server
+type Address struct {
+	Address string `json:"addr"`
+	Version int    `json:"version"`
+	Type    string `json:"OS-EXT-IPS:type"`
+}
+
+// View is a server as it is seen through the api
+type View struct {
+	ID               string               `json:"id"`
+	Name             string               `json:"name"`
+	Status           string               `json:"status"`
+	Flavor           map[string]string    `json:"flavor"`
+	Image            map[string]string    `json:"image"`
+	Addresses        map[string][]Address `json:"addresses"`
+	Metadata         map[string]string    `json:"metadata"`
+	Tags             []string             `json:"tags"`
+	KeyName          string               `json:"key_name,omitempty"`
+	AvailabilityZone string               `json:"OS-EXT-AZ:availability_zone"`
+	Created          string               `json:"created"`
+	Updated          string               `json:"updated"`
+}
+
+type server struct {
+	View
+	bootedAt time.Time
+}
+
+type flavor struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	RAM   int    `json:"ram"`
+	VCPUs int    `json:"vcpus"`
+	Disk  int    `json:"disk"`
+}
+
+type image struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+type network struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+type keyPair struct {
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
+	PublicKey   string `json:"public_key"`
+}
+
+// createRequest is a server create request
+type createRequest struct {
+	Server struct {
+		Name             string `json:"name"`
+		ImageRef         string `json:"imageRef"`
+		FlavorRef        string `json:"flavorRef"`
+		KeyName          string `json:"key_name"`
+		AvailabilityZone string `json:"availability_zone"`
+		Networks         []struct {
+			UUID string `json:"uuid"`
+		} `json:"networks"`
+		Tags     []string          `json:"tags"`
+		Metadata map[string]string `json:"metadata"`
+	} `json:"server"`
+}
+
+// actionRequest is a server action request
+type actionRequest struct {
+	Reboot *struct {
+		Type string `json:"type"`
+	} `json:"reboot"`
+	Rebuild *struct {
+		ImageRef string `json:"imageRef"`
+	} `json:"rebuild"`
+}
+
+// failure is an error returned for the next request which matches it
+type failure struct {
+	method string
+	prefix string
+	status int
+}
+
+// NewServer starts a new api stand-in with a few flavors, an ubuntu image
+// and a private network. It must be closed once done.
+func NewServer() *Server {
+	s := &Server{
+		tokens:  make(map[string]bool),
+		servers: make(map[string]*server),
+		flavors: []flavor{
+			{ID: "1", Name: "m1.small", RAM: 2048, VCPUs: 1, Disk: 20},
+			{ID: "2", Name: "m1.medium", RAM: 4096, VCPUs: 2, Disk: 40},
+			{ID: "3", Name: "m1.large", RAM: 8192, VCPUs: 4, Disk: 80},
+		},
+	}
+	s.addImage("ubuntu-20.04")
+	s.addNetwork("private")
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// AuthURL returns the keystone url which is given as the auth_url of
+// clouds.yaml
+func (s *Server) AuthURL() string {
+	return s.server.URL + "/identity/v3"
+}
+
+// CloudsYAML returns a clouds.yaml with a cloud of the given name which
+// uses the server
+func (s *Server) CloudsYAML(cloud string) string {
+	return fmt.Sprintf(`clouds:
+  %s:
+    auth:
+      auth_url: %s
+      username: %q
+      password: %q
+      project_name: demo
+      user_domain_name: Default
+      project_domain_name: Default
+    region_name: %s
+`, cloud, s.AuthURL(), s.Username, s.Password, s.region())
+}
+
+// Close shuts down the server
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// AddImage adds an image and returns its id
+func (s *Server) AddImage(name string) string {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.addImage(name)
+}
+
+// AddNetwork adds a network and returns its id
+func (s *Server) AddNetwork(name string) string {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.addNetwork(name)
+}
+
+// AddKeyPair registers a key pair, servers can only be created with
+// registered key pairs.
+func (s *Server) AddKeyPair(name, fingerprint string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.keyPairs = append(s.keyPairs, keyPair{
+		Name:        name,
+		Fingerprint: fingerprint,
+	})
+}
+
+// AddServer adds an existing server, e.g. one which is adopted later. It
+// is active right away.
+func (s *Server) AddServer(name string, tags ...string) View {
+	s.Lock()
+	defer s.Unlock()
+
+	srv := s.newServer(name, s.flavors[0], s.images[0].ID,
+		[]network{s.networks[0]}, "", defaultZone, tags)
+	srv.bootedAt = time.Time{}
+	return s.view(srv)
+}
+
+// Servers returns all the servers
+func (s *Server) Servers() []View {
+	s.Lock()
+	defer s.Unlock()
+
+	list := []View{}
+	for _, id := range s.serverIDs() {
+		list = append(list, s.view(s.servers[id]))
+	}
+	return list
+}
+
+// Shutoff stops a server as if it was shut down from inside
+func (s *Server) Shutoff(id string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if srv, ok := s.servers[id]; ok {
+		srv.Status = "SHUTOFF"
+	}
+}
+
+// Fail makes the next request with the given method whose path starts with
+// the given prefix fail with the given status, e.g. Fail("POST",
+// "/compute/v2.1/servers", 429).
+func (s *Server) Fail(method, prefix string, status int) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.failures = append(s.failures, failure{
+		method: method,
+		prefix: prefix,
+		status: status,
+	})
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	for i, f := range s.failures {
+		if f.method == r.Method && strings.HasPrefix(r.URL.Path, f.prefix) {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			if f.status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "1")
+			}
+			writeError(w, f.status, "injected failure")
+			return
+		}
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "identity/v3/auth/tokens" && r.Method == http.MethodPost {
+		s.serveTokens(w, r)
+		return
+	}
+
+	if !s.tokens[r.Header.Get(headerAuthToken)] {
+		writeError(w, http.StatusUnauthorized, "The request you have made requires authentication.")
+		return
+	}
+
+	switch parts := strings.Split(path, "/"); {
+	case strings.HasPrefix(path, "compute/v2.1/"):
+		s.serveCompute(w, r, parts[2:])
+	case path == "image/v2/images" && r.Method == http.MethodGet:
+		images := []image{}
+		for _, i := range s.images {
+			if name := r.URL.Query().Get("name"); name == "" || i.Name == name {
+				images = append(images, i)
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"images": images})
+	case path == "network/v2.0/networks" && r.Method == http.MethodGet:
+		networks := []network{}
+		for _, n := range s.networks {
+			if name := r.URL.Query().Get("name"); name == "" || n.Name == name {
+				networks = append(networks, n)
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"networks": networks})
+	default:
+		writeError(w, http.StatusNotFound, "The resource could not be found.")
+	}
+}
+
+// serveTokens issues a project scoped token along with the service
+// catalog
+func (s *Server) serveTokens(w http.ResponseWriter, r *http.Request) {
+	request := struct {
+		Auth struct {
+			Identity struct {
+				Password struct {
+					User struct {
+						Name     string `json:"name"`
+						Password string `json:"password"`
+					} `json:"user"`
+				} `json:"password"`
+			} `json:"identity"`
+		} `json:"auth"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user := request.Auth.Identity.Password.User
+	if s.Username != "" && (user.Name != s.Username || user.Password != s.Password) {
+		writeError(w, http.StatusUnauthorized, "The request you have made requires authentication.")
+		return
+	}
+
+	s.lastToken++
+	token := fmt.Sprintf("token-%d", s.lastToken)
+	s.tokens[token] = true
+
+	endpoint := func(path string) []map[string]string {
+		return []map[string]string{{
+			"id":        path,
+			"interface": "public",
+			"region":    s.region(),
+			"region_id": s.region(),
+			"url":       s.server.URL + path,
+		}}
+	}
+
+	w.Header().Set(headerSubjectToken, token)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"token": map[string]interface{}{
+			"expires_at": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+			"project":    map[string]string{"id": "demo", "name": "demo"},
+			"catalog": []map[string]interface{}{
+				{"type": "identity", "name": "keystone", "endpoints": endpoint("/identity/v3/")},
+				{"type": "compute", "name": "nova", "endpoints": endpoint("/compute/v2.1/")},
+				{"type": "image", "name": "glance", "endpoints": endpoint("/image/")},
+				{"type": "network", "name": "neutron", "endpoints": endpoint("/network/")},
+			},
+		},
+	})
+}
+
+func (s *Server) serveCompute(w http.ResponseWriter, r *http.Request, parts []string) {
+	switch {
+	case parts[0] == "servers" && len(parts) == 1 && r.Method == http.MethodPost:
+		s.createServer(w, r)
+	case parts[0] == "servers" && (len(parts) == 1 || parts[1] == "detail") &&
+		r.Method == http.MethodGet:
+		tags := []string{}
+		if value := r.URL.Query().Get("tags"); value != "" {
+			tags = strings.Split(value, ",")
+		}
+		servers := []View{}
+		for _, id := range s.serverIDs() {
+			srv := s.servers[id]
+			if hasTags(srv.Tags, tags) {
+				servers = append(servers, s.view(srv))
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"servers": servers})
+	case parts[0] == "servers" && len(parts) >= 2:
+		s.serveServer(w, r, parts[1], parts[2:])
+	case parts[0] == "flavors" && len(parts) == 2 && parts[1] == "detail" &&
+		r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"flavors": s.flavors})
+	case parts[0] == "os-keypairs" && len(parts) == 1 && r.Method == http.MethodGet:
+		keyPairs := []map[string]keyPair{}
+		for _, key := range s.keyPairs {
+			keyPairs = append(keyPairs, map[string]keyPair{"keypair": key})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keypairs": keyPairs})
+	default:
+		writeError(w, http.StatusNotFound, "The resource could not be found.")
+	}
+}
+
+func (s *Server) createServer(w http.ResponseWriter, r *http.Request) {
+	request := &createRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	spec := request.Server
+
+	if s.InstanceLimit > 0 && len(s.servers) >= s.InstanceLimit {
+		writeError(w, http.StatusForbidden, fmt.Sprintf(
+			"Quota exceeded for instances: Requested 1, but already used %d of %d instances",
+			len(s.servers), s.InstanceLimit))
+		return
+	}
+
+	if spec.Name == "" {
+		writeError(w, http.StatusBadRequest, "Invalid input for field/attribute name.")
+		return
+	}
+
+	f, ok := s.getFlavor(spec.FlavorRef)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Flavor "+spec.FlavorRef+" could not be found.")
+		return
+	}
+
+	if !s.hasImage(spec.ImageRef) {
+		writeError(w, http.StatusBadRequest, "Image "+spec.ImageRef+" could not be found.")
+		return
+	}
+
+	networks := []network{}
+	for _, requested := range spec.Networks {
+		n, ok := s.getNetwork(requested.UUID)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "Network "+requested.UUID+" could not be found.")
+			return
+		}
+		networks = append(networks, n)
+	}
+	if len(networks) == 0 {
+		networks = append(networks, s.networks[0])
+	}
+
+	if spec.KeyName != "" && !s.hasKeyPair(spec.KeyName) {
+		writeError(w, http.StatusBadRequest, "Invalid key_name provided.")
+		return
+	}
+
+	zone := spec.AvailabilityZone
+	if zone == "" {
+		zone = defaultZone
+	}
+
+	srv := s.newServer(spec.Name, f, spec.ImageRef, networks, spec.KeyName, zone, spec.Tags)
+	for key, value := range spec.Metadata {
+		srv.Metadata[key] = value
+	}
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"server": map[string]interface{}{
+			"id":        srv.ID,
+			"links":     []interface{}{},
+			"adminPass": "secret",
+		},
+	})
+}
+
+func (s *Server) serveServer(w http.ResponseWriter, r *http.Request, id string, parts []string) {
+	srv, ok := s.servers[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Instance "+id+" could not be found.")
+		return
+	}
+
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"server": s.view(srv)})
+	case len(parts) == 0 && r.Method == http.MethodDelete:
+		delete(s.servers, id)
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 1 && parts[0] == "action" && r.Method == http.MethodPost:
+		request := &actionRequest{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		switch {
+		case request.Reboot != nil:
+			srv.Status = "ACTIVE"
+			w.WriteHeader(http.StatusAccepted)
+		case request.Rebuild != nil:
+			if !s.hasImage(request.Rebuild.ImageRef) {
+				writeError(w, http.StatusBadRequest, "Image "+request.Rebuild.ImageRef+" could not be found.")
+				return
+			}
+			srv.Image = map[string]string{"id": request.Rebuild.ImageRef}
+			srv.Status = "REBUILD"
+			srv.bootedAt = time.Now()
+			writeJSON(w, http.StatusAccepted, map[string]interface{}{"server": s.view(srv)})
+		default:
+			writeError(w, http.StatusBadRequest, "Unsupported server action.")
+		}
+	case len(parts) == 2 && parts[0] == "tags" && r.Method == http.MethodPut:
+		if !hasTag(srv.Tags, parts[1]) {
+			srv.Tags = append(srv.Tags, parts[1])
+		}
+		w.WriteHeader(http.StatusCreated)
+	default:
+		writeError(w, http.StatusNotFound, "The resource could not be found.")
+	}
+}
+
+func (s *Server) newServer(name string, f flavor, imageID string, networks []network,
+	keyName, zone string, tags []string) *server {
+	s.lastServerID++
+	n := s.lastServerID
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	addresses := map[string][]Address{}
+	for i, net := range networks {
+		addresses[net.Name] = []Address{{
+			Address: fmt.Sprintf("10.%d.%d.%d", i, n/256%256, n%256),
+			Version: 4,
+			Type:    "fixed",
+		}}
+	}
+	if s.FloatingIPs {
+		s.lastFloating++
+		first := networks[0].Name
+		addresses[first] = append(addresses[first], Address{
+			Address: fmt.Sprintf("172.24.%d.%d", s.lastFloating/256%256, s.lastFloating%256),
+			Version: 4,
+			Type:    "floating",
+		})
+	}
+
+	srv := &server{
+		View: View{
+			ID:     uuid(n),
+			Name:   name,
+			Status: "BUILD",
+			Flavor: map[string]string{
+				"original_name": f.Name,
+			},
+			Image:            map[string]string{"id": imageID},
+			Addresses:        addresses,
+			Metadata:         map[string]string{},
+			Tags:             append([]string{}, tags...),
+			KeyName:          keyName,
+			AvailabilityZone: zone,
+			Created:          now,
+			Updated:          now,
+		},
+		bootedAt: time.Now(),
+	}
+	s.servers[srv.ID] = srv
+	return srv
+}
+
+// view returns a server as it is seen through the api. New and rebuilt
+// servers become active after the boot delay.
+func (s *Server) view(srv *server) View {
+	if (srv.Status == "BUILD" || srv.Status == "REBUILD") &&
+		time.Since(srv.bootedAt) >= s.BootDelay {
+		srv.Status = "ACTIVE"
+	}
+
+	view := srv.View
+	view.Tags = append([]string{}, srv.Tags...)
+	return view
+}
+
+// serverIDs returns the ids of all servers in the order of creation
+func (s *Server) serverIDs() []string {
+	ids := []string{}
+	for id := range s.servers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (s *Server) addImage(name string) string {
+	id := uuid(1000 + len(s.images))
+	s.images = append(s.images, image{ID: id, Name: name, Status: "active"})
+	return id
+}
+
+func (s *Server) addNetwork(name string) string {
+	id := uuid(2000 + len(s.networks))
+	s.networks = append(s.networks, network{ID: id, Name: name, Status: "ACTIVE"})
+	return id
+}
+
+func (s *Server) getFlavor(ref string) (flavor, bool) {
+	for _, f := range s.flavors {
+		if f.ID == ref || f.Name == ref {
+			return f, true
+		}
+	}
+	return flavor{}, false
+}
+
+func (s *Server) hasImage(ref string) bool {
+	for _, i := range s.images {
+		if i.ID == ref {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) getNetwork(ref string) (network, bool) {
+	for _, n := range s.networks {
+		if n.ID == ref {
+			return n, true
+		}
+	}
+	return network{}, false
+}
+
+func (s *Server) hasKeyPair(name string) bool {
+	for _, key := range s.keyPairs {
+		if key.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) region() string {
+	if s.Region != "" {
+		return s.Region
+	}
+	return defaultRegion
+}
+
+// uuid returns a fixed uuid for a number so that ids sort in the order of
+// creation
+func uuid(n int) string {
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", n)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes an error the way nova does, keyed by the kind of the
+// error
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		errorKey(status): map[string]interface{}{
+			"code":    status,
+			"message": message,
+		},
+	})
+}
+
+func errorKey(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "badRequest"
+	case http.StatusUnauthorized:
+		return "error"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "itemNotFound"
+	case http.StatusTooManyRequests, http.StatusRequestEntityTooLarge:
+		return "overLimit"
+	}
+	return "computeFault"
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTags returns true if all the wanted tags are present
+func hasTags(tags []string, wanted []string) bool {
+	for _, tag := range wanted {
+		if !hasTag(tags, tag) {
+			return false
+		}
+	}
+	return true
+}
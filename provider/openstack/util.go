@@ -0,0 +1,296 @@
+package openstack
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"sigs.k8s.io/yaml"
+)
+
+// computeMicroversion is the nova api version used, 2.52 is the first
+// version which tags servers on create
+const computeMicroversion = "2.52"
+
+const defaultCloud = "openstack"
+
+var (
+	_ provider.InstanceProvider = &Client{}
+	_ provider.Rebuilder        = &Client{}
+	_ provider.Adopter          = &Client{}
+	_ provider.IDDeleter        = &Client{}
+)
+
+func init() {
+	provider.Register(provider.OpenStack, &provider.Registration{
+		New:      newProvider,
+		Template: template,
+		Account:  account,
+		SSHUser: func(pool *spotcluster.Pool) string {
+			return sshUser(*pool.ProviderSpec.OpenStack)
+		},
+		ProviderIDPrefix: provider.OpenStackProviderID,
+	})
+}
+
+// clouds is the clouds.yaml of openstack clients
+type clouds struct {
+	Clouds map[string]cloud `json:"clouds"`
+}
+
+// cloud is an entry of clouds.yaml
+type cloud struct {
+	Auth       cloudAuth `json:"auth"`
+	RegionName string    `json:"region_name"`
+	// Interface is the endpoint interface of the services, public if
+	// empty. EndpointType is its older name.
+	Interface    string `json:"interface"`
+	EndpointType string `json:"endpoint_type"`
+	// Verify is false to skip the verification of the api certificates
+	Verify *bool `json:"verify"`
+	// CACert is the path of the ca bundle of the api certificates
+	CACert string `json:"cacert"`
+}
+
+// cloudAuth is the keystone authentication of a cloud. Password and
+// application credentials are supported.
+type cloudAuth struct {
+	AuthURL                     string `json:"auth_url"`
+	Username                    string `json:"username"`
+	UserID                      string `json:"user_id"`
+	Password                    string `json:"password"`
+	UserDomainName              string `json:"user_domain_name"`
+	UserDomainID                string `json:"user_domain_id"`
+	ProjectName                 string `json:"project_name"`
+	ProjectID                   string `json:"project_id"`
+	ProjectDomainName           string `json:"project_domain_name"`
+	ProjectDomainID             string `json:"project_domain_id"`
+	DomainName                  string `json:"domain_name"`
+	DomainID                    string `json:"domain_id"`
+	ApplicationCredentialID     string `json:"application_credential_id"`
+	ApplicationCredentialName   string `json:"application_credential_name"`
+	ApplicationCredentialSecret string `json:"application_credential_secret"`
+}
+
+// newProvider returns a nova client for the clouds.yaml of a pool
+func newProvider(pool *spotcluster.Pool) (provider.InstanceProvider, error) {
+	return newClient(pool)
+}
+
+// template returns the server config asked for by a pool
+func template(pool *spotcluster.Pool) provider.InstanceConfig {
+	spec := pool.ProviderSpec.OpenStack
+	return provider.InstanceConfig{
+		Image:  spec.Image,
+		Size:   spec.Flavor,
+		Region: spec.Region,
+	}
+}
+
+// account returns the clouds.yaml secret and the cloud of a pool
+func account(pool *spotcluster.Pool) string {
+	spec := pool.ProviderSpec.OpenStack
+	if spec.CloudsSecret == nil {
+		return cloudName(*spec)
+	}
+	return spec.CloudsSecret.Namespace + "/" + spec.CloudsSecret.Name +
+		"/" + cloudName(*spec)
+}
+
+// sshUser returns the user used to provision the servers of a pool
+func sshUser(spec spotcluster.OpenStack) string {
+	if spec.SSHUser != "" {
+		return spec.SSHUser
+	}
+	return provider.OpenStackDefaultUser
+}
+
+// cloudName returns the entry of the clouds.yaml used by a pool
+func cloudName(spec spotcluster.OpenStack) string {
+	if spec.Cloud != "" {
+		return spec.Cloud
+	}
+	return defaultCloud
+}
+
+// newClient authenticates with keystone using the clouds.yaml of a pool
+// and returns a nova client for the region of the pool
+func newClient(pool *spotcluster.Pool) (*Client, error) {
+	spec := pool.ProviderSpec.OpenStack
+	if spec == nil {
+		return nil, errors.Errorf("pool %s has no openstack provider spec", pool.GetName())
+	}
+
+	if spec.CloudsSecret == nil {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Errorf("pool %s has no clouds.yaml secret", pool.GetName()))
+	}
+
+	ctx := context.TODO()
+	data, err := provider.ReadSecret(ctx, spec.CloudsSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := parseCloud(data, cloudName(*spec))
+	if err != nil {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	}
+
+	client, err := openstack.NewClient(c.Auth.AuthURL)
+	if err != nil {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	}
+	client.Context = ctx
+	client.UserAgent.Prepend("spotcluster")
+
+	transport, err := c.transport()
+	if err != nil {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0, err)
+	}
+	if transport != nil {
+		client.HTTPClient = http.Client{Transport: transport}
+	}
+
+	if err := openstack.Authenticate(client, c.authOptions()); err != nil {
+		return nil, classify(err)
+	}
+
+	region := c.RegionName
+	if spec.Region != "" {
+		region = spec.Region
+	}
+	endpoint := gophercloud.EndpointOpts{
+		Region:       region,
+		Availability: c.availability(),
+	}
+
+	compute, err := openstack.NewComputeV2(client, endpoint)
+	if err != nil {
+		return nil, classify(err)
+	}
+	compute.Microversion = computeMicroversion
+
+	return &Client{
+		Provider: compute,
+		endpoint: endpoint,
+		spec:     *spec,
+	}, nil
+}
+
+// parseCloud returns an entry of a clouds.yaml
+func parseCloud(data []byte, name string) (*cloud, error) {
+	var config clouds
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, errors.Wrap(err, "error parsing clouds.yaml")
+	}
+
+	c, ok := config.Clouds[name]
+	if !ok {
+		return nil, errors.Errorf("cloud %s is not found in clouds.yaml", name)
+	}
+
+	if c.Auth.AuthURL == "" {
+		return nil, errors.Errorf("cloud %s has no auth_url", name)
+	}
+	return &c, nil
+}
+
+// authOptions returns the keystone authentication options of a cloud. The
+// token is scoped to the project of the cloud.
+func (c *cloud) authOptions() gophercloud.AuthOptions {
+	auth := c.Auth
+	options := gophercloud.AuthOptions{
+		IdentityEndpoint:            auth.AuthURL,
+		Username:                    auth.Username,
+		UserID:                      auth.UserID,
+		Password:                    auth.Password,
+		DomainID:                    firstOf(auth.UserDomainID, auth.DomainID),
+		DomainName:                  firstOf(auth.UserDomainName, auth.DomainName),
+		ApplicationCredentialID:     auth.ApplicationCredentialID,
+		ApplicationCredentialName:   auth.ApplicationCredentialName,
+		ApplicationCredentialSecret: auth.ApplicationCredentialSecret,
+		AllowReauth:                 true,
+	}
+
+	// Application credentials are always scoped to their own project.
+	if auth.ApplicationCredentialID != "" || auth.ApplicationCredentialName != "" {
+		return options
+	}
+
+	switch {
+	case auth.ProjectID != "":
+		options.Scope = &gophercloud.AuthScope{
+			ProjectID: auth.ProjectID,
+		}
+	case auth.ProjectName != "":
+		options.Scope = &gophercloud.AuthScope{
+			ProjectName: auth.ProjectName,
+			DomainID:    firstOf(auth.ProjectDomainID, auth.DomainID),
+			DomainName:  firstOf(auth.ProjectDomainName, auth.DomainName),
+		}
+		if options.Scope.DomainID == "" && options.Scope.DomainName == "" {
+			options.Scope.DomainID = options.DomainID
+			options.Scope.DomainName = options.DomainName
+		}
+	}
+	return options
+}
+
+// availability returns the endpoint interface of a cloud
+func (c *cloud) availability() gophercloud.Availability {
+	switch firstOf(c.Interface, c.EndpointType) {
+	case "internal", "internalURL":
+		return gophercloud.AvailabilityInternal
+	case "admin", "adminURL":
+		return gophercloud.AvailabilityAdmin
+	default:
+		return gophercloud.AvailabilityPublic
+	}
+}
+
+// transport returns the http transport for the certificate settings of a
+// cloud, nil if the default transport is used
+func (c *cloud) transport() (http.RoundTripper, error) {
+	if c.CACert == "" && (c.Verify == nil || *c.Verify) {
+		return nil, nil
+	}
+
+	config := &tls.Config{}
+	if c.Verify != nil && !*c.Verify {
+		config.InsecureSkipVerify = true
+	}
+
+	if c.CACert != "" {
+		pem, err := ioutil.ReadFile(c.CACert)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading ca bundle %s", c.CACert)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificate is found in %s", c.CACert)
+		}
+		config.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	return transport, nil
+}
+
+// firstOf returns the first non empty value
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package openstack
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud"
+)
+
+func TestParseCloud(t *testing.T) {
+	const cloudsYAML = `clouds:
+  openstack:
+    auth:
+      auth_url: https://keystone.example.com:5000/v3
+      username: demo
+      password: secret
+      project_name: demo
+      user_domain_name: Default
+      project_domain_id: default
+    region_name: RegionOne
+    interface: internal
+  appcred:
+    auth:
+      auth_url: https://keystone.example.com:5000/v3
+      application_credential_id: 21dced0fd20347869b93710d2b98aae0
+      application_credential_secret: secret
+    endpoint_type: adminURL
+    verify: false
+  noauth:
+    region_name: RegionOne
+`
+
+	tests := map[string]struct {
+		data             string
+		cloud            string
+		wantErr          bool
+		wantOptions      gophercloud.AuthOptions
+		wantAvailability gophercloud.Availability
+		wantInsecure     bool
+	}{
+		"password with project scope": {
+			data:  cloudsYAML,
+			cloud: "openstack",
+			wantOptions: gophercloud.AuthOptions{
+				IdentityEndpoint: "https://keystone.example.com:5000/v3",
+				Username:         "demo",
+				Password:         "secret",
+				DomainName:       "Default",
+				AllowReauth:      true,
+				Scope: &gophercloud.AuthScope{
+					ProjectName: "demo",
+					DomainID:    "default",
+				},
+			},
+			wantAvailability: gophercloud.AvailabilityInternal,
+		},
+		"application credential": {
+			data:  cloudsYAML,
+			cloud: "appcred",
+			wantOptions: gophercloud.AuthOptions{
+				IdentityEndpoint:            "https://keystone.example.com:5000/v3",
+				ApplicationCredentialID:     "21dced0fd20347869b93710d2b98aae0",
+				ApplicationCredentialSecret: "secret",
+				AllowReauth:                 true,
+			},
+			wantAvailability: gophercloud.AvailabilityAdmin,
+			wantInsecure:     true,
+		},
+		"cloud without auth url": {
+			data:    cloudsYAML,
+			cloud:   "noauth",
+			wantErr: true,
+		},
+		"missing cloud": {
+			data:    cloudsYAML,
+			cloud:   "other",
+			wantErr: true,
+		},
+		"invalid yaml": {
+			data:    "clouds: [openstack",
+			cloud:   "openstack",
+			wantErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := parseCloud([]byte(test.data), test.cloud)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			if options := c.authOptions(); !reflect.DeepEqual(options, test.wantOptions) {
+				t.Fatalf("expected auth options %+v, got %+v", test.wantOptions, options)
+			}
+			if availability := c.availability(); availability != test.wantAvailability {
+				t.Fatalf("expected %s interface, got %s", test.wantAvailability, availability)
+			}
+
+			transport, err := c.transport()
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			insecure := transport != nil &&
+				transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify
+			if insecure != test.wantInsecure {
+				t.Fatalf("expected insecure %t, got %t", test.wantInsecure, insecure)
+			}
+		})
+	}
+}
+
+func TestTransportMissingCACert(t *testing.T) {
+	c := &cloud{CACert: "/nonexistent/ca.pem"}
+	if _, err := c.transport(); err == nil {
+		t.Fatal("expected an error for a missing ca bundle, got none")
+	}
+}
@@ -0,0 +1,167 @@
+package plugin
+
+import (
+	"context"
+	"time"
+
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/shovanmaity/spotcluster/provider/plugin/pluginpb"
+)
+
+var (
+	_ provider.InstanceProvider     = &Client{}
+	_ provider.CapabilityReporter   = &Client{}
+	_ provider.Rebuilder            = &Client{}
+	_ provider.Adopter              = &Client{}
+	_ provider.IDDeleter            = &Client{}
+	_ provider.InterruptionNotifier = &Client{}
+)
+
+// Client calls a provider plugin for a pool. Optional methods are used by
+// the controllers only if the plugin reports their capability.
+type Client struct {
+	plugin       pluginpb.ProviderClient
+	pool         *pluginpb.Pool
+	capabilities provider.Capabilities
+}
+
+// Capabilities returns the capabilities reported by the plugin
+func (c *Client) Capabilities() provider.Capabilities {
+	return c.capabilities
+}
+
+// Create creates a vm for the given config
+func (c *Client) Create(ctx context.Context,
+	config provider.InstanceConfig) (*provider.InstanceConfig, error) {
+	resp, err := c.plugin.Create(ctx, &pluginpb.CreateRequest{
+		Pool:   c.pool,
+		Config: toInstanceConfig(&config),
+	})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return fromInstanceConfig(resp.Instance), nil
+}
+
+// Get returns the vm with the given tag
+func (c *Client) Get(ctx context.Context, tag string) (*provider.InstanceConfig, bool, error) {
+	resp, err := c.plugin.Get(ctx, &pluginpb.GetRequest{
+		Pool: c.pool,
+		Tag:  tag,
+	})
+	if err != nil {
+		return nil, false, fromStatus(err)
+	}
+
+	if !resp.Found {
+		return nil, false, nil
+	}
+	return fromInstanceConfig(resp.Instance), true, nil
+}
+
+// Delete deletes the vm with the given tag if it is found
+func (c *Client) Delete(ctx context.Context, tag string) error {
+	_, err := c.plugin.Delete(ctx, &pluginpb.DeleteRequest{
+		Pool: c.pool,
+		Tag:  tag,
+	})
+	return fromStatus(err)
+}
+
+// List returns all the vms with the given tag
+func (c *Client) List(ctx context.Context, tag string) ([]provider.InstanceConfig, error) {
+	resp, err := c.plugin.List(ctx, &pluginpb.ListRequest{
+		Pool: c.pool,
+		Tag:  tag,
+	})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return fromInstanceConfigs(resp.Instances), nil
+}
+
+// Reboot power cycles the vm with the given id
+func (c *Client) Reboot(ctx context.Context, id string) error {
+	_, err := c.plugin.Reboot(ctx, &pluginpb.RebootRequest{
+		Pool: c.pool,
+		Id:   id,
+	})
+	return fromStatus(err)
+}
+
+// Rebuild reimages the vm with the given id
+func (c *Client) Rebuild(ctx context.Context, id, image string) error {
+	_, err := c.plugin.Rebuild(ctx, &pluginpb.RebuildRequest{
+		Pool:  c.pool,
+		Id:    id,
+		Image: image,
+	})
+	return fromStatus(err)
+}
+
+// Find returns the vms for given vm ids or tags
+func (c *Client) Find(ctx context.Context, refs []string) ([]provider.InstanceConfig, error) {
+	resp, err := c.plugin.Find(ctx, &pluginpb.FindRequest{
+		Pool: c.pool,
+		Refs: refs,
+	})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return fromInstanceConfigs(resp.Instances), nil
+}
+
+// Tag adds the given tags to a vm
+func (c *Client) Tag(ctx context.Context, id string, tags ...string) error {
+	_, err := c.plugin.Tag(ctx, &pluginpb.TagRequest{
+		Pool: c.pool,
+		Id:   id,
+		Tags: tags,
+	})
+	return fromStatus(err)
+}
+
+// DeleteByID deletes the vm with the given id
+func (c *Client) DeleteByID(ctx context.Context, id string) error {
+	_, err := c.plugin.DeleteByID(ctx, &pluginpb.DeleteByIDRequest{
+		Pool: c.pool,
+		Id:   id,
+	})
+	return fromStatus(err)
+}
+
+// Interruption returns a notice if the vm is going to be reclaimed
+func (c *Client) Interruption(ctx context.Context,
+	id string) (*provider.InterruptionNotice, error) {
+	resp, err := c.plugin.Interruption(ctx, &pluginpb.InterruptionRequest{
+		Pool: c.pool,
+		Id:   id,
+	})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+
+	if !resp.Notice {
+		return nil, nil
+	}
+	return &provider.InterruptionNotice{
+		Time:   time.Unix(resp.TimeUnix, 0),
+		Reason: resp.Reason,
+	}, nil
+}
+
+// describe returns the description of a pool by the plugin
+func describe(plugin pluginpb.ProviderClient,
+	pool *spotcluster.Pool) (*pluginpb.DescribePoolResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	resp, err := plugin.DescribePool(ctx, &pluginpb.DescribePoolRequest{
+		Pool: toPool(pool),
+	})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return resp, nil
+}
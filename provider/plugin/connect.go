@@ -0,0 +1,206 @@
+package plugin
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/shovanmaity/spotcluster/provider/plugin/pluginpb"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+const (
+	unixPrefix = "unix://"
+	// callTimeout is the time a plugin gets to describe a pool
+	callTimeout = 10 * time.Second
+	// descriptionTTL is the time the description of a pool is used for, the
+	// pool is described again after it or as soon as the pool changes
+	descriptionTTL = time.Minute
+)
+
+// Connect registers the plugin at the given address as a provider with the
+// given name. Plugin is connected to lazily, pools of a plugin which is not
+// up yet or has gone away fail to sync until it can be reached again. Info
+// of the plugin is read on first use within the given timeout. Address is
+// host:port or the path of a unix socket as unix:///path.
+func Connect(name, address string, infoTimeout time.Duration) error {
+	if _, ok := provider.GetRegistration(name); ok {
+		return errors.Errorf("provider %s is already registered", name)
+	}
+
+	conn, err := grpc.Dial(target(address),
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(dial))
+	if err != nil {
+		return errors.Wrapf(err, "invalid address %s of plugin %s", address, name)
+	}
+
+	c := &connection{
+		name:         name,
+		address:      address,
+		plugin:       pluginpb.NewProviderClient(conn),
+		infoTimeout:  infoTimeout,
+		descriptions: make(map[string]*description),
+	}
+	c.registration = &provider.Registration{
+		New: c.newClient,
+		Template: func(pool *spotcluster.Pool) provider.InstanceConfig {
+			return *fromInstanceConfig(c.described(pool).Template)
+		},
+		Account: func(pool *spotcluster.Pool) string {
+			return c.described(pool).Account
+		},
+		SSHUser: func(pool *spotcluster.Pool) string {
+			return c.described(pool).SshUser
+		},
+	}
+	provider.Register(name, c.registration)
+
+	logrus.Infof("Registered provider plugin %s at %s", name, address)
+	return nil
+}
+
+// connection is a provider plugin which is connected to lazily. It keeps
+// the info of the plugin once it is read and the last description of every
+// pool.
+type connection struct {
+	sync.Mutex
+	name         string
+	address      string
+	plugin       pluginpb.ProviderClient
+	infoTimeout  time.Duration
+	registration *provider.Registration
+	// capabilities are nil until the info of the plugin is read
+	capabilities *provider.Capabilities
+	descriptions map[string]*description
+}
+
+// description is the description of a pool at a resource version
+type description struct {
+	*pluginpb.DescribePoolResponse
+	resourceVersion string
+	describedAt     time.Time
+}
+
+// newClient returns a client of the plugin for a pool. Pool is described
+// here so that a plugin which can not be reached fails the sync instead of
+// the registration returning an empty template, account or ssh user.
+func (c *connection) newClient(pool *spotcluster.Pool) (provider.InstanceProvider, error) {
+	if pool.ProviderSpec.Plugin == nil {
+		return nil, errors.Errorf("pool %s has no plugin provider spec", pool.GetName())
+	}
+
+	capabilities, err := c.info()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.describe(pool); err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		plugin:       c.plugin,
+		pool:         toPool(pool),
+		capabilities: capabilities,
+	}, nil
+}
+
+// info returns the capabilities of the plugin, the info of the plugin is
+// read on first use. Provider id prefix of the registration is set from it
+// before any client is returned.
+func (c *connection) info() (provider.Capabilities, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.capabilities != nil {
+		return *c.capabilities, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.infoTimeout)
+	defer cancel()
+
+	info, err := c.plugin.GetInfo(ctx, &pluginpb.GetInfoRequest{})
+	if err != nil {
+		return provider.Capabilities{}, errors.Wrapf(fromStatus(err),
+			"failed to get info of plugin %s at %s", c.name, c.address)
+	}
+
+	capabilities := fromCapabilities(info.Capabilities)
+	c.registration.ProviderIDPrefix = info.ProviderIdPrefix
+	c.capabilities = &capabilities
+
+	logrus.Infof("Connected to provider plugin %s at %s with capabilities %+v",
+		c.name, c.address, capabilities)
+	return capabilities, nil
+}
+
+// describe returns the description of a pool. Pool is described by the
+// plugin only if it has changed or its description is older than the ttl.
+func (c *connection) describe(pool *spotcluster.Pool) (*pluginpb.DescribePoolResponse, error) {
+	c.Lock()
+	cached, ok := c.descriptions[pool.GetName()]
+	c.Unlock()
+	if ok && cached.resourceVersion == pool.GetResourceVersion() &&
+		time.Since(cached.describedAt) < descriptionTTL {
+		return cached.DescribePoolResponse, nil
+	}
+
+	resp, err := describe(c.plugin, pool)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe pool %s by plugin %s",
+			pool.GetName(), c.name)
+	}
+
+	c.Lock()
+	c.descriptions[pool.GetName()] = &description{
+		DescribePoolResponse: resp,
+		resourceVersion:      pool.GetResourceVersion(),
+		describedAt:          time.Now(),
+	}
+	c.Unlock()
+	return resp, nil
+}
+
+// described returns the description of a pool for the registration which
+// has no error. It is called after a client is created for the pool, that
+// is after the pool is described.
+func (c *connection) described(pool *spotcluster.Pool) *pluginpb.DescribePoolResponse {
+	resp, err := c.describe(pool)
+	if err != nil {
+		logrus.Errorf("error describing pool %s: %s", pool.GetName(), err)
+		return &pluginpb.DescribePoolResponse{}
+	}
+	return resp
+}
+
+// target returns the grpc target of an address
+func target(address string) string {
+	if strings.HasPrefix(address, unixPrefix) {
+		return "passthrough:///" + address
+	}
+	return address
+}
+
+// dial dials a unix socket or a tcp address
+func dial(ctx context.Context, address string) (net.Conn, error) {
+	var dialer net.Dialer
+	if strings.HasPrefix(address, unixPrefix) {
+		return dialer.DialContext(ctx, "unix", strings.TrimPrefix(address, unixPrefix))
+	}
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
+// listen listens on a unix socket or a tcp address
+func listen(address string) (net.Listener, error) {
+	if strings.HasPrefix(address, unixPrefix) {
+		return net.Listen("unix", strings.TrimPrefix(address, unixPrefix))
+	}
+	return net.Listen("tcp", address)
+}
@@ -0,0 +1,155 @@
+package plugin
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/shovanmaity/spotcluster/provider/plugin/pluginpb"
+	"google.golang.org/grpc"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testBackend is a backend which counts the pools it describes
+type testBackend struct {
+	sync.Mutex
+	described int
+}
+
+func (b *testBackend) backend() Backend {
+	return Backend{
+		Registration: &provider.Registration{
+			Template: func(pool *spotcluster.Pool) provider.InstanceConfig {
+				b.Lock()
+				defer b.Unlock()
+				b.described++
+				return provider.InstanceConfig{Image: "ubuntu", Size: "small"}
+			},
+			Account: func(pool *spotcluster.Pool) string {
+				return "account"
+			},
+			SSHUser: func(pool *spotcluster.Pool) string {
+				return "ubuntu"
+			},
+			ProviderIDPrefix: "test://",
+		},
+		Pool: func(name string, config map[string]string) (*spotcluster.Pool, error) {
+			return &spotcluster.Pool{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+		},
+	}
+}
+
+func (b *testBackend) count() int {
+	b.Lock()
+	defer b.Unlock()
+	return b.described
+}
+
+// serve serves a backend at the given address until the server is stopped
+func serve(t *testing.T, address string, backend Backend) *grpc.Server {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatalf("error listening at %s: %s", address, err)
+	}
+
+	server := grpc.NewServer()
+	pluginpb.RegisterProviderServer(server, &Server{backend: backend})
+	go server.Serve(listener)
+	return server
+}
+
+// freeAddress returns a local address nothing listens at
+func freeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %s", err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// forPool returns the provider of a pool, waiting for the connection to
+// the plugin to be retried if it is not up
+func forPool(pool *spotcluster.Pool) (*provider.Registration, error) {
+	var err error
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); {
+		var registration *provider.Registration
+		_, registration, err = provider.ForPool(pool)
+		if err == nil {
+			return registration, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil, err
+}
+
+func TestConnect(t *testing.T) {
+	address := freeAddress(t)
+	if err := Connect("test-plugin", address, time.Second); err != nil {
+		t.Fatalf("expected plugin which is not up to be registered, got %s", err)
+	}
+
+	pool := &spotcluster.Pool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pool",
+			ResourceVersion: "1",
+		},
+		ProviderSpec: spotcluster.ProviderSpec{
+			Plugin: &spotcluster.Plugin{Name: "test-plugin"},
+		},
+	}
+
+	// Plugin is not up.
+	_, _, err := provider.ForPool(pool)
+	if err == nil {
+		t.Fatal("expected an error for a plugin which is not up, got none")
+	}
+	if class := provider.ClassOf(err); class != provider.ErrorTransient {
+		t.Fatalf("expected %s error, got %s: %s", provider.ErrorTransient, class, err)
+	}
+
+	// Plugin comes up.
+	backend := &testBackend{}
+	server := serve(t, address, backend.backend())
+	registration, err := forPool(pool)
+	if err != nil {
+		t.Fatalf("expected no error once the plugin is up, got %s", err)
+	}
+	if registration.ProviderIDPrefix != "test://" {
+		t.Fatalf("expected provider id prefix test://, got %s", registration.ProviderIDPrefix)
+	}
+
+	template := registration.Template(pool)
+	if template.Image != "ubuntu" || template.Size != "small" ||
+		registration.Account(pool) != "account" || registration.SSHUser(pool) != "ubuntu" {
+		t.Fatalf("unexpected description %+v %s %s", template,
+			registration.Account(pool), registration.SSHUser(pool))
+	}
+	if count := backend.count(); count != 1 {
+		t.Fatalf("expected pool to be described once, got %d", count)
+	}
+
+	// Pool changes.
+	pool.ResourceVersion = "2"
+	if _, _, err := provider.ForPool(pool); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	registration.Template(pool)
+	if count := backend.count(); count != 2 {
+		t.Fatalf("expected changed pool to be described again, got %d descriptions", count)
+	}
+
+	// Plugin goes away.
+	server.Stop()
+	pool.ResourceVersion = "3"
+	_, _, err = provider.ForPool(pool)
+	if err == nil {
+		t.Fatal("expected an error once the plugin is down, got none")
+	}
+	if class := provider.ClassOf(err); class != provider.ErrorTransient {
+		t.Fatalf("expected %s error, got %s: %s", provider.ErrorTransient, class, err)
+	}
+}
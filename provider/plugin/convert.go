@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/shovanmaity/spotcluster/provider/plugin/pluginpb"
+)
+
+// toPool returns the plugin config of a pool
+func toPool(pool *spotcluster.Pool) *pluginpb.Pool {
+	return &pluginpb.Pool{
+		Name:   pool.GetName(),
+		Config: pool.ProviderSpec.Plugin.Config,
+	}
+}
+
+func toInstanceConfig(config *provider.InstanceConfig) *pluginpb.InstanceConfig {
+	if config == nil {
+		return nil
+	}
+	return &pluginpb.InstanceConfig{
+		Id:             config.ID,
+		Name:           config.Name,
+		Region:         config.Region,
+		Zone:           config.Zone,
+		Image:          config.Image,
+		Size:           config.Size,
+		InternalIp:     config.InternalIP,
+		ExternalIp:     config.ExteralIP,
+		SshAddress:     config.SSHAddress,
+		SshFingerprint: config.SSHFingerprint,
+		Tags:           config.Tags,
+		Labels:         config.Labels,
+		Status:         config.Status,
+		IsRunning:      config.IsRunning,
+	}
+}
+
+func fromInstanceConfig(config *pluginpb.InstanceConfig) *provider.InstanceConfig {
+	if config == nil {
+		return &provider.InstanceConfig{}
+	}
+	return &provider.InstanceConfig{
+		ID:             config.Id,
+		Name:           config.Name,
+		Region:         config.Region,
+		Zone:           config.Zone,
+		Image:          config.Image,
+		Size:           config.Size,
+		InternalIP:     config.InternalIp,
+		ExteralIP:      config.ExternalIp,
+		SSHAddress:     config.SshAddress,
+		SSHFingerprint: config.SshFingerprint,
+		Tags:           config.Tags,
+		Labels:         config.Labels,
+		Status:         config.Status,
+		IsRunning:      config.IsRunning,
+	}
+}
+
+func toInstanceConfigs(configs []provider.InstanceConfig) []*pluginpb.InstanceConfig {
+	list := []*pluginpb.InstanceConfig{}
+	for i := range configs {
+		list = append(list, toInstanceConfig(&configs[i]))
+	}
+	return list
+}
+
+func fromInstanceConfigs(configs []*pluginpb.InstanceConfig) []provider.InstanceConfig {
+	list := []provider.InstanceConfig{}
+	for _, config := range configs {
+		list = append(list, *fromInstanceConfig(config))
+	}
+	return list
+}
+
+func toCapabilities(capabilities provider.Capabilities) *pluginpb.Capabilities {
+	return &pluginpb.Capabilities{
+		Rebuild:      capabilities.Rebuild,
+		Adopt:        capabilities.Adopt,
+		DeleteById:   capabilities.DeleteByID,
+		Interruption: capabilities.Interruption,
+	}
+}
+
+func fromCapabilities(capabilities *pluginpb.Capabilities) provider.Capabilities {
+	if capabilities == nil {
+		return provider.Capabilities{}
+	}
+	return provider.Capabilities{
+		Rebuild:      capabilities.Rebuild,
+		Adopt:        capabilities.Adopt,
+		DeleteByID:   capabilities.DeleteById,
+		Interruption: capabilities.Interruption,
+	}
+}
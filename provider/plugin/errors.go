@@ -0,0 +1,98 @@
+package plugin
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/pkg/errors"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain is the domain of the error info which has the error class
+const errorDomain = "spotcluster.io"
+
+// toStatus returns the grpc status of an error returned by a backend. Its
+// error class and retry delay are kept in the status details.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	class, retryAfter := provider.ErrorTransient, provider.RetryAfterOf(err)
+	if providerClass := provider.ClassOf(err); providerClass != "" {
+		class = providerClass
+	}
+
+	code := codes.Unavailable
+	switch class {
+	case provider.ErrorThrottled, provider.ErrorQuota:
+		code = codes.ResourceExhausted
+	case provider.ErrorInvalidConfig:
+		code = codes.InvalidArgument
+	case provider.ErrorTerminal:
+		code = codes.FailedPrecondition
+	}
+
+	s := status.New(code, err.Error())
+	s, detailErr := s.WithDetails(&errdetails.ErrorInfo{
+		Reason: string(class),
+		Domain: errorDomain,
+	})
+	if detailErr != nil {
+		return status.Error(code, err.Error())
+	}
+	if retryAfter > 0 {
+		if withRetry, err := s.WithDetails(&errdetails.RetryInfo{
+			RetryDelay: ptypes.DurationProto(retryAfter),
+		}); err == nil {
+			s = withRetry
+		}
+	}
+	return s.Err()
+}
+
+// fromStatus returns the classified error of a grpc status returned by a
+// plugin. Status without the error class is classified by its code.
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return provider.NewError(provider.ErrorTransient, 0, err)
+	}
+
+	message := errors.New(s.Message())
+	var class provider.ErrorClass
+	var retryAfter time.Duration
+	for _, detail := range s.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			if d.Domain == errorDomain {
+				class = provider.ErrorClass(d.Reason)
+			}
+		case *errdetails.RetryInfo:
+			if delay, err := ptypes.Duration(d.RetryDelay); err == nil {
+				retryAfter = delay
+			}
+		}
+	}
+	if class != "" {
+		return provider.NewError(class, retryAfter, message)
+	}
+
+	switch s.Code() {
+	case codes.ResourceExhausted:
+		return provider.NewError(provider.ErrorThrottled, retryAfter, message)
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.PermissionDenied,
+		codes.Unauthenticated, codes.Unimplemented:
+		return provider.NewError(provider.ErrorInvalidConfig, 0, message)
+	case codes.NotFound, codes.Aborted:
+		return provider.NewError(provider.ErrorTerminal, 0, message)
+	}
+	return provider.NewError(provider.ErrorTransient, 0, message)
+}
@@ -0,0 +1,282 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/shovanmaity/spotcluster/provider/plugin/pluginpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Backend is a provider served by a plugin
+type Backend struct {
+	// Registration is the registration of the provider
+	Registration *provider.Registration
+	// Capabilities are the optional interfaces of the provider which are
+	// reported to spot-manager
+	Capabilities provider.Capabilities
+	// Pool returns the pool of the provider for the plugin config of a pool
+	Pool func(name string, config map[string]string) (*spotcluster.Pool, error)
+}
+
+// Serve serves a backend at the given address until the listener fails.
+// Address is host:port or the path of a unix socket as unix:///path.
+func Serve(address string, backend Backend) error {
+	if strings.HasPrefix(address, unixPrefix) {
+		// Socket of a previous run is removed before listening.
+		if err := os.Remove(strings.TrimPrefix(address, unixPrefix)); err != nil &&
+			!os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	listener, err := listen(address)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen at %s", address)
+	}
+
+	server := grpc.NewServer()
+	pluginpb.RegisterProviderServer(server, &Server{backend: backend})
+	return server.Serve(listener)
+}
+
+// Server serves a backend over the provider protocol
+type Server struct {
+	backend Backend
+}
+
+var _ pluginpb.ProviderServer = &Server{}
+
+// GetInfo returns the provider id prefix and the capabilities of the
+// backend
+func (s *Server) GetInfo(ctx context.Context,
+	req *pluginpb.GetInfoRequest) (*pluginpb.GetInfoResponse, error) {
+	return &pluginpb.GetInfoResponse{
+		ProviderIdPrefix: s.backend.Registration.ProviderIDPrefix,
+		Capabilities:     toCapabilities(s.backend.Capabilities),
+	}, nil
+}
+
+// DescribePool returns the template, the account and the ssh user of a
+// pool
+func (s *Server) DescribePool(ctx context.Context,
+	req *pluginpb.DescribePoolRequest) (*pluginpb.DescribePoolResponse, error) {
+	pool, err := s.pool(req.Pool)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	template := s.backend.Registration.Template(pool)
+	return &pluginpb.DescribePoolResponse{
+		Template: toInstanceConfig(&template),
+		Account:  s.backend.Registration.Account(pool),
+		SshUser:  s.backend.Registration.SSHUser(pool),
+	}, nil
+}
+
+// Create creates a vm for the given config
+func (s *Server) Create(ctx context.Context,
+	req *pluginpb.CreateRequest) (*pluginpb.CreateResponse, error) {
+	p, err := s.provider(req.Pool)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	vm, err := p.Create(ctx, *fromInstanceConfig(req.Config))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pluginpb.CreateResponse{Instance: toInstanceConfig(vm)}, nil
+}
+
+// Get returns the vm with the given tag
+func (s *Server) Get(ctx context.Context,
+	req *pluginpb.GetRequest) (*pluginpb.GetResponse, error) {
+	p, err := s.provider(req.Pool)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	vm, found, err := p.Get(ctx, req.Tag)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pluginpb.GetResponse{Instance: toInstanceConfig(vm), Found: found}, nil
+}
+
+// Delete deletes the vm with the given tag if it is found
+func (s *Server) Delete(ctx context.Context,
+	req *pluginpb.DeleteRequest) (*pluginpb.DeleteResponse, error) {
+	p, err := s.provider(req.Pool)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	if err := p.Delete(ctx, req.Tag); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pluginpb.DeleteResponse{}, nil
+}
+
+// List returns all the vms with the given tag
+func (s *Server) List(ctx context.Context,
+	req *pluginpb.ListRequest) (*pluginpb.ListResponse, error) {
+	p, err := s.provider(req.Pool)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	list, err := p.List(ctx, req.Tag)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pluginpb.ListResponse{Instances: toInstanceConfigs(list)}, nil
+}
+
+// Reboot power cycles the vm with the given id
+func (s *Server) Reboot(ctx context.Context,
+	req *pluginpb.RebootRequest) (*pluginpb.RebootResponse, error) {
+	p, err := s.provider(req.Pool)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	if err := p.Reboot(ctx, req.Id); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pluginpb.RebootResponse{}, nil
+}
+
+// Rebuild reimages the vm with the given id
+func (s *Server) Rebuild(ctx context.Context,
+	req *pluginpb.RebuildRequest) (*pluginpb.RebuildResponse, error) {
+	p, err := s.provider(req.Pool)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	rebuilder, ok := p.(provider.Rebuilder)
+	if !ok || !s.backend.Capabilities.Rebuild {
+		return nil, unimplemented("rebuild")
+	}
+
+	if err := rebuilder.Rebuild(ctx, req.Id, req.Image); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pluginpb.RebuildResponse{}, nil
+}
+
+// Find returns the vms for given vm ids or tags
+func (s *Server) Find(ctx context.Context,
+	req *pluginpb.FindRequest) (*pluginpb.FindResponse, error) {
+	p, err := s.provider(req.Pool)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	adopter, ok := p.(provider.Adopter)
+	if !ok || !s.backend.Capabilities.Adopt {
+		return nil, unimplemented("find")
+	}
+
+	list, err := adopter.Find(ctx, req.Refs)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pluginpb.FindResponse{Instances: toInstanceConfigs(list)}, nil
+}
+
+// Tag adds the given tags to a vm
+func (s *Server) Tag(ctx context.Context,
+	req *pluginpb.TagRequest) (*pluginpb.TagResponse, error) {
+	p, err := s.provider(req.Pool)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	adopter, ok := p.(provider.Adopter)
+	if !ok || !s.backend.Capabilities.Adopt {
+		return nil, unimplemented("tag")
+	}
+
+	if err := adopter.Tag(ctx, req.Id, req.Tags...); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pluginpb.TagResponse{}, nil
+}
+
+// DeleteByID deletes the vm with the given id
+func (s *Server) DeleteByID(ctx context.Context,
+	req *pluginpb.DeleteByIDRequest) (*pluginpb.DeleteByIDResponse, error) {
+	p, err := s.provider(req.Pool)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	deleter, ok := p.(provider.IDDeleter)
+	if !ok || !s.backend.Capabilities.DeleteByID {
+		return nil, unimplemented("delete by id")
+	}
+
+	if err := deleter.DeleteByID(ctx, req.Id); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pluginpb.DeleteByIDResponse{}, nil
+}
+
+// Interruption returns a notice if the vm is going to be reclaimed
+func (s *Server) Interruption(ctx context.Context,
+	req *pluginpb.InterruptionRequest) (*pluginpb.InterruptionResponse, error) {
+	p, err := s.provider(req.Pool)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	notifier, ok := p.(provider.InterruptionNotifier)
+	if !ok || !s.backend.Capabilities.Interruption {
+		return nil, unimplemented("interruption")
+	}
+
+	notice, err := notifier.Interruption(ctx, req.Id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	if notice == nil {
+		return &pluginpb.InterruptionResponse{}, nil
+	}
+	return &pluginpb.InterruptionResponse{
+		Notice:   true,
+		TimeUnix: notice.Time.Unix(),
+		Reason:   notice.Reason,
+	}, nil
+}
+
+// pool returns the pool of the backend for a plugin pool
+func (s *Server) pool(pool *pluginpb.Pool) (*spotcluster.Pool, error) {
+	if pool == nil {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.New("got nil pool"))
+	}
+	return s.backend.Pool(pool.Name, pool.Config)
+}
+
+// provider returns the provider of the backend for a plugin pool
+func (s *Server) provider(pool *pluginpb.Pool) (provider.InstanceProvider, error) {
+	backendPool, err := s.pool(pool)
+	if err != nil {
+		return nil, err
+	}
+	return s.backend.Registration.New(backendPool)
+}
+
+// unimplemented returns the status of a capability which is not supported
+func unimplemented(capability string) error {
+	return status.Errorf(codes.Unimplemented, "plugin does not support %s", capability)
+}
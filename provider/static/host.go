@@ -0,0 +1,301 @@
+package static
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// tagsFile is kept in the home of the ssh user. A host is claimed
+	// when the file is created, it holds the tags of the claim.
+	tagsFile = ".spotcluster/tags"
+	// hostTimeout is the time a host gets to answer a command
+	hostTimeout = 15 * time.Second
+	// uninstallScript is installed by the k3s agent install script
+	uninstallScript = "/usr/local/bin/k3s-agent-uninstall.sh"
+	// reachableStatus is the status of a reachable host
+	reachableStatus = "reachable"
+)
+
+var (
+	// knownTags are the tags of every host when it was last reached. A
+	// host which is not reachable is not treated as released.
+	knownTagsLock sync.Mutex
+	knownTags     = make(map[string][]string)
+)
+
+// Client claims the hosts of a pool. Host id is its ssh address.
+type Client struct {
+	hosts []string
+	spec  spotcluster.Static
+	// dial connects to a host, it is replaced by the tests
+	dial func(ctx context.Context, user, address string) (*ssh.Client, error)
+}
+
+// hostState is the state of a host found by a scan
+type hostState struct {
+	address   string
+	reachable bool
+	config    *provider.InstanceConfig
+}
+
+// Create claims the first free host of the pool with the given tags
+func (c *Client) Create(ctx context.Context,
+	config provider.InstanceConfig) (*provider.InstanceConfig, error) {
+	command := "mkdir -p " + dirOf(tagsFile) + " && (set -C; printf '%s\\n' " +
+		quoteAll(config.Tags) + " > " + tagsFile + ")"
+
+	for _, address := range c.hosts {
+		// Claim fails if the host is claimed or not reachable, both of
+		// them are skipped.
+		if _, err := c.run(ctx, address, command); err != nil {
+			continue
+		}
+
+		host, err := c.read(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		return host, nil
+	}
+	return nil, provider.NewError(provider.ErrorQuota, 0,
+		errors.Errorf("no free host among %d hosts", len(c.hosts)))
+}
+
+// Get returns the host claimed with the given tag
+func (c *Client) Get(ctx context.Context, tag string) (*provider.InstanceConfig, bool, error) {
+	states := c.scan(ctx)
+	list := []provider.InstanceConfig{}
+	for _, state := range states {
+		if state.reachable && hasTag(state.config.Tags, tag) {
+			list = append(list, *state.config)
+		}
+	}
+
+	if len(list) == 0 {
+		for _, state := range states {
+			if !state.reachable && hasTag(lastTags(state.address), tag) {
+				return nil, false, provider.NewError(provider.ErrorTransient, 0,
+					errors.Errorf("host %s claimed with tag %s is not reachable",
+						state.address, tag))
+			}
+		}
+		return nil, false, nil
+	}
+
+	if len(list) != 1 {
+		return nil, false,
+			errors.Errorf("Got %d hosts for the given tag %s", len(list), tag)
+	}
+	return &list[0], true, nil
+}
+
+// Delete releases the host claimed with the given tag
+func (c *Client) Delete(ctx context.Context, tag string) error {
+	host, found, err := c.Get(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return nil
+	}
+	return c.DeleteByID(ctx, host.ID)
+}
+
+// List returns the reachable hosts claimed with the given tag
+func (c *Client) List(ctx context.Context, tag string) ([]provider.InstanceConfig, error) {
+	list := []provider.InstanceConfig{}
+	for _, state := range c.scan(ctx) {
+		if state.reachable && hasTag(state.config.Tags, tag) {
+			list = append(list, *state.config)
+		}
+	}
+	return list, nil
+}
+
+// Reboot reboots the host with the given id
+func (c *Client) Reboot(ctx context.Context, id string) error {
+	_, err := c.run(ctx, id, c.sudo()+"sh -c '(sleep 2; reboot) >/dev/null 2>&1 &'")
+	return err
+}
+
+// Find returns the hosts for given host addresses or tags
+func (c *Client) Find(ctx context.Context, refs []string) ([]provider.InstanceConfig, error) {
+	list := []provider.InstanceConfig{}
+	for _, ref := range refs {
+		if _, _, err := net.SplitHostPort(ref); err == nil {
+			host, err := c.read(ctx, ref)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, *host)
+			continue
+		}
+
+		hosts, err := c.List(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, hosts...)
+	}
+	return list, nil
+}
+
+// Tag adds the given tags to the claim of a host
+func (c *Client) Tag(ctx context.Context, id string, tags ...string) error {
+	_, err := c.run(ctx, id, "mkdir -p "+dirOf(tagsFile)+" && printf '%s\\n' "+
+		quoteAll(tags)+" >> "+tagsFile)
+	return err
+}
+
+// DeleteByID uninstalls the k3s agent of a host and releases it
+func (c *Client) DeleteByID(ctx context.Context, id string) error {
+	_, err := c.run(ctx, id, "if [ -x "+uninstallScript+" ]; then "+
+		c.sudo()+uninstallScript+"; fi && rm -f "+tagsFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to release host %s", id)
+	}
+
+	knownTagsLock.Lock()
+	delete(knownTags, id)
+	knownTagsLock.Unlock()
+	return nil
+}
+
+// scan reads every host of the pool at the same time
+func (c *Client) scan(ctx context.Context) []hostState {
+	states := make([]hostState, len(c.hosts))
+	var wg sync.WaitGroup
+	for i, address := range c.hosts {
+		wg.Add(1)
+		go func(i int, address string) {
+			defer wg.Done()
+
+			states[i].address = address
+			config, err := c.read(ctx, address)
+			if err != nil {
+				return
+			}
+			states[i].reachable = true
+			states[i].config = config
+		}(i, address)
+	}
+	wg.Wait()
+	return states
+}
+
+// read returns the details and the tags of a host
+func (c *Client) read(ctx context.Context, address string) (*provider.InstanceConfig, error) {
+	output, err := c.run(ctx, address, "hostname; "+
+		"echo \"$(hostname -I 2>/dev/null | awk '{print $1}')\"; "+
+		"cat "+tagsFile+" 2>/dev/null || true")
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 {
+		return nil, errors.Errorf("unexpected output from host %s: %s", address, output)
+	}
+
+	config := &provider.InstanceConfig{
+		ID:         address,
+		Name:       strings.TrimSpace(lines[0]),
+		InternalIP: strings.TrimSpace(lines[1]),
+		SSHAddress: address,
+		Status:     reachableStatus,
+		IsRunning:  true,
+	}
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		config.ExteralIP = host
+	}
+	for _, line := range lines[2:] {
+		if tag := strings.TrimSpace(line); tag != "" {
+			config.Tags = append(config.Tags, tag)
+		}
+	}
+
+	knownTagsLock.Lock()
+	knownTags[address] = config.Tags
+	knownTagsLock.Unlock()
+	return config, nil
+}
+
+// run does a ssh into a host and runs a command, output of the command is
+// returned
+func (c *Client) run(ctx context.Context, address, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, hostTimeout)
+	defer cancel()
+
+	client, err := c.dial(ctx, sshUser(c.spec), address)
+	if err != nil {
+		return "", err
+	}
+
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return "", err
+	}
+
+	defer session.Close()
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	if err := session.Run(command); err != nil {
+		return "", errors.Wrapf(err, "failed to run command on host %s: %s",
+			address, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// sudo returns the prefix which runs a command as root
+func (c *Client) sudo() string {
+	if sshUser(c.spec) == provider.StaticRootUser {
+		return ""
+	}
+	return "sudo -n "
+}
+
+// lastTags returns the tags of a host when it was last reached
+func lastTags(address string) []string {
+	knownTagsLock.Lock()
+	defer knownTagsLock.Unlock()
+
+	return knownTags[address]
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteAll quotes every value for the shell
+func quoteAll(values []string) string {
+	quoted := []string{}
+	for _, value := range values {
+		quoted = append(quoted, "'"+strings.Replace(value, "'", `'\''`, -1)+"'")
+	}
+	return strings.Join(quoted, " ")
+}
+
+func dirOf(file string) string {
+	return file[:strings.LastIndex(file, "/")]
+}
@@ -0,0 +1,253 @@
+package static
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/shovanmaity/spotcluster/provider/common/sshtest"
+	"golang.org/x/crypto/ssh"
+)
+
+// testHost is a ssh server which runs commands in its own home directory
+type testHost struct {
+	server *sshtest.Server
+	home   string
+}
+
+func startHost(t *testing.T) *testHost {
+	home, err := ioutil.TempDir("", "static-host")
+	if err != nil {
+		t.Fatalf("error creating home directory: %s", err)
+	}
+	return &testHost{
+		server: sshtest.NewServer(sshtest.Shell(home)),
+		home:   home,
+	}
+}
+
+func (h *testHost) close() {
+	h.server.Close()
+	os.RemoveAll(h.home)
+}
+
+// claim claims the host with the given tags as another pool would
+func (h *testHost) claim(t *testing.T, tags ...string) {
+	if err := os.MkdirAll(filepath.Join(h.home, dirOf(tagsFile)), 0755); err != nil {
+		t.Fatalf("error creating tags directory: %s", err)
+	}
+	err := ioutil.WriteFile(filepath.Join(h.home, tagsFile),
+		[]byte(strings.Join(tags, "\n")+"\n"), 0644)
+	if err != nil {
+		t.Fatalf("error writing tags file: %s", err)
+	}
+}
+
+// tags returns the tags in the tags file of the host, nil if the host is
+// not claimed
+func (h *testHost) tags(t *testing.T) []string {
+	data, err := ioutil.ReadFile(filepath.Join(h.home, tagsFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("error reading tags file: %s", err)
+	}
+	return strings.Fields(string(data))
+}
+
+// freeAddress returns a local address nothing listens at
+func freeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %s", err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func newTestClient(t *testing.T, hosts ...string) *Client {
+	client, err := newClient(&spotcluster.Pool{
+		ProviderSpec: spotcluster.ProviderSpec{
+			Static: &spotcluster.Static{
+				Hosts: hosts,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error creating client: %s", err)
+	}
+
+	client.dial = func(ctx context.Context, user, address string) (*ssh.Client, error) {
+		return ssh.Dial("tcp", address, &ssh.ClientConfig{
+			User:            user,
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			Timeout:         time.Second,
+		})
+	}
+	return client
+}
+
+func TestCreate(t *testing.T) {
+	tests := map[string]struct {
+		claimed     []int
+		unreachable bool
+		wantHost    int
+		wantClass   provider.ErrorClass
+	}{
+		"first free host": {
+			wantHost: 0,
+		},
+		"claimed host is skipped": {
+			claimed:  []int{0},
+			wantHost: 1,
+		},
+		"unreachable host is skipped": {
+			unreachable: true,
+			wantHost:    0,
+		},
+		"no free host": {
+			claimed:   []int{0, 1},
+			wantClass: provider.ErrorQuota,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			hosts := []*testHost{startHost(t), startHost(t)}
+			addresses := []string{}
+			if test.unreachable {
+				addresses = append(addresses, freeAddress(t))
+			}
+			for _, host := range hosts {
+				defer host.close()
+				addresses = append(addresses, host.server.Addr())
+			}
+			for _, i := range test.claimed {
+				hosts[i].claim(t, "other-uid", provider.OwnerTag)
+			}
+
+			tags := []string{"instance-uid", provider.OwnerTag}
+			vm, err := newTestClient(t, addresses...).Create(context.TODO(),
+				provider.InstanceConfig{Tags: tags})
+			if test.wantClass != "" {
+				if provider.ClassOf(err) != test.wantClass {
+					t.Fatalf("expected %s error, got %s: %v", test.wantClass, provider.ClassOf(err), err)
+				}
+				for _, i := range test.claimed {
+					if got := hosts[i].tags(t); got[0] != "other-uid" {
+						t.Fatalf("expected claim of host %d to be kept, got tags %v", i, got)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			host := hosts[test.wantHost]
+			if vm.ID != host.server.Addr() || vm.SSHAddress != host.server.Addr() {
+				t.Fatalf("expected host %s, got %+v", host.server.Addr(), vm)
+			}
+			if got := host.tags(t); strings.Join(got, ",") != strings.Join(tags, ",") {
+				t.Fatalf("expected tags %v in the tags file, got %v", tags, got)
+			}
+			if !hasTag(vm.Tags, "instance-uid") || !vm.IsRunning {
+				t.Fatalf("expected running host tagged with its uid, got %+v", vm)
+			}
+		})
+	}
+}
+
+func TestTag(t *testing.T) {
+	host := startHost(t)
+	defer host.close()
+	client := newTestClient(t, host.server.Addr())
+
+	tests := map[string]struct {
+		claimed  bool
+		tags     []string
+		wantTags []string
+	}{
+		"claimed host": {
+			claimed:  true,
+			tags:     []string{"instance-uid", provider.OwnerTag},
+			wantTags: []string{"other-uid", "instance-uid", provider.OwnerTag},
+		},
+		"free host": {
+			tags:     []string{"instance-uid"},
+			wantTags: []string{"instance-uid"},
+		},
+		"tag which needs quoting": {
+			tags:     []string{"it's"},
+			wantTags: []string{"it's"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			os.RemoveAll(filepath.Join(host.home, dirOf(tagsFile)))
+			if test.claimed {
+				host.claim(t, "other-uid")
+			}
+
+			if err := client.Tag(context.TODO(), host.server.Addr(), test.tags...); err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			if got := host.tags(t); strings.Join(got, ",") != strings.Join(test.wantTags, ",") {
+				t.Fatalf("expected tags %v, got %v", test.wantTags, got)
+			}
+
+			vm, found, err := client.Get(context.TODO(), test.tags[0])
+			if err != nil || !found {
+				t.Fatalf("expected host tagged with %s to be found, got %t: %v",
+					test.tags[0], found, err)
+			}
+			if vm.ID != host.server.Addr() {
+				t.Fatalf("expected host %s, got %s", host.server.Addr(), vm.ID)
+			}
+		})
+	}
+}
+
+func TestDeleteByID(t *testing.T) {
+	host := startHost(t)
+	defer host.close()
+	client := newTestClient(t, host.server.Addr())
+
+	tags := []string{"instance-uid", provider.OwnerTag}
+	vm, err := client.Create(context.TODO(), provider.InstanceConfig{Tags: tags})
+	if err != nil {
+		t.Fatalf("error claiming host: %s", err)
+	}
+
+	if err := client.DeleteByID(context.TODO(), vm.ID); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if got := host.tags(t); got != nil {
+		t.Fatalf("expected host to be released, got tags %v", got)
+	}
+
+	_, found, err := client.Get(context.TODO(), "instance-uid")
+	if err != nil || found {
+		t.Fatalf("expected released host not to be found, got %t: %v", found, err)
+	}
+
+	// Released host is claimed again.
+	vm, err = client.Create(context.TODO(), provider.InstanceConfig{
+		Tags: []string{"other-uid", provider.OwnerTag},
+	})
+	if err != nil {
+		t.Fatalf("expected released host to be claimed again, got %s", err)
+	}
+	if vm.ID != host.server.Addr() {
+		t.Fatalf("expected host %s, got %s", host.server.Addr(), vm.ID)
+	}
+}
@@ -0,0 +1,102 @@
+package static
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	spotcluster "github.com/shovanmaity/spotcluster/pkg/apis/spotcluster.io/v1alpha1"
+	provider "github.com/shovanmaity/spotcluster/provider/common"
+	"github.com/shovanmaity/spotcluster/remotedial"
+)
+
+var (
+	_ provider.InstanceProvider = &Client{}
+	_ provider.Adopter          = &Client{}
+	_ provider.IDDeleter        = &Client{}
+)
+
+func init() {
+	provider.Register(provider.Static, &provider.Registration{
+		New:      newProvider,
+		Template: template,
+		Account:  account,
+		SSHUser: func(pool *spotcluster.Pool) string {
+			return sshUser(*pool.ProviderSpec.Static)
+		},
+		ProviderIDPrefix: provider.StaticProviderID,
+	})
+}
+
+// newProvider returns a client for the hosts of a pool
+func newProvider(pool *spotcluster.Pool) (provider.InstanceProvider, error) {
+	return newClient(pool)
+}
+
+// template returns an empty config as hosts are not created
+func template(pool *spotcluster.Pool) provider.InstanceConfig {
+	return provider.InstanceConfig{}
+}
+
+// account returns the hosts of a pool
+func account(pool *spotcluster.Pool) string {
+	spec := pool.ProviderSpec.Static
+	account := strings.Join(spec.Hosts, ",")
+	if ref := spec.HostsConfigMap; ref != nil {
+		account += "/" + ref.Namespace + "/" + ref.Name + "/" + ref.Key
+	}
+	return account
+}
+
+// sshUser returns the ssh user of the hosts of a pool
+func sshUser(spec spotcluster.Static) string {
+	if spec.SSHUser != "" {
+		return spec.SSHUser
+	}
+	return provider.StaticRootUser
+}
+
+// newClient returns a client for the hosts listed in a pool and in its
+// hosts config map
+func newClient(pool *spotcluster.Pool) (*Client, error) {
+	spec := pool.ProviderSpec.Static
+	if spec == nil {
+		return nil, errors.Errorf("pool %s has no static provider spec", pool.GetName())
+	}
+
+	hosts := append([]string{}, spec.Hosts...)
+	if spec.HostsConfigMap != nil {
+		data, err := provider.ReadConfigMap(context.TODO(), spec.HostsConfigMap)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(data, "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				hosts = append(hosts, line)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	c := &Client{
+		spec: *spec,
+		dial: remotedial.NewSSHClientWithContext,
+	}
+	for _, host := range hosts {
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+				errors.Wrapf(err, "invalid host %s", host))
+		}
+		if !seen[host] {
+			seen[host] = true
+			c.hosts = append(c.hosts, host)
+		}
+	}
+
+	if len(c.hosts) == 0 {
+		return nil, provider.NewError(provider.ErrorInvalidConfig, 0,
+			errors.Errorf("pool %s has no hosts", pool.GetName()))
+	}
+	return c, nil
+}
@@ -1,7 +1,9 @@
 package remotedial
 
 import (
+	"context"
 	"io/ioutil"
+	"net"
 	"time"
 
 	"golang.org/x/crypto/ssh"
@@ -9,6 +11,13 @@ import (
 
 // NewSSHClient returns a ssh client for given user and remote address
 func NewSSHClient(user, remoteAddress string) (*ssh.Client, error) {
+	return NewSSHClientWithContext(context.Background(), user, remoteAddress)
+}
+
+// NewSSHClientWithContext returns a ssh client for given user and remote
+// address. Dial is aborted when the context is cancelled.
+func NewSSHClientWithContext(ctx context.Context,
+	user, remoteAddress string) (*ssh.Client, error) {
 	key, err := ioutil.ReadFile("/etc/spotcluster/id_rsa")
 	if err != nil {
 		return nil, err
@@ -25,5 +34,78 @@ func NewSSHClient(user, remoteAddress string) (*ssh.Client, error) {
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
 		Timeout:         10 * time.Minute,
 	}
-	return ssh.Dial("tcp", remoteAddress, config)
+
+	dialer := net.Dialer{Timeout: config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", remoteAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := handshake(ctx, conn, remoteAddress, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// Close the connection when the context is cancelled so that a
+	// running session is aborted.
+	go func() {
+		select {
+		case <-ctx.Done():
+			client.Close()
+		case <-closed(client):
+		}
+	}()
+
+	return client, nil
+}
+
+// handshake returns a ssh client over the given connection. Handshake is
+// bounded by the timeout of the config and is aborted when the context is
+// cancelled, a host which accepts the connection but never answers would
+// block it otherwise.
+func handshake(ctx context.Context, conn net.Conn, remoteAddress string,
+	config *ssh.ClientConfig) (*ssh.Client, error) {
+	deadline := time.Now().Add(config.Timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, remoteAddress, config)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	// Deadline is cleared as sessions may run longer than the handshake.
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// closed returns a channel which is closed once the connection is closed
+func closed(client *ssh.Client) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(done)
+	}()
+	return done
 }
@@ -0,0 +1,133 @@
+package remotedial
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/shovanmaity/spotcluster/provider/common/sshtest"
+	"golang.org/x/crypto/ssh"
+)
+
+// silentListener accepts connections and never answers, as a host which is
+// still booting may do
+func silentListener(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %s", err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	return listener
+}
+
+func TestHandshake(t *testing.T) {
+	listener := silentListener(t)
+	defer listener.Close()
+
+	tests := map[string]struct {
+		timeout time.Duration
+		ctx     func() (context.Context, context.CancelFunc)
+	}{
+		"config timeout": {
+			timeout: 100 * time.Millisecond,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+		},
+		"context deadline": {
+			timeout: time.Minute,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 100*time.Millisecond)
+			},
+		},
+		"context cancelled": {
+			timeout: time.Minute,
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(100*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := test.ctx()
+			defer cancel()
+
+			conn, err := net.Dial("tcp", listener.Addr().String())
+			if err != nil {
+				t.Fatalf("error dialing: %s", err)
+			}
+			defer conn.Close()
+
+			errCh := make(chan error, 1)
+			go func() {
+				_, err := handshake(ctx, conn, listener.Addr().String(), &ssh.ClientConfig{
+					User:            "root",
+					HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+					Timeout:         test.timeout,
+				})
+				errCh <- err
+			}()
+
+			select {
+			case err := <-errCh:
+				if err == nil {
+					t.Fatal("expected handshake to fail")
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("expected handshake to be aborted")
+			}
+		})
+	}
+}
+
+func TestHandshakeClearsDeadline(t *testing.T) {
+	server := sshtest.NewServer(func(user, command string) (string, string, int) {
+		return command, "", 0
+	})
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatalf("error dialing: %s", err)
+	}
+	defer conn.Close()
+
+	timeout := 100 * time.Millisecond
+	client, err := handshake(context.Background(), conn, server.Addr(), &ssh.ClientConfig{
+		User:            "root",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         timeout,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	defer client.Close()
+
+	// Session is run after the handshake timeout is over.
+	time.Sleep(2 * timeout)
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("error creating session: %s", err)
+	}
+	defer session.Close()
+
+	out, err := session.Output("hostname")
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if string(out) != "hostname" {
+		t.Fatalf("expected output hostname, got %q", out)
+	}
+}